@@ -0,0 +1,210 @@
+package gosql
+
+import (
+	"fmt"
+)
+
+/*
+Aggregates
+----------
+Aggregate functions fold the rows of a group into a single value: count, sum, avg, min and max. They skip NULL
+values, count(*) counts rows whatever they hold. Over a group without any values sum, avg, min and max are NULL.
+
+The sum of floats has the type of its argument, their average is a DOUBLE PRECISION. The average of INTs is an
+INT, rounded toward zero. The sum and average of NUMERICs are exact NUMERICs, the average rounded like a
+division.
+*/
+
+var aggregateFunctions = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+func isAggregate(call *callExpression) bool {
+	return aggregateFunctions[call.name.value]
+}
+
+// collectAggregates appends every aggregate call in exp that isn't in aggs yet
+func collectAggregates(exp *expression, aggs []*callExpression) []*callExpression {
+	if exp == nil {
+		return aggs
+	}
+
+	switch exp.kind {
+	case binaryKind:
+		aggs = collectAggregates(exp.binary.a, aggs)
+		return collectAggregates(exp.binary.b, aggs)
+	case callKind:
+		if !isAggregate(exp.call) {
+			for _, arg := range exp.call.args {
+				aggs = collectAggregates(arg, aggs)
+			}
+			return aggs
+		}
+
+		code := exp.call.generateCode()
+		for _, agg := range aggs {
+			if agg.generateCode() == code {
+				return aggs
+			}
+		}
+		return append(aggs, exp.call)
+	case castKind:
+		return collectAggregates(exp.cast.exp, aggs)
+	case arrayKind:
+		for _, element := range exp.array.elements {
+			aggs = collectAggregates(element, aggs)
+		}
+		return aggs
+	case subscriptKind:
+		aggs = collectAggregates(exp.subscript.exp, aggs)
+		return collectAggregates(exp.subscript.index, aggs)
+	}
+	return aggs
+}
+
+type aggregateCall struct {
+	name string
+	// nil for count(*)
+	arg     evaluator
+	argType ColumnType
+	typ     ColumnType
+}
+
+type aggregateState struct {
+	count    int64
+	sum      int64
+	floatSum float64
+	// Only for NUMERICs, nil until the first value
+	numericSum *decimal
+	value      MemoryCell
+}
+
+func compileAggregate(call *callExpression, cols []resultColumn) (*aggregateCall, error) {
+	agg := &aggregateCall{name: call.name.value, typ: BigIntType}
+	if call.star {
+		if agg.name != "count" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSelectItem, call.generateCode())
+		}
+		return agg, nil
+	}
+
+	if len(call.args) != 1 {
+		return nil, fmt.Errorf("%w: %s takes a single argument", ErrWrongNumberOfArguments, agg.name)
+	}
+	for _, arg := range call.args {
+		if inner := collectAggregates(arg, nil); len(inner) > 0 {
+			return nil, fmt.Errorf("%w: aggregate calls can't be nested", ErrInvalidSelectItem)
+		}
+	}
+
+	arg, argType, err := compileExpression(call.args[0], cols)
+	if err != nil {
+		return nil, err
+	}
+	agg.arg, agg.argType = arg, argType
+
+	switch agg.name {
+	case "sum", "avg":
+		if !isNumeric(argType) {
+			return nil, fmt.Errorf("%w: %s(%s)", ErrInvalidOperands, agg.name, argType)
+		}
+		agg.typ = argType
+		switch {
+		case agg.name == "sum" && isInteger(argType):
+			agg.typ = BigIntType
+		case agg.name == "avg" && argType == RealType:
+			agg.typ = DoubleType
+		}
+	case "min", "max":
+		agg.typ = argType
+	}
+	return agg, nil
+}
+
+func (a *aggregateCall) step(state *aggregateState, row []MemoryCell) error {
+	if a.arg == nil {
+		state.count++
+		return nil
+	}
+
+	cell, err := a.arg(row)
+	if err != nil || cell == nil {
+		return err
+	}
+	state.count++
+
+	switch a.name {
+	case "sum", "avg":
+		if a.argType == NumericType {
+			state.addNumeric(decodeNumeric(cell))
+		} else if isInteger(a.argType) {
+			i := cell.AsInt64()
+			sum := state.sum + i
+			if (sum > state.sum) != (i > 0) {
+				return fmt.Errorf("%w: %s doesn't fit in BIGINT", ErrIntegerOutOfRange, a.name)
+			}
+			state.sum = sum
+		} else {
+			state.floatSum += cell.AsFloat()
+		}
+	case "min":
+		if state.value == nil || compareCells(cell, state.value, a.argType) < 0 {
+			state.value = cell
+		}
+	case "max":
+		if state.value == nil || compareCells(cell, state.value, a.argType) > 0 {
+			state.value = cell
+		}
+	}
+	return nil
+}
+
+func (a *aggregateCall) result(state *aggregateState) (MemoryCell, error) {
+	if a.name == "count" {
+		return integerCell(state.count, BigIntType)
+	}
+	if state.count == 0 {
+		return nil, nil
+	}
+
+	if a.name != "min" && a.name != "max" && a.argType == NumericType {
+		if a.name == "avg" {
+			avg, err := divideNumeric(*state.numericSum, integerDecimal(state.count))
+			if err != nil {
+				return nil, err
+			}
+			return numericCell(avg), nil
+		}
+		return numericCell(*state.numericSum), nil
+	}
+	if a.name != "min" && a.name != "max" && !isInteger(a.argType) {
+		if a.name == "avg" {
+			return floatCell(state.floatSum/float64(state.count), a.typ)
+		}
+		return floatCell(state.floatSum, a.typ)
+	}
+
+	switch a.name {
+	case "sum":
+		return integerCell(state.sum, a.typ)
+	case "avg":
+		return integerCell(state.sum/state.count, a.typ)
+	}
+	return state.value, nil
+}
+
+func (state *aggregateState) addNumeric(d decimal) {
+	if state.numericSum == nil {
+		state.numericSum = &d
+		return
+	}
+
+	scale := max(state.numericSum.scale, d.scale)
+	sum, d := state.numericSum.rescale(scale), d.rescale(scale)
+	sum.unscaled.Add(sum.unscaled, d.unscaled)
+	state.numericSum = &sum
+}
@@ -0,0 +1,403 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+/*
+Arrays
+------
+A column of any type but another array can hold arrays of it instead, declared like INT[] or TEXT[]. An array type
+is the type of its elements with arrayTypeFlag set, so it fits the byte types are persisted in. Cells hold the
+type of the elements followed by each element, so an array can be read without knowing where it came from:
+
+	$element-type byte $count uint32 [$length int32 $element]...
+
+NULL elements have a length of -1. Arrays are written as ARRAY[1, 2, 3], or as strings in the form Postgres
+shows them, '{1,2,3}', where the type is known, and compare element by element.
+
+Elements are read with a subscript counted from 1, tags[1], which gives NULL when it's out of range. x = ANY(tags)
+is true when x compares true with any element, false when it compares false with all of them and NULL otherwise,
+with any comparison operator. unnest(tags) as a select item gives a row for every element, see projection.
+tags || tags joins two arrays, and tags || 'x' or 'x' || tags adds an element at the end or the start.
+*/
+
+const arrayTypeFlag ColumnType = 0x40
+
+func arrayOf(typ ColumnType) ColumnType {
+	return typ | arrayTypeFlag
+}
+
+func isArray(typ ColumnType) bool {
+	return typ&arrayTypeFlag != 0
+}
+
+// elementType is the type of the elements of an array, or the type itself for anything else
+func elementType(typ ColumnType) ColumnType {
+	return typ &^ arrayTypeFlag
+}
+
+func arrayCell(typ ColumnType, elements []MemoryCell) MemoryCell {
+	cell := MemoryCell{byte(typ)}
+	cell = binary.BigEndian.AppendUint32(cell, uint32(len(elements)))
+	for _, element := range elements {
+		if element == nil {
+			cell = binary.BigEndian.AppendUint32(cell, uint32(0xFFFFFFFF))
+			continue
+		}
+		cell = binary.BigEndian.AppendUint32(cell, uint32(len(element)))
+		cell = append(cell, element...)
+	}
+	return cell
+}
+
+func decodeArray(cell MemoryCell) (ColumnType, []MemoryCell) {
+	typ := ColumnType(cell[0])
+	count := binary.BigEndian.Uint32(cell[1:])
+	elements := make([]MemoryCell, 0, count)
+	rest := cell[5:]
+	for i := uint32(0); i < count; i++ {
+		length := int32(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		if length < 0 {
+			elements = append(elements, nil)
+			continue
+		}
+		elements = append(elements, rest[:length])
+		rest = rest[length:]
+	}
+	return typ, elements
+}
+
+// arrayText writes an array the way Postgres does, quoting elements that would be ambiguous otherwise
+func arrayText(cell MemoryCell) string {
+	typ, elements := decodeArray(cell)
+	texts := []string{}
+	for _, element := range elements {
+		if element == nil {
+			texts = append(texts, "NULL")
+			continue
+		}
+		text := cellText(element, typ)
+		if text == "" || strings.ContainsAny(text, "{},\"\\ \t\n") || strings.EqualFold(text, "null") {
+			text = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+		}
+		texts = append(texts, text)
+	}
+	return "{" + strings.Join(texts, ",") + "}"
+}
+
+// parseArrayText reads an array written like arrayText writes them, each element as a value of typ
+func parseArrayText(s string, typ ColumnType, mod typeModifier) (MemoryCell, error) {
+	invalid := fmt.Errorf("%w: %s is not an array", ErrInvalidDatatype, s)
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, invalid
+	}
+
+	elements := []MemoryCell{}
+	rest := strings.TrimSpace(s[1 : len(s)-1])
+	for rest != "" {
+		if len(elements) > 0 {
+			if rest[0] != ',' {
+				return nil, invalid
+			}
+			rest = strings.TrimLeft(rest[1:], " \t\n")
+		}
+
+		var text strings.Builder
+		quoted := strings.HasPrefix(rest, `"`)
+		if quoted {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				text.WriteByte(rest[i])
+			}
+			if i == len(rest) {
+				return nil, invalid
+			}
+			rest = rest[i+1:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			text.WriteString(strings.TrimSpace(rest[:end]))
+			rest = rest[end:]
+			if text.Len() == 0 || strings.ContainsAny(text.String(), `{}"`) {
+				return nil, invalid
+			}
+		}
+		rest = strings.TrimLeft(rest, " \t\n")
+
+		if !quoted && strings.EqualFold(text.String(), "null") {
+			elements = append(elements, nil)
+			continue
+		}
+		element, err := textToCell(text.String(), typ, mod)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	return arrayCell(valueType(typ), elements), nil
+}
+
+func compareArrays(a, b MemoryCell) int {
+	typ, x := decodeArray(a)
+	_, y := decodeArray(b)
+	for i := 0; i < len(x) && i < len(y); i++ {
+		// NULL elements go after any other like in Postgres
+		switch {
+		case x[i] == nil && y[i] == nil:
+			continue
+		case x[i] == nil:
+			return 1
+		case y[i] == nil:
+			return -1
+		}
+		if c := compareCells(x[i], y[i], typ); c != 0 {
+			return c
+		}
+	}
+	return len(x) - len(y)
+}
+
+// convertArray converts the elements of an array to a wider type, see converts
+func convertArray(cell MemoryCell, to ColumnType) (MemoryCell, error) {
+	from, elements := decodeArray(cell)
+	converted := []MemoryCell{}
+	for _, element := range elements {
+		c, err := convertEvaluator(constantEvaluator(element), from, to)(nil)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, c)
+	}
+	return arrayCell(to, converted), nil
+}
+
+// compileArrayConcat compiles || with an array on either side, the elements of both sides are converted to the
+// wider of their types
+func compileArrayConcat(a evaluator, at ColumnType, b evaluator, bt ColumnType) (evaluator, ColumnType, error) {
+	typ, ok := commonType(elementType(at), elementType(bt))
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s || %s", ErrInvalidOperands, at, bt)
+	}
+
+	elements := func(cell MemoryCell, t ColumnType) ([]MemoryCell, error) {
+		parts := []MemoryCell{cell}
+		if isArray(t) {
+			_, parts = decodeArray(cell)
+		}
+		converted := []MemoryCell{}
+		for _, part := range parts {
+			c, err := convertEvaluator(constantEvaluator(part), elementType(t), typ)(nil)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, c)
+		}
+		return converted, nil
+	}
+	return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+		first, err := elements(x, at)
+		if err != nil {
+			return nil, err
+		}
+		second, err := elements(y, bt)
+		if err != nil {
+			return nil, err
+		}
+		return arrayCell(typ, append(first, second...)), nil
+	}), arrayOf(typ), nil
+}
+
+// compileArray compiles ARRAY[...], its elements are converted to the widest of their types
+func compileArray(arr *arrayExpression, cols []resultColumn) (evaluator, ColumnType, error) {
+	evs, types := []evaluator{}, []ColumnType{}
+	typ, typed := ColumnType(0), false
+	for _, element := range arr.elements {
+		ev, t, err := compileExpression(element, cols)
+		if err != nil {
+			return nil, 0, err
+		}
+		evs, types = append(evs, ev), append(types, t)
+		if isNullLiteral(element) {
+			continue
+		}
+
+		switch {
+		case !typed:
+			typ, typed = t, true
+		case isNumeric(typ) && isNumeric(t):
+			typ = promoteNumeric(typ, t)
+		case converts(typ, t):
+			typ = t
+		case !converts(t, typ):
+			return nil, 0, fmt.Errorf("%w: ARRAY can't have both %s and %s elements", ErrInvalidOperands, typ, t)
+		}
+	}
+	if !typed {
+		return nil, 0, fmt.Errorf("%w: can't tell the type of the elements of %s", ErrInvalidDatatype,
+			(&expression{kind: arrayKind, array: arr}).generateCode())
+	}
+	if isArray(typ) {
+		return nil, 0, fmt.Errorf("%w: arrays of arrays", ErrNotSupported)
+	}
+
+	for i, ev := range evs {
+		if !isNullLiteral(arr.elements[i]) {
+			evs[i] = convertEvaluator(ev, types[i], typ)
+		}
+	}
+	return func(row []MemoryCell) (MemoryCell, error) {
+		elements := []MemoryCell{}
+		for _, ev := range evs {
+			cell, err := ev(row)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, cell)
+		}
+		return arrayCell(typ, elements), nil
+	}, arrayOf(typ), nil
+}
+
+// isUntypedArray is true for ARRAY[...] with no elements but NULLs
+func isUntypedArray(exp *expression) bool {
+	if exp.kind != arrayKind {
+		return false
+	}
+	for _, element := range exp.array.elements {
+		if !isNullLiteral(element) {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayToCell computes an ARRAY[...] inserted in an array column, each element as a value of the column's
+// element type, so ARRAY[] and ARRAY['2024-01-02'] can be inserted too
+func arrayToCell(arr *arrayExpression, typ ColumnType, mod typeModifier) (MemoryCell, error) {
+	elements := []MemoryCell{}
+	for _, element := range arr.elements {
+		cell, err := expressionToCell(element, elementType(typ), mod)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, cell)
+	}
+	return arrayCell(valueType(elementType(typ)), elements), nil
+}
+
+func compileSubscript(sub *subscriptExpression, cols []resultColumn) (evaluator, ColumnType, error) {
+	arr, at, err := compileExpression(sub.exp, cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	index, it, err := compileExpression(sub.index, cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isArray(at) || !isInteger(it) {
+		return nil, 0, fmt.Errorf("%w: %s[%s]", ErrInvalidOperands, at, it)
+	}
+
+	return binaryEvaluator(arr, index, func(x, y MemoryCell) (MemoryCell, error) {
+		_, elements := decodeArray(x)
+		i := y.AsInt64()
+		if i < 1 || i > int64(len(elements)) {
+			return nil, nil
+		}
+		return elements[i-1], nil
+	}), elementType(at), nil
+}
+
+// isAny is true for the ANY(...) on the right of a comparison
+func isAny(exp *expression) bool {
+	return exp.kind == callKind && exp.call.name.value == "any"
+}
+
+// compileAny compiles a comparison with ANY(...), see the comment above
+func compileAny(be *binaryExpression, cols []resultColumn) (evaluator, ColumnType, error) {
+	if len(be.b.call.args) != 1 {
+		return nil, 0, fmt.Errorf("%w: ANY takes an array", ErrInvalidOperands)
+	}
+	a, at, err := compileExpression(be.a, cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	arr, arrType, err := compileExpression(be.b.call.args[0], cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isArray(arrType) {
+		return nil, 0, fmt.Errorf("%w: ANY takes an array, not a %s", ErrInvalidOperands, arrType)
+	}
+
+	elemType := elementType(arrType)
+	if isNullLiteral(be.a) {
+		at = elemType
+	}
+	if a, at, err = coerceLiteral(be.a, a, at, elemType); err != nil {
+		return nil, 0, err
+	}
+	typ := at
+	switch {
+	case at == elemType:
+	case isNumeric(at) && isNumeric(elemType):
+		typ = promoteNumeric(at, elemType)
+	case converts(at, elemType):
+		typ = elemType
+	case !converts(elemType, at):
+		return nil, 0, fmt.Errorf("%w: %s %s ANY(%s)", ErrInvalidOperands, at, be.op.value, arrType)
+	}
+	a = convertEvaluator(a, at, typ)
+
+	op := be.op.value
+	return binaryEvaluator(a, arr, func(x, y MemoryCell) (MemoryCell, error) {
+		_, elements := decodeArray(y)
+		unknown := false
+		for _, element := range elements {
+			if element == nil {
+				unknown = true
+				continue
+			}
+			element, err := convertEvaluator(constantEvaluator(element), elemType, typ)(nil)
+			if err != nil {
+				return nil, err
+			}
+			if compare(compareCells(x, element, typ), op) {
+				return boolCell(true), nil
+			}
+		}
+		if unknown {
+			return nil, nil
+		}
+		return boolCell(false), nil
+	}), BoolType, nil
+}
+
+// isUnnest is true for a call to unnest, which is only valid as a select item
+func isUnnest(exp *expression) bool {
+	return exp.kind == callKind && exp.call.name.value == "unnest"
+}
+
+// compileUnnest compiles the array unnest gives the elements of, returning the type of the elements
+func compileUnnest(call *callExpression, cols []resultColumn) (evaluator, ColumnType, error) {
+	if len(call.args) != 1 || call.star {
+		return nil, 0, fmt.Errorf("%w: unnest takes an array", ErrInvalidOperands)
+	}
+	arr, typ, err := compileExpression(call.args[0], cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isArray(typ) {
+		return nil, 0, fmt.Errorf("%w: unnest takes an array, not a %s", ErrInvalidOperands, typ)
+	}
+	return arr, elementType(typ), nil
+}
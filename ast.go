@@ -1,5 +1,10 @@
 package gosql
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Ast struct {
 	Statements []*Statement
 }
@@ -10,66 +15,209 @@ const (
 	SelectKind AStKind = iota
 	CreateTableKind
 	InsertKind
+	ExplainKind
+	CreateIndexKind
+	AnalyzeKind
 )
 
 type Statement struct {
 	SelectStatement      *SelectStatement
 	CreateTableStatement *CreateTableStatement
 	InsertStatement      *InsertStatement
+	ExplainStatement     *ExplainStatement
+	CreateIndexStatement *CreateIndexStatement
+	AnalyzeStatement     *AnalyzeStatement
 	Kind                 AStKind
 }
 
-// An insert statement for now, has a table name and a list of values to insert:
+// An insert statement has a table name and a list of values to insert, into the columns it names or into every
+// column in order when it names none:
 type InsertStatement struct {
-	table  token
-	values []*expression
+	table   token
+	columns []token
+	values  []*expression
 }
 
-// An expression is a literal token or (in the future) a function call or inline operation:
+// An expression is a literal token, an inline operation, a function call, a conversion to a type, an array or an
+// element of one:
 type expressionKind uint
 
 const (
 	literalKind expressionKind = iota
-	//binaryKind
+	binaryKind
+	callKind
+	castKind
+	arrayKind
+	subscriptKind
 )
 
-//type binaryExpression struct {
-//	A  expression
-//	B  expression
-//	Op token
-//}
-//
-//func (be binaryExpression) GenerateCode() string {
-//	return fmt.Sprintf("(%s %s %s)", be.A.GenerateCode(), be.Op.value, be.B.GenerateCode())
-//}
+type binaryExpression struct {
+	a  *expression
+	b  *expression
+	op token
+}
+
+func (be *binaryExpression) generateCode() string {
+	op := be.op.value
+	if be.op.kind == keywordKind {
+		op = strings.ToUpper(op)
+	}
+	return fmt.Sprintf("(%s %s %s)", be.a.generateCode(), op, be.b.generateCode())
+}
+
+// A function call, star is set for calls like count(*)
+type callExpression struct {
+	name token
+	args []*expression
+	star bool
+}
+
+func (ce *callExpression) generateCode() string {
+	if ce.star {
+		return ce.name.value + "(*)"
+	}
+
+	args := []string{}
+	for _, arg := range ce.args {
+		args = append(args, arg.generateCode())
+	}
+	return fmt.Sprintf("%s(%s)", ce.name.value, strings.Join(args, ", "))
+}
+
+// A conversion of an expression to a type, written CAST(x AS type), x::type or as a typed literal like
+// DATE '2024-01-02'. The type is written like the type of a column.
+type castExpression struct {
+	exp       *expression
+	datatype  token
+	modifiers []token
+	array     bool
+}
+
+func (ce *castExpression) generateCode() string {
+	typ := strings.ToUpper(ce.datatype.value)
+	if len(ce.modifiers) > 0 {
+		modifiers := []string{}
+		for _, modifier := range ce.modifiers {
+			modifiers = append(modifiers, modifier.value)
+		}
+		typ += "(" + strings.Join(modifiers, ", ") + ")"
+	}
+	if ce.array {
+		typ += "[]"
+	}
+
+	if ce.isTypedLiteral() {
+		return typ + " '" + strings.ReplaceAll(ce.exp.literal.value, "'", "''") + "'"
+	}
+	return fmt.Sprintf("CAST(%s AS %s)", ce.exp.generateCode(), typ)
+}
+
+// isTypedLiteral is true for the casts that can be written as typed literals, see parseTypedLiteral
+func (ce *castExpression) isTypedLiteral() bool {
+	if ce.exp.kind != literalKind || ce.exp.literal.kind != stringKind || len(ce.modifiers) > 0 || ce.array {
+		return false
+	}
+	switch keyword(ce.datatype.value) {
+	case dateKeyword, timeKeyword, timestampKeyword, intervalKeyword:
+		return true
+	}
+	return false
+}
+
+// definition is the cast's type as the definition of a column, see columnTypeFromDefinition
+func (ce *castExpression) definition() *columnDefinition {
+	return &columnDefinition{datatype: ce.datatype, modifiers: ce.modifiers, array: ce.array}
+}
+
+// An array written like ARRAY[1, 2, 3]
+type arrayExpression struct {
+	elements []*expression
+	// Where ARRAY is, since an empty array has no other token
+	loc location
+}
+
+func (ae *arrayExpression) generateCode() string {
+	elements := []string{}
+	for _, element := range ae.elements {
+		elements = append(elements, element.generateCode())
+	}
+	return "ARRAY[" + strings.Join(elements, ", ") + "]"
+}
+
+// An element of an array, like tags[1]
+type subscriptExpression struct {
+	exp   *expression
+	index *expression
+}
+
+func (se *subscriptExpression) generateCode() string {
+	return fmt.Sprintf("%s[%s]", se.exp.generateCode(), se.index.generateCode())
+}
 
 type expression struct {
-	literal *token
-	kind    expressionKind
-	//binary  *binaryExpression
-}
-
-//func (e expression) GenerateCode() string {
-//	switch e.kind {
-//	case literalKind:
-//		switch e.literal.kind {
-//		case identifierKind:
-//			return fmt.Sprintf("\"%s\"", e.literal.value)
-//		case stringKind:
-//			return fmt.Sprintf("'%s'", e.literal.value)
-//		default:
-//			return fmt.Sprintf(e.literal.value)
-//		}
-//case binaryKind:
-//	return e.binary.GenerateCode()
-//}
-//return ""
-//}
-
-// A create statement, for now, has a table name and a list of column names and types:
+	literal   *token
+	binary    *binaryExpression
+	call      *callExpression
+	cast      *castExpression
+	array     *arrayExpression
+	subscript *subscriptExpression
+	kind      expressionKind
+}
+
+// loc is where the expression is in the source: where its operator, function, type or ARRAY is, or its index for
+// a subscript
+func (e *expression) loc() location {
+	switch e.kind {
+	case binaryKind:
+		return e.binary.op.loc
+	case callKind:
+		return e.call.name.loc
+	case castKind:
+		return e.cast.datatype.loc
+	case arrayKind:
+		return e.array.loc
+	case subscriptKind:
+		return e.subscript.index.loc()
+	}
+	return e.literal.loc
+}
+
+// generateCode writes the expression back as SQL, it's how expressions show up in plans and how identical
+// expressions are recognized
+func (e *expression) generateCode() string {
+	switch e.kind {
+	case literalKind:
+		switch e.literal.kind {
+		case stringKind:
+			return `"` + strings.ReplaceAll(e.literal.value, `"`, `""`) + `"`
+		case hexKind:
+			return "X'" + e.literal.value + "'"
+		default:
+			return e.literal.value
+		}
+	case binaryKind:
+		return e.binary.generateCode()
+	case callKind:
+		return e.call.generateCode()
+	case castKind:
+		return e.cast.generateCode()
+	case arrayKind:
+		return e.array.generateCode()
+	case subscriptKind:
+		return e.subscript.generateCode()
+	}
+	return ""
+}
+
+// A create statement, for now, has a table name and a list of column names and types. Types may take numbers,
+// like the precision and scale of NUMERIC(10, 2), columns may hold arrays of their type, like INT[], and may have
+// a default value for inserts that leave them out:
 type columnDefinition struct {
-	name     token
-	datatype token
+	name      token
+	datatype  token
+	modifiers []token
+	array     bool
+	def       *expression
 }
 
 type CreateTableStatement struct {
@@ -77,8 +225,235 @@ type CreateTableStatement struct {
 	cols []*columnDefinition
 }
 
-// A select statemen, for now, has a table name and a list of column names:
+// A select statement has a list of items, the table they come from along with any joined tables, and optionally
+// a filter, a grouping and an ordering:
 type SelectStatement struct {
-	item []*expression
-	from token
+	item    []*expression
+	from    token
+	alias   *token
+	joins   []*joinClause
+	where   *expression
+	groupBy []*expression
+	orderBy []*orderByItem
+}
+
+// A join has no condition when it's a cross join
+type joinClause struct {
+	table token
+	alias *token
+	on    *expression
+}
+
+type orderByItem struct {
+	exp  *expression
+	desc bool
+}
+
+type ExplainStatement struct {
+	analyze   bool
+	statement *SelectStatement
+}
+
+// An index is on a single column of a table
+type CreateIndexStatement struct {
+	name   token
+	table  token
+	column token
+}
+
+// ANALYZE collects the statistics of a table, or of every table when it has none
+type AnalyzeStatement struct {
+	table *token
+}
+
+// expressions returns every expression in the ast, including the ones nested in others, in the order they
+// appear in the source
+func (a *Ast) expressions() []*expression {
+	exps := []*expression{}
+	var walk func(exp *expression)
+	walk = func(exp *expression) {
+		if exp == nil {
+			return
+		}
+		exps = append(exps, exp)
+		switch exp.kind {
+		case binaryKind:
+			walk(exp.binary.a)
+			walk(exp.binary.b)
+		case callKind:
+			for _, arg := range exp.call.args {
+				walk(arg)
+			}
+		case castKind:
+			walk(exp.cast.exp)
+		case arrayKind:
+			for _, element := range exp.array.elements {
+				walk(element)
+			}
+		case subscriptKind:
+			walk(exp.subscript.exp)
+			walk(exp.subscript.index)
+		}
+	}
+
+	for _, stmt := range a.Statements {
+		switch stmt.Kind {
+		case SelectKind:
+			stmt.SelectStatement.walk(walk)
+		case ExplainKind:
+			stmt.ExplainStatement.statement.walk(walk)
+		case InsertKind:
+			for _, exp := range stmt.InsertStatement.values {
+				walk(exp)
+			}
+		}
+	}
+	return exps
+}
+
+// walk calls fn with every top level expression of the select in the order they appear in the source
+func (slct *SelectStatement) walk(fn func(*expression)) {
+	for _, exp := range slct.item {
+		fn(exp)
+	}
+	for _, join := range slct.joins {
+		fn(join.on)
+	}
+	fn(slct.where)
+	for _, exp := range slct.groupBy {
+		fn(exp)
+	}
+	for _, item := range slct.orderBy {
+		fn(item.exp)
+	}
+}
+
+// mapExpressions returns a copy of the ast with every literal replaced by what fn returns for it, the ast itself
+// is left untouched
+func (a *Ast) mapExpressions(fn func(*expression) (*expression, error)) (*Ast, error) {
+	copied := &Ast{}
+	for _, stmt := range a.Statements {
+		s := *stmt
+		var err error
+		switch stmt.Kind {
+		case SelectKind:
+			s.SelectStatement, err = stmt.SelectStatement.mapExpressions(fn)
+		case ExplainKind:
+			expl := *stmt.ExplainStatement
+			expl.statement, err = expl.statement.mapExpressions(fn)
+			s.ExplainStatement = &expl
+		case InsertKind:
+			inst := *stmt.InsertStatement
+			inst.values, err = mapExpressions(inst.values, fn)
+			s.InsertStatement = &inst
+		}
+		if err != nil {
+			return nil, err
+		}
+		copied.Statements = append(copied.Statements, &s)
+	}
+	return copied, nil
+}
+
+func (slct *SelectStatement) mapExpressions(fn func(*expression) (*expression, error)) (*SelectStatement, error) {
+	copied := *slct
+	var err error
+	if copied.item, err = mapExpressions(slct.item, fn); err != nil {
+		return nil, err
+	}
+
+	copied.joins = nil
+	for _, join := range slct.joins {
+		j := *join
+		if j.on, err = mapExpression(join.on, fn); err != nil {
+			return nil, err
+		}
+		copied.joins = append(copied.joins, &j)
+	}
+
+	if copied.where, err = mapExpression(slct.where, fn); err != nil {
+		return nil, err
+	}
+	if copied.groupBy, err = mapExpressions(slct.groupBy, fn); err != nil {
+		return nil, err
+	}
+
+	copied.orderBy = nil
+	for _, item := range slct.orderBy {
+		o := *item
+		if o.exp, err = mapExpression(item.exp, fn); err != nil {
+			return nil, err
+		}
+		copied.orderBy = append(copied.orderBy, &o)
+	}
+	return &copied, nil
+}
+
+func mapExpressions(exps []*expression, fn func(*expression) (*expression, error)) ([]*expression, error) {
+	if exps == nil {
+		return nil, nil
+	}
+
+	mapped := []*expression{}
+	for _, exp := range exps {
+		m, err := mapExpression(exp, fn)
+		if err != nil {
+			return nil, err
+		}
+		mapped = append(mapped, m)
+	}
+	return mapped, nil
+}
+
+// mapExpression rebuilds exp with fn applied to its literals
+func mapExpression(exp *expression, fn func(*expression) (*expression, error)) (*expression, error) {
+	if exp == nil {
+		return nil, nil
+	}
+
+	switch exp.kind {
+	case binaryKind:
+		a, err := mapExpression(exp.binary.a, fn)
+		if err != nil {
+			return nil, err
+		}
+		b, err := mapExpression(exp.binary.b, fn)
+		if err != nil {
+			return nil, err
+		}
+		return &expression{kind: binaryKind, binary: &binaryExpression{a: a, b: b, op: exp.binary.op}}, nil
+	case callKind:
+		args, err := mapExpressions(exp.call.args, fn)
+		if err != nil {
+			return nil, err
+		}
+		call := *exp.call
+		call.args = args
+		return &expression{kind: callKind, call: &call}, nil
+	case castKind:
+		inner, err := mapExpression(exp.cast.exp, fn)
+		if err != nil {
+			return nil, err
+		}
+		cast := *exp.cast
+		cast.exp = inner
+		return &expression{kind: castKind, cast: &cast}, nil
+	case arrayKind:
+		elements, err := mapExpressions(exp.array.elements, fn)
+		if err != nil {
+			return nil, err
+		}
+		return &expression{kind: arrayKind, array: &arrayExpression{elements: elements, loc: exp.array.loc}}, nil
+	case subscriptKind:
+		arr, err := mapExpression(exp.subscript.exp, fn)
+		if err != nil {
+			return nil, err
+		}
+		index, err := mapExpression(exp.subscript.index, fn)
+		if err != nil {
+			return nil, err
+		}
+		return &expression{kind: subscriptKind, subscript: &subscriptExpression{exp: arr, index: index}}, nil
+	}
+	return fn(exp)
 }
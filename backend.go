@@ -1,25 +1,94 @@
 package gosql
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+)
 
 type ColumnType uint
 
 const (
 	TextType ColumnType = iota
 	IntType
+	// Only expressions produce booleans for now, like the conditions of WHERE
+	BoolType
+	// Single precision floats, REAL, take 4 bytes, double precision ones, DOUBLE PRECISION or FLOAT, take 8
+	RealType
+	DoubleType
+	// Integers take 2 bytes as SMALLINT, 4 as INT and 8 as BIGINT
+	SmallIntType
+	BigIntType
+	// Exact numbers of any size, see numeric.go
+	NumericType
+	// Dates and times without a time zone, see temporal.go
+	DateType
+	TimeType
+	TimestampType
+	IntervalType
+	// Only columns are declared with them, their values are TEXT limited to a length and, for CHAR, padded with
+	// spaces up to it, which expressions ignore
+	VarcharType
+	CharType
+	ByteaType
+	JsonType
+	UuidType
 )
 
+func (t ColumnType) String() string {
+	if isArray(t) {
+		return elementType(t).String() + "[]"
+	}
+	switch t {
+	case TextType:
+		return "TEXT"
+	case IntType:
+		return "INT"
+	case BoolType:
+		return "BOOL"
+	case RealType:
+		return "REAL"
+	case DoubleType:
+		return "DOUBLE PRECISION"
+	case SmallIntType:
+		return "SMALLINT"
+	case BigIntType:
+		return "BIGINT"
+	case NumericType:
+		return "NUMERIC"
+	case DateType:
+		return "DATE"
+	case TimeType:
+		return "TIME"
+	case TimestampType:
+		return "TIMESTAMP"
+	case IntervalType:
+		return "INTERVAL"
+	case VarcharType:
+		return "VARCHAR"
+	case CharType:
+		return "CHAR"
+	case ByteaType:
+		return "BYTEA"
+	case JsonType:
+		return "JSON"
+	case UuidType:
+		return "UUID"
+	}
+	return "UNKNOWN"
+}
+
 type Cell interface {
 	AsText() string
+	// AsInt is only meant for SMALLINT and INT cells, AsInt64 reads any integer
 	AsInt() int32
-}
-
-type Results struct {
-	Columns []struct {
-		Type ColumnType
-		Name string
-	}
-	Rows [][]Cell
+	AsInt64() int64
+	AsBool() bool
+	AsFloat() float64
+	AsNumeric() *big.Rat
+	AsTime() time.Time
+	AsBytes() []byte
 }
 
 var (
@@ -27,8 +96,13 @@ var (
 	ErrorInvalidDataType  = errors.New("invalid data type")
 )
 
+// Backends stop and return an error wrapping ErrQueryCanceled once the context is done, selects keep checking it
+// while their rows are read
 type Backend interface {
-	CreateTable(statement *CreateTableStatement) error
-	Insert(*InsertStatement) error
-	Select(*SelectStatement) (*Results, error)
+	CreateTable(ctx context.Context, statement *CreateTableStatement) error
+	Insert(context.Context, *InsertStatement) error
+	Select(context.Context, *SelectStatement) (*Rows, error)
+	Explain(context.Context, *ExplainStatement) (*Rows, error)
+	CreateIndex(context.Context, *CreateIndexStatement) error
+	Analyze(context.Context, *AnalyzeStatement) error
 }
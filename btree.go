@@ -0,0 +1,451 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+/*
+B+Tree
+------
+Every table on disk is a B+tree keyed by rowid. Leaves hold the encoded rows and are linked left to right so a
+full scan only has to walk the leaf level. Internal nodes hold separator keys, where children[i] has every key
+lower than keys[i] and the last child has the rest.
+
+The root page of a tree never moves: when the root splits its content is copied into a new page and the root
+becomes an internal node pointing to both halves. That way the catalog can keep the root page id forever.
+
+Node layout:
+	[0]     node type
+	[1:3]   number of keys
+	[3:7]   next leaf (leaf) or unused (internal)
+	leaf:     ($key uint64, $length uint16, $value)...
+	internal: $child uint32, ($key uint64, $child uint32)...
+
+Values too big to keep in a leaf are written to a chain of overflow pages, and the leaf keeps their length and
+the first page of the chain instead, with overflowFlag set in the length of the cell. Pages are never freed, so
+replacing such a value leaves its old chain behind.
+
+Overflow page layout:
+	[0]     overflowNode
+	[1:5]   next page of the chain, 0 on the last one
+	[5:7]   length of the part of the value in this page
+	[7:]    the part of the value
+*/
+
+const (
+	leafNode     byte = 1
+	internalNode byte = 2
+	overflowNode byte = 3
+
+	nodeHeaderSize = 7
+	leafCellHeader = 10
+	internalCell   = 12
+
+	// Values in a leaf can take at most a quarter of a page so a split always leaves both halves within a page,
+	// bigger ones go to overflow pages
+	maxValueSize = (pageSize-nodeHeaderSize)/4 - leafCellHeader
+
+	overflowFlag       = 0x8000
+	overflowStubSize   = 8
+	overflowHeaderSize = 7
+	overflowCapacity   = pageSize - overflowHeaderSize
+
+	// Every page a statement writes stays in the buffer pool until it commits, so values are kept well below
+	// the size of the default pool
+	maxRowSize = 512 << 10
+)
+
+var ErrRowTooLarge = errors.New("Row is too large")
+
+type node struct {
+	leaf   bool
+	keys   []uint64
+	values [][]byte
+	// Whether each value is the length and first page of an overflow chain, see readOverflow
+	overflow []bool
+	children []pageID
+	next     pageID
+}
+
+func decodeNode(data *[pageSize]byte) (*node, error) {
+	n := &node{}
+	switch data[0] {
+	case leafNode:
+		n.leaf = true
+	case internalNode:
+	default:
+		return nil, fmt.Errorf("%w: unknown node type %d", ErrCorruptPage, data[0])
+	}
+
+	count := int(binary.BigEndian.Uint16(data[1:3]))
+	n.next = pageID(binary.BigEndian.Uint32(data[3:7]))
+	offset := nodeHeaderSize
+
+	if n.leaf {
+		for i := 0; i < count; i++ {
+			if offset+leafCellHeader > pageSize {
+				return nil, ErrCorruptPage
+			}
+			key := binary.BigEndian.Uint64(data[offset:])
+			length := int(binary.BigEndian.Uint16(data[offset+8:]))
+			overflow := length&overflowFlag != 0
+			length &^= overflowFlag
+			offset += leafCellHeader
+			if offset+length > pageSize {
+				return nil, ErrCorruptPage
+			}
+
+			// Copy the value out, the page can be evicted and reused after this
+			value := make([]byte, length)
+			copy(value, data[offset:offset+length])
+			offset += length
+
+			n.keys = append(n.keys, key)
+			n.values = append(n.values, value)
+			n.overflow = append(n.overflow, overflow)
+		}
+		return n, nil
+	}
+
+	if nodeHeaderSize+4+count*internalCell > pageSize {
+		return nil, ErrCorruptPage
+	}
+	n.children = append(n.children, pageID(binary.BigEndian.Uint32(data[offset:])))
+	offset += 4
+	for i := 0; i < count; i++ {
+		n.keys = append(n.keys, binary.BigEndian.Uint64(data[offset:]))
+		n.children = append(n.children, pageID(binary.BigEndian.Uint32(data[offset+8:])))
+		offset += internalCell
+	}
+	return n, nil
+}
+
+func (n *node) size() int {
+	if !n.leaf {
+		return nodeHeaderSize + 4 + len(n.keys)*internalCell
+	}
+
+	size := nodeHeaderSize
+	for _, value := range n.values {
+		size += leafCellHeader + len(value)
+	}
+	return size
+}
+
+func (n *node) encode(data *[pageSize]byte) {
+	*data = [pageSize]byte{}
+	data[0] = internalNode
+	if n.leaf {
+		data[0] = leafNode
+	}
+	binary.BigEndian.PutUint16(data[1:3], uint16(len(n.keys)))
+	binary.BigEndian.PutUint32(data[3:7], uint32(n.next))
+	offset := nodeHeaderSize
+
+	if n.leaf {
+		for i, key := range n.keys {
+			length := uint16(len(n.values[i]))
+			if n.overflow[i] {
+				length |= overflowFlag
+			}
+			binary.BigEndian.PutUint64(data[offset:], key)
+			binary.BigEndian.PutUint16(data[offset+8:], length)
+			offset += leafCellHeader
+			offset += copy(data[offset:], n.values[i])
+		}
+		return
+	}
+
+	binary.BigEndian.PutUint32(data[offset:], uint32(n.children[0]))
+	offset += 4
+	for i, key := range n.keys {
+		binary.BigEndian.PutUint64(data[offset:], key)
+		binary.BigEndian.PutUint32(data[offset+8:], uint32(n.children[i+1]))
+		offset += internalCell
+	}
+}
+
+// split moves the upper half of the node into a new node, returning it with the key that separates both
+func (n *node) split() (*node, uint64) {
+	if n.leaf {
+		// Split by bytes rather than by count since rows have different sizes
+		half := n.size() / 2
+		size := nodeHeaderSize
+		m := 1
+		for ; m < len(n.keys)-1; m++ {
+			size += leafCellHeader + len(n.values[m-1])
+			if size >= half {
+				break
+			}
+		}
+
+		right := &node{
+			leaf:     true,
+			keys:     append([]uint64{}, n.keys[m:]...),
+			values:   append([][]byte{}, n.values[m:]...),
+			overflow: append([]bool{}, n.overflow[m:]...),
+		}
+		n.keys = n.keys[:m]
+		n.values = n.values[:m]
+		n.overflow = n.overflow[:m]
+		return right, right.keys[0]
+	}
+
+	m := len(n.keys) / 2
+	sep := n.keys[m]
+	right := &node{
+		keys:     append([]uint64{}, n.keys[m+1:]...),
+		children: append([]pageID{}, n.children[m+1:]...),
+	}
+	n.keys = n.keys[:m]
+	n.children = n.children[:m+1]
+	return right, sep
+}
+
+type btree struct {
+	pool *bufferPool
+	root pageID
+}
+
+func createBtree(pool *bufferPool) (*btree, error) {
+	pg, err := pool.allocate()
+	if err != nil {
+		return nil, err
+	}
+	defer pool.unpin(pg)
+
+	(&node{leaf: true}).encode(&pg.data)
+	return &btree{pool: pool, root: pg.id}, nil
+}
+
+func (t *btree) readNode(id pageID) (*node, error) {
+	pg, err := t.pool.fetch(id)
+	if err != nil {
+		return nil, err
+	}
+	defer t.pool.unpin(pg)
+
+	return decodeNode(&pg.data)
+}
+
+type nodeSplit struct {
+	key   uint64
+	right pageID
+}
+
+// insert adds the value under the given key, replacing it if the key already exists
+func (t *btree) insert(key uint64, value []byte) error {
+	if len(value) > maxRowSize {
+		return fmt.Errorf("%w: %d bytes, the most is %d", ErrRowTooLarge, len(value), maxRowSize)
+	}
+	overflow := len(value) > maxValueSize
+	if overflow {
+		var err error
+		if value, err = t.writeOverflow(value); err != nil {
+			return err
+		}
+	}
+
+	split, err := t.insertInto(t.root, key, value, overflow)
+	if err != nil || split == nil {
+		return err
+	}
+
+	// The root split, move its left half out so the root page can keep being the root
+	root, err := t.pool.fetch(t.root)
+	if err != nil {
+		return err
+	}
+	defer t.pool.unpin(root)
+
+	left, err := t.pool.allocate()
+	if err != nil {
+		return err
+	}
+	defer t.pool.unpin(left)
+
+	t.pool.modify(root)
+	left.data = root.data
+	(&node{
+		keys:     []uint64{split.key},
+		children: []pageID{left.id, split.right},
+	}).encode(&root.data)
+	return nil
+}
+
+func (t *btree) insertInto(id pageID, key uint64, value []byte, overflow bool) (*nodeSplit, error) {
+	pg, err := t.pool.fetch(id)
+	if err != nil {
+		return nil, err
+	}
+	defer t.pool.unpin(pg)
+
+	n, err := decodeNode(&pg.data)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.leaf {
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= key })
+		if i < len(n.keys) && n.keys[i] == key {
+			n.values[i] = value
+			n.overflow[i] = overflow
+		} else {
+			n.keys = append(n.keys[:i], append([]uint64{key}, n.keys[i:]...)...)
+			n.values = append(n.values[:i], append([][]byte{value}, n.values[i:]...)...)
+			n.overflow = append(n.overflow[:i], append([]bool{overflow}, n.overflow[i:]...)...)
+		}
+	} else {
+		i := sort.Search(len(n.keys), func(i int) bool { return key < n.keys[i] })
+		split, err := t.insertInto(n.children[i], key, value, overflow)
+		if err != nil || split == nil {
+			return nil, err
+		}
+		n.keys = append(n.keys[:i], append([]uint64{split.key}, n.keys[i:]...)...)
+		n.children = append(n.children[:i+1], append([]pageID{split.right}, n.children[i+1:]...)...)
+	}
+
+	t.pool.modify(pg)
+	if n.size() <= pageSize {
+		n.encode(&pg.data)
+		return nil, nil
+	}
+
+	right, sep := n.split()
+	rpg, err := t.pool.allocate()
+	if err != nil {
+		return nil, err
+	}
+	defer t.pool.unpin(rpg)
+
+	if n.leaf {
+		right.next = n.next
+		n.next = rpg.id
+	}
+	n.encode(&pg.data)
+	right.encode(&rpg.data)
+	return &nodeSplit{key: sep, right: rpg.id}, nil
+}
+
+// writeOverflow writes a value to a chain of overflow pages and returns what the leaf keeps instead: its length
+// and the first page of the chain. The chain is written from its end so every page knows the next one.
+func (t *btree) writeOverflow(value []byte) ([]byte, error) {
+	next := pageID(0)
+	for i := (len(value) - 1) / overflowCapacity; i >= 0; i-- {
+		part := value[i*overflowCapacity : min((i+1)*overflowCapacity, len(value))]
+		pg, err := t.pool.allocate()
+		if err != nil {
+			return nil, err
+		}
+		pg.data[0] = overflowNode
+		binary.BigEndian.PutUint32(pg.data[1:5], uint32(next))
+		binary.BigEndian.PutUint16(pg.data[5:7], uint16(len(part)))
+		copy(pg.data[overflowHeaderSize:], part)
+		next = pg.id
+		t.pool.unpin(pg)
+	}
+
+	stub := make([]byte, overflowStubSize)
+	binary.BigEndian.PutUint32(stub[0:4], uint32(len(value)))
+	binary.BigEndian.PutUint32(stub[4:8], uint32(next))
+	return stub, nil
+}
+
+// readOverflow reads back a value written by writeOverflow
+func (t *btree) readOverflow(stub []byte) ([]byte, error) {
+	if len(stub) != overflowStubSize {
+		return nil, ErrCorruptPage
+	}
+	length := int(binary.BigEndian.Uint32(stub[0:4]))
+	id := pageID(binary.BigEndian.Uint32(stub[4:8]))
+
+	value := make([]byte, 0, length)
+	for id != 0 && len(value) < length {
+		pg, err := t.pool.fetch(id)
+		if err != nil {
+			return nil, err
+		}
+		part := int(binary.BigEndian.Uint16(pg.data[5:7]))
+		if pg.data[0] != overflowNode || part > overflowCapacity {
+			t.pool.unpin(pg)
+			return nil, fmt.Errorf("%w: bad overflow page %d", ErrCorruptPage, id)
+		}
+		value = append(value, pg.data[overflowHeaderSize:overflowHeaderSize+part]...)
+		id = pageID(binary.BigEndian.Uint32(pg.data[1:5]))
+		t.pool.unpin(pg)
+	}
+	if len(value) != length {
+		return nil, fmt.Errorf("%w: overflow chain of %d bytes instead of %d", ErrCorruptPage, len(value), length)
+	}
+	return value, nil
+}
+
+// maxKey returns the highest key in the tree, ok is false when the tree is empty
+func (t *btree) maxKey() (uint64, bool, error) {
+	id := t.root
+	for {
+		n, err := t.readNode(id)
+		if err != nil {
+			return 0, false, err
+		}
+		if !n.leaf {
+			id = n.children[len(n.children)-1]
+			continue
+		}
+		if len(n.keys) == 0 {
+			return 0, false, nil
+		}
+		return n.keys[len(n.keys)-1], true, nil
+	}
+}
+
+// btreeCursor walks the leaf level of a tree in key order. It keeps a copy of the current leaf, so it doesn't
+// hold any page pinned between calls.
+type btreeCursor struct {
+	tree  *btree
+	leaf  *node
+	index int
+}
+
+func (t *btree) first() (*btreeCursor, error) {
+	id := t.root
+	for {
+		n, err := t.readNode(id)
+		if err != nil {
+			return nil, err
+		}
+		if n.leaf {
+			return &btreeCursor{tree: t, leaf: n}, nil
+		}
+		id = n.children[0]
+	}
+}
+
+// next returns the following key and value, ok is false once the cursor went past the last leaf
+func (c *btreeCursor) next() (uint64, []byte, bool, error) {
+	for c.index >= len(c.leaf.keys) {
+		if c.leaf.next == 0 {
+			return 0, nil, false, nil
+		}
+
+		n, err := c.tree.readNode(c.leaf.next)
+		if err != nil {
+			return 0, nil, false, err
+		}
+		c.leaf = n
+		c.index = 0
+	}
+
+	key, value := c.leaf.keys[c.index], c.leaf.values[c.index]
+	if c.leaf.overflow[c.index] {
+		var err error
+		if value, err = c.tree.readOverflow(value); err != nil {
+			return 0, nil, false, err
+		}
+	}
+	c.index++
+	return key, value, true, nil
+}
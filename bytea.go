@@ -0,0 +1,106 @@
+package gosql
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Binary data
+-----------
+BYTEA (or BLOB) columns hold any bytes as they are. They're written as hex literals, like X'DEADBEEF', and shown
+the way Postgres shows them, as \xdeadbeef. Strings inserted in a BYTEA column or compared with one are read as
+their bytes, unless they're written in that hex form themselves, which is how Postgres clients send them.
+
+length and substring work on both strings and binary data, counting characters for the first and bytes for the
+second.
+*/
+
+// parseHex reads the digits of a hex literal, two per byte
+func parseHex(digits string) (MemoryCell, error) {
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("%w: X'%s' is not hexadecimal", ErrInvalidDatatype, digits)
+	}
+	return b, nil
+}
+
+// byteaFromText reads a string as binary data, see the comment above
+func byteaFromText(s string) (MemoryCell, error) {
+	if digits, ok := strings.CutPrefix(s, `\x`); ok {
+		return parseHex(digits)
+	}
+	return MemoryCell(s), nil
+}
+
+// hexDigits writes binary data the way it's written in hex literals
+func hexDigits(cell MemoryCell) string {
+	return strings.ToUpper(hex.EncodeToString(cell))
+}
+
+func byteaText(cell MemoryCell) string {
+	return `\x` + hex.EncodeToString(cell)
+}
+
+// AsBytes reads the cell of a BYTEA column, it shares its memory with the cell
+func (mc MemoryCell) AsBytes() []byte {
+	return mc
+}
+
+// compileLength counts the characters of a string or the bytes of binary data
+func compileLength(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	binary := types[0] == ByteaType
+	return func(row []MemoryCell) (MemoryCell, error) {
+		cell, err := args[0](row)
+		if err != nil || cell == nil {
+			return nil, err
+		}
+		if binary {
+			return integerCell(int64(len(cell)), IntType)
+		}
+		return integerCell(int64(utf8.RuneCount(cell)), IntType)
+	}, IntType, nil
+}
+
+// compileSubstring takes the part of a string or binary data starting at a position, counted from 1, and
+// optionally of a given length. Like in Postgres, positions before the start shorten the result instead of failing.
+func compileSubstring(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	typ := types[0]
+	return func(row []MemoryCell) (MemoryCell, error) {
+		values := []MemoryCell{}
+		for _, arg := range args {
+			cell, err := arg(row)
+			if err != nil || cell == nil {
+				return nil, err
+			}
+			values = append(values, cell)
+		}
+
+		// Characters are counted as runes, bytes as they are
+		var runes []rune
+		size := int64(len(values[0]))
+		if typ == TextType {
+			runes = []rune(values[0].AsText())
+			size = int64(len(runes))
+		}
+
+		start, end := values[1].AsInt64()-1, size
+		if len(values) == 3 {
+			length := values[2].AsInt64()
+			if length < 0 {
+				return nil, fmt.Errorf("%w: substring can't have a negative length", ErrInvalidOperands)
+			}
+			end = min(end, start+length)
+		}
+		start = max(start, 0)
+		if start >= end {
+			return MemoryCell{}, nil
+		}
+		if typ == TextType {
+			return MemoryCell(string(runes[start:end])), nil
+		}
+		return append(MemoryCell{}, values[0][start:end]...), nil
+	}, typ, nil
+}
@@ -0,0 +1,262 @@
+package gosql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Casts
+-----
+CAST(x AS type), or x::type, converts a value to another type. How freely a value of one type becomes one of
+another is given by coercionOf:
+
+	from                to                   coercion
+	any type            a wider one          implicit, see converts
+	any number          any other number     assignment, rounding to integers and failing when it doesn't fit
+	TIMESTAMP           DATE, TIME           assignment, keeping the date or the time of day
+	any type            TEXT, VARCHAR, CHAR  explicit, written as it's shown
+	TEXT                any type             explicit, read like a literal of that type
+	any integer         BOOL, and back       explicit, anything but 0 being true
+	an array            an array             the coercion of their elements
+
+Implicit coercions happen wherever needed, like when comparing an INT with a BIGINT. Assignment ones also happen
+when inserting into a column, and explicit ones only with CAST. Casting to VARCHAR(n) or CHAR(n) cuts strings down
+to n characters instead of failing like inserting them does.
+
+String literals have no type of their own until they're compared with or inserted into something else, like in
+Postgres: '12' inserted into an INT column or compared with one is 12, while 'twelve' fails with
+ErrInvalidDatatype.
+*/
+
+// A coercion is how freely values of a type become values of another, each one allowing the ones before it
+type coercion int
+
+const (
+	noCoercion coercion = iota
+	explicitCoercion
+	assignmentCoercion
+	implicitCoercion
+)
+
+// coercionOf looks up the conversion from a type to another in the table above
+func coercionOf(from, to ColumnType) coercion {
+	switch {
+	case converts(from, valueType(to)):
+		return implicitCoercion
+	case isArray(from) && isArray(to):
+		return coercionOf(elementType(from), elementType(to))
+	case valueType(to) == TextType, from == TextType:
+		return explicitCoercion
+	case isArray(from) || isArray(to):
+		return noCoercion
+	case isNumeric(from) && isNumeric(to):
+		return assignmentCoercion
+	case from == TimestampType && (to == DateType || to == TimeType):
+		return assignmentCoercion
+	case isInteger(from) && to == BoolType, from == BoolType && isInteger(to):
+		return explicitCoercion
+	}
+	return noCoercion
+}
+
+// castEvaluator converts the values ev produces to another type, which must be a coercion of it and the type of
+// values a column of the type holds, see valueType
+func castEvaluator(ev evaluator, from, to ColumnType) evaluator {
+	if converts(from, to) {
+		return convertEvaluator(ev, from, to)
+	}
+	return func(row []MemoryCell) (MemoryCell, error) {
+		cell, err := ev(row)
+		if err != nil || cell == nil {
+			return nil, err
+		}
+		return castCell(cell, from, to)
+	}
+}
+
+func castCell(cell MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	switch {
+	case converts(from, to):
+		return convertEvaluator(constantEvaluator(cell), from, to)(nil)
+	case isArray(from) && isArray(to):
+		_, elements := decodeArray(cell)
+		cast := []MemoryCell{}
+		for _, element := range elements {
+			if element != nil {
+				var err error
+				if element, err = castCell(element, elementType(from), elementType(to)); err != nil {
+					return nil, err
+				}
+			}
+			cast = append(cast, element)
+		}
+		return arrayCell(elementType(to), cast), nil
+	case to == TextType:
+		return MemoryCell(cellText(cell, from)), nil
+	case from == TextType:
+		return textToCell(cell.AsText(), to, typeModifier{})
+	case isInteger(to) && isInteger(from):
+		return integerCell(cell.AsInt64(), to)
+	case isInteger(to) && from == NumericType:
+		rounded := decodeNumeric(cell).rescale(0).unscaled
+		if !rounded.IsInt64() {
+			return nil, fmt.Errorf("%w: %s doesn't fit in %s", ErrIntegerOutOfRange, rounded, to)
+		}
+		return integerCell(rounded.Int64(), to)
+	case isInteger(to) && isNumeric(from):
+		// Floats round half to even like in Postgres
+		f := math.RoundToEven(cell.AsFloat())
+		if f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, fmt.Errorf("%w: %g doesn't fit in %s", ErrIntegerOutOfRange, f, to)
+		}
+		return integerCell(int64(f), to)
+	case to == NumericType:
+		// Written with the digits of their own precision, so the REAL 0.1 is 0.1
+		bits := 64
+		if from == RealType {
+			bits = 32
+		}
+		d, err := parseNumeric(strconv.FormatFloat(cell.AsFloat(), 'f', -1, bits))
+		if err != nil {
+			return nil, err
+		}
+		return numericCell(d), nil
+	case isNumeric(to) && isNumeric(from):
+		return floatCell(numericValue(cell, from), to)
+	case from == TimestampType:
+		days, micros := floorDiv(cellMicros(cell), microsPerDay)
+		if to == DateType {
+			return dateCell(days)
+		}
+		return microsCell(micros), nil
+	case to == BoolType:
+		return boolCell(cell.AsInt64() != 0), nil
+	case from == BoolType:
+		value := int64(0)
+		if cell.AsBool() {
+			value = 1
+		}
+		return integerCell(value, to)
+	}
+	return nil, fmt.Errorf("%w: can't cast a %s to a %s", ErrInvalidDatatype, from, to)
+}
+
+// castText cuts a string down to the length of a VARCHAR or CHAR, or of the elements of an array of them
+func castText(cell MemoryCell, typ ColumnType, mod typeModifier) MemoryCell {
+	if isArray(typ) {
+		t, elements := decodeArray(cell)
+		cut := []MemoryCell{}
+		for _, element := range elements {
+			if element != nil {
+				element = castText(element, elementType(typ), mod)
+			}
+			cut = append(cut, element)
+		}
+		return arrayCell(t, cut)
+	}
+	if (typ != VarcharType && typ != CharType) || mod.length == 0 || utf8.RuneCount(cell) <= mod.length {
+		return cell
+	}
+	return MemoryCell(string([]rune(cell.AsText())[:mod.length]))
+}
+
+// textToCell reads a string as a value of a type, the way a string literal inserted into a column of it is read
+func textToCell(s string, typ ColumnType, mod typeModifier) (MemoryCell, error) {
+	switch {
+	case isArray(typ):
+		return parseArrayText(s, elementType(typ), mod)
+	case typ == NumericType:
+		d, err := parseNumeric(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if d, err = d.fit(mod); err != nil {
+			return nil, err
+		}
+		return numericCell(d), nil
+	case isInteger(typ):
+		i, err := parseInteger(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		return integerCell(i, typ)
+	case typ == RealType, typ == DoubleType:
+		f, err := parseFloat(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		return floatCell(f, typ)
+	case typ == BoolType:
+		return parseBool(s)
+	case isTemporal(typ):
+		return parseTemporal(s, typ)
+	case typ == ByteaType:
+		return byteaFromText(s)
+	case typ == JsonType:
+		return parseJSON(s)
+	case typ == UuidType:
+		return parseUUID(s)
+	case typ == VarcharType, typ == CharType:
+		return fitText(s, typ, mod)
+	}
+	return MemoryCell(s), nil
+}
+
+// parseBool reads the words Postgres takes for booleans, in any case
+func parseBool(s string) (MemoryCell, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "t", "true", "y", "yes", "on", "1":
+		return boolCell(true), nil
+	case "f", "false", "n", "no", "off", "0":
+		return boolCell(false), nil
+	}
+	return nil, fmt.Errorf("%w: %s is not a BOOL", ErrInvalidDatatype, s)
+}
+
+// compileCast compiles CAST(x AS type). Literals are read once here, so one that isn't valid fails before any row
+// is read.
+func compileCast(cast *castExpression, cols []resultColumn) (evaluator, ColumnType, error) {
+	typ, mod, err := columnTypeFromDefinition(cast.definition())
+	if err != nil {
+		return nil, 0, err
+	}
+	// An array with nothing to tell the type of its elements by, like ARRAY[], takes the one it's cast to
+	if isArray(typ) && isUntypedArray(cast.exp) {
+		cell, err := arrayToCell(cast.exp.array, typ, mod)
+		if err != nil {
+			return nil, 0, err
+		}
+		return constantEvaluator(cell), valueType(typ), nil
+	}
+	ev, from, err := compileExpression(cast.exp, cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	if isNullLiteral(cast.exp) {
+		return ev, valueType(typ), nil
+	}
+	if coercionOf(from, typ) == noCoercion {
+		return nil, 0, fmt.Errorf("%w: can't cast a %s to a %s", ErrInvalidDatatype, from, typ)
+	}
+
+	ev = castEvaluator(ev, from, valueType(typ))
+	convert := func(row []MemoryCell) (MemoryCell, error) {
+		cell, err := ev(row)
+		if err != nil || cell == nil {
+			return nil, err
+		}
+		return fitCell(castText(cell, typ, mod), typ, mod)
+	}
+	if isConstant(cast.exp) {
+		cell, err := convert(nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		return constantEvaluator(cell), valueType(typ), nil
+	}
+	return convert, valueType(typ), nil
+}
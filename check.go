@@ -0,0 +1,224 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+Type checking
+-------------
+Every statement is checked right before it runs, against the tables as the statements before it left them, so a
+statement with a mistake fails before its backend reads or writes anything. Checking resolves every identifier to
+a column and infers the type of every expression, the same way compiling them does, and errors say where the
+mistake is in the source, like "Column does not exist: nme at 0:7":
+
+  - New tables must have types that exist and defaults of their type, and new indexes columns that exist.
+  - Inserts must have a value for each of the columns they name, which must exist. Every value must be NULL, a
+    literal that reads as the type of its column or an expression with an assignment coercion to it, see
+    coercionOf. Values too long or too precise for their column only fail when inserted.
+  - Selects, and the selects of EXPLAIN, are planned, which compiles every expression against the columns it
+    will see, without building the operators that read the rows.
+
+Tables that don't exist are left for the backend to report. Backends that aren't a catalog, see plan.go, check
+their statements themselves.
+*/
+
+// A positionError is an error in a part of a statement, with where that part is in the source
+type positionError struct {
+	err error
+	loc location
+}
+
+func (e *positionError) Error() string {
+	return fmt.Sprintf("%s at %d:%d", e.err, e.loc.line, e.loc.col)
+}
+
+func (e *positionError) Unwrap() error {
+	return e.err
+}
+
+// atLocation says where err happened, unless it already does, which is where a part inside what's at loc failed
+func atLocation(err error, loc location) error {
+	var positioned *positionError
+	if err == nil || errors.As(err, &positioned) {
+		return err
+	}
+	return &positionError{err: err, loc: loc}
+}
+
+// checkStatement checks a statement against the tables of a backend, see the comment above
+func checkStatement(backend Backend, stmt *Statement) error {
+	c, ok := backend.(catalog)
+	if !ok {
+		return nil
+	}
+
+	switch stmt.Kind {
+	case CreateTableKind:
+		return checkCreateTable(stmt.CreateTableStatement)
+	case InsertKind:
+		return checkInsert(c, stmt.InsertStatement)
+	case CreateIndexKind:
+		return checkCreateIndex(c, stmt.CreateIndexStatement)
+	case SelectKind:
+		return checkSelect(c, stmt.SelectStatement)
+	case ExplainKind:
+		return checkSelect(c, stmt.ExplainStatement.statement)
+	}
+	return nil
+}
+
+func checkCreateTable(crt *CreateTableStatement) error {
+	for _, col := range crt.cols {
+		typ, mod, err := columnTypeFromDefinition(col)
+		if err != nil {
+			return atLocation(err, col.datatype.loc)
+		}
+		if _, err := columnDefault(col, typ, mod); err != nil {
+			return atLocation(err, col.def.loc())
+		}
+	}
+	return nil
+}
+
+func checkCreateIndex(c catalog, ci *CreateIndexStatement) error {
+	cols, err := c.tableColumns(ci.table.value)
+	if err != nil {
+		return nil
+	}
+	for _, col := range cols {
+		if col.name == ci.column.value {
+			return nil
+		}
+	}
+	return atLocation(fmt.Errorf("%w: %s", ErrColumnDoesNotExist, ci.column.value), ci.column.loc)
+}
+
+// checkInsert checks an insert the way insertedRow reads it
+func checkInsert(c catalog, inst *InsertStatement) error {
+	cols, err := c.tableColumns(inst.table.value)
+	if err != nil || inst.values == nil {
+		return nil
+	}
+
+	targets := cols
+	if inst.columns != nil {
+		if len(inst.columns) != len(inst.values) {
+			err := fmt.Errorf("%w: %d values for %d columns", ErrMissingValues, len(inst.values), len(inst.columns))
+			return atLocation(err, inst.table.loc)
+		}
+
+		targets = nil
+		seen := map[string]bool{}
+		for _, column := range inst.columns {
+			found := false
+			for _, col := range cols {
+				if col.name == column.value {
+					targets, found = append(targets, col), true
+				}
+			}
+			if !found {
+				return atLocation(fmt.Errorf("%w: %s", ErrColumnDoesNotExist, column.value), column.loc)
+			}
+			if seen[column.value] {
+				return atLocation(fmt.Errorf("%w: %s", ErrDuplicateColumn, column.value), column.loc)
+			}
+			seen[column.value] = true
+		}
+	} else if len(inst.values) != len(cols) {
+		err := fmt.Errorf("%w: %d values for %d columns", ErrMissingValues, len(inst.values), len(cols))
+		return atLocation(err, inst.table.loc)
+	}
+
+	for i, value := range inst.values {
+		if err := checkValue(value, targets[i].typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkValue checks a value can be inserted into a column holding a type, see expressionToCell
+func checkValue(exp *expression, typ ColumnType) error {
+	if exp.kind == literalKind {
+		switch exp.literal.kind {
+		case stringKind, numericKind, hexKind, boolKind, nullKind:
+			_, err := tokenToCell(exp.literal, typ, typeModifier{})
+			return atLocation(err, exp.loc())
+		}
+	}
+	if exp.kind == arrayKind && isArray(typ) {
+		for _, element := range exp.array.elements {
+			if err := checkValue(element, elementType(typ)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	_, t, err := compileExpression(exp, nil)
+	if err != nil {
+		return err
+	}
+	if t != typ && coercionOf(t, typ) < assignmentCoercion {
+		err := fmt.Errorf("%w: can't insert a %s into a %s column", ErrInvalidDatatype, t, typ)
+		return atLocation(err, exp.loc())
+	}
+	return nil
+}
+
+// checkSelect plans a select, then compiles the expressions of the plan that are only compiled when building its
+// operators
+func checkSelect(c catalog, slct *SelectStatement) error {
+	node, err := logicalPlan(slct, c)
+	if err != nil {
+		return err
+	}
+	return checkPlan(node)
+}
+
+// checkPlan compiles the expressions of every node, children first like building the operators does
+func checkPlan(node planNode) error {
+	var children []planNode
+	switch n := node.(type) {
+	case *filterNode:
+		children = []planNode{n.child}
+	case *joinNode:
+		children = []planNode{n.left, n.right}
+	case *aggregateNode:
+		children = []planNode{n.child}
+	case *sortNode:
+		children = []planNode{n.child}
+	case *projectNode:
+		children = []planNode{n.child}
+	}
+	for _, child := range children {
+		if err := checkPlan(child); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	switch n := node.(type) {
+	case *filterNode:
+		_, err = compileCondition(n.condition, n.child.columns())
+	case *joinNode:
+		if n.condition != nil {
+			_, err = compileCondition(n.condition, n.columns())
+		}
+	case *sortNode:
+		for _, item := range n.orderBy {
+			if _, _, err = compileExpression(item.exp, n.child.columns()); err != nil {
+				break
+			}
+		}
+	case *projectNode:
+		for _, item := range n.items {
+			if _, _, err = compileItem(item, n.child.columns()); err != nil {
+				break
+			}
+		}
+	}
+	return err
+}
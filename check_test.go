@@ -0,0 +1,84 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingBackend counts the statements that reach a memory backend
+type countingBackend struct {
+	*MemoryBackend
+	inserts int
+	selects int
+}
+
+func (b *countingBackend) Insert(ctx context.Context, inst *InsertStatement) error {
+	b.inserts++
+	return b.MemoryBackend.Insert(ctx, inst)
+}
+
+func (b *countingBackend) Select(ctx context.Context, slct *SelectStatement) (*Rows, error) {
+	b.selects++
+	return b.MemoryBackend.Select(ctx, slct)
+}
+
+func TestCheckStatement(t *testing.T) {
+	b := &countingBackend{MemoryBackend: NewMemoryBackend()}
+	execute(t, b, `CREATE TABLE users (id INT, name TEXT, tags TEXT[]);`)
+	execute(t, b, `INSERT INTO users VALUES (1, 'ana', NULL);`)
+	b.inserts, b.selects = 0, 0
+
+	tests := []struct {
+		source string
+		err    error
+		at     string
+	}{
+		{`SELECT nme FROM users;`, ErrColumnDoesNotExist, "at 0:7"},
+		{`SELECT id FROM users WHERE id = 'one';`, ErrInvalidDatatype, "at 0:32"},
+		{`SELECT id FROM users WHERE name > 1 + 2;`, ErrInvalidOperands, "at 0:32"},
+		{`SELECT id FROM users WHERE id + 1;`, ErrInvalidDatatype, "at 0:30"},
+		{`SELECT id FROM users WHERE count(*) > 1;`, ErrInvalidSelectItem, "at 0:27"},
+		{`SELECT id, count(*) FROM users;`, ErrInvalidSelectItem, "column id must appear in GROUP BY or be used in an aggregate at 0:7"},
+		{`SELECT id FROM users ORDER BY count(*);`, ErrInvalidSelectItem, "at 0:7"},
+		{`SELECT count(*) FROM users GROUP BY name ORDER BY length(id);`, ErrInvalidSelectItem, "at 0:57"},
+		{`SELECT id FROM users ORDER BY soundex(name);`, ErrFunctionDoesNotExist, "at 0:30"},
+		{"SELECT id,\n  length(id)\nFROM users;", ErrInvalidOperands, "at 1:2"},
+		{`EXPLAIN SELECT id FROM users WHERE tags[1] = 2;`, ErrInvalidOperands, "at 0:43"},
+		{`INSERT INTO users VALUES (2, 'luis');`, ErrMissingValues, "at 0:12"},
+		{`INSERT INTO users VALUES ('two', 'luis', NULL);`, ErrInvalidDatatype, "at 0:26"},
+		{`INSERT INTO users VALUES (2, 'luis', ARRAY['a', 1]);`, ErrInvalidDatatype, "at 0:48"},
+		{`INSERT INTO users VALUES (2, 'luis', 1 + 1);`, ErrInvalidDatatype, "at 0:39"},
+		{`INSERT INTO users VALUES (DATE '2024-01-02', 'luis', NULL);`, ErrInvalidDatatype, "at 0:26"},
+		{`INSERT INTO users (id, nme) VALUES (2, 'luis');`, ErrColumnDoesNotExist, "at 0:23"},
+		{`INSERT INTO users (id, id) VALUES (2, 3);`, ErrDuplicateColumn, "at 0:23"},
+		{`CREATE TABLE other (id INT DEFAULT 'x');`, ErrInvalidDatatype, "at 0:35"},
+		{`CREATE TABLE other (id NUMERIC(1, 2));`, ErrorInvalidDataType, "at 0:23"},
+		{`CREATE INDEX users_nme ON users (nme);`, ErrColumnDoesNotExist, "at 0:33"},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+		_, _, err = runStatements(context.Background(), b, ast)
+		assert.True(t, errors.Is(err, test.err), "%s: %v", test.source, err)
+		if assert.NotNil(t, err, test.source) {
+			assert.Contains(t, err.Error(), test.at, test.source)
+		}
+	}
+
+	// None of them reached the backend, and neither did the statements after them
+	assert.Equal(t, 0, b.inserts)
+	assert.Equal(t, 0, b.selects)
+	ast, err := Parse(`INSERT INTO users VALUES (2, 'luis', NULL); SELECT id FROM users WHERE name = 1;`)
+	assert.Nil(t, err)
+	_, _, err = runStatements(context.Background(), b, ast)
+	assert.True(t, errors.Is(err, ErrInvalidOperands), err)
+	assert.Equal(t, 1, b.inserts)
+	assert.Equal(t, 0, b.selects)
+
+	// Statements are checked against the tables the ones before them created
+	execute(t, b, `CREATE TABLE teams (id INT); INSERT INTO teams VALUES (1); SELECT id FROM teams;`)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/macwinux/gosql"
+	"net"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:5432", "address to listen on")
+	dbPath := flag.String("db", "", "database file, data is kept in memory if empty")
+	timeout := flag.Duration("query-timeout", 0, "cancel queries running longer than this, no limit if zero")
+	flag.Parse()
+
+	var backend gosql.Backend = gosql.NewMemoryBackend()
+	if *dbPath != "" {
+		db, err := gosql.OpenDiskBackend(*dbPath)
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+		backend = db
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		panic(err)
+	}
+
+	// Stop accepting connections on Ctrl-C so the backend gets closed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	fmt.Printf("Listening on %s\n", l.Addr())
+	server := gosql.NewPgServer(backend)
+	server.QueryTimeout = *timeout
+	if err := server.Serve(l); err != nil {
+		panic(err)
+	}
+}
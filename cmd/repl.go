@@ -2,71 +2,87 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"github.com/macwinux/gosql"
+	"io"
 	"os"
 	"strings"
 )
 
 func main() {
-	mb := gosql.NewMemoryBackend()
+	dbPath := flag.String("db", "", "database file, data is kept in memory if empty")
+	snapshot := flag.String("load", "", "snapshot to load into memory before starting")
+	flag.Parse()
+
+	db := gosql.Open()
+	if *snapshot != "" {
+		f, err := os.Open(*snapshot)
+		if err != nil {
+			panic(err)
+		}
+		loaded, err := gosql.LoadMemoryBackend(f)
+		f.Close()
+		if err != nil {
+			panic(err)
+		}
+		db = gosql.OpenBackend(loaded)
+	} else if *dbPath != "" {
+		backend, err := gosql.OpenDiskBackend(*dbPath)
+		if err != nil {
+			panic(err)
+		}
+		db = gosql.OpenBackend(backend)
+	}
+	defer db.Close()
+
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Println("Welcome to gosql")
 	for {
 		fmt.Println("# ")
 		text, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return
+		}
 		text = strings.Replace(text, "\n", "", -1)
 
-		ast, err := gosql.Parse(text)
+		rows, err := db.Query(text)
 		if err != nil {
-			panic(err)
+			fmt.Println(err)
+			continue
 		}
 
-		for _, stmt := range ast.Statements {
-			switch stmt.Kind {
-			case gosql.CreateTableKind:
-				err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
-				if err != nil {
-					panic(err)
-				}
-				fmt.Println("ok")
-			case gosql.InsertKind:
-				err = mb.Insert(stmt.InsertStatement)
-				if err != nil {
-					panic(err)
-				}
-				fmt.Println("ok")
-			case gosql.SelectKind:
-				results, err := mb.Select(stmt.SelectStatement)
-				if err != nil {
+		columns := rows.Columns()
+		if len(columns) > 0 {
+			for _, col := range columns {
+				fmt.Printf("| %s ", col)
+			}
+			fmt.Println("|")
+			for i := 0; i < 20; i++ {
+				fmt.Printf("=")
+			}
+			fmt.Println()
+
+			values := make([]any, len(columns))
+			dest := make([]any, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			for rows.Next() {
+				if err := rows.Scan(dest...); err != nil {
 					panic(err)
 				}
-				for _, col := range results.Columns {
-					fmt.Printf("| %s ", col.Name)
-				}
-				fmt.Println("|")
-				for i := 0; i < 20; i++ {
-					fmt.Printf("=")
+				fmt.Printf("|")
+				for _, value := range values {
+					fmt.Printf("| %v ", value)
 				}
 				fmt.Println()
-
-				for _, result := range results.Rows {
-					fmt.Printf("|")
-					for i, cell := range result {
-						typ := results.Columns[i].Type
-						s := ""
-						switch typ {
-						case gosql.IntType:
-							s = fmt.Sprintf("%d", cell.AsInt())
-						case gosql.TextType:
-							s = cell.AsText()
-						}
-						fmt.Printf("| %s ", s)
-					}
-					fmt.Println()
-				}
-				fmt.Println("ok")
 			}
 		}
+		if err := rows.Err(); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Println("ok")
 	}
 }
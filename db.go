@@ -0,0 +1,101 @@
+package gosql
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+/*
+Embedding
+---------
+DB is the way to use gosql from Go without dealing with the ast: it parses scripts of any number of statements,
+binds their parameters and dispatches every statement to the backend.
+
+	db := gosql.Open()
+	_, err := db.Exec(`CREATE TABLE users (id INT, name TEXT); INSERT INTO users VALUES ($1, $2);`, 1, "Carlos")
+	rows, err := db.Query(`SELECT id, name FROM users;`)
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+	}
+
+A DB is safe for concurrent use, statements and the rows of queries are produced one at a time.
+*/
+
+type DB struct {
+	mu      sync.Mutex
+	backend Backend
+}
+
+// Open returns a DB keeping its tables in memory
+func Open() *DB {
+	return OpenBackend(NewMemoryBackend())
+}
+
+// OpenBackend returns a DB on top of any backend
+func OpenBackend(backend Backend) *DB {
+	return &DB{backend: backend}
+}
+
+// Close closes the backend if it needs closing, like the disk backend does
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if c, ok := db.backend.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type Result struct {
+	RowsAffected int64
+}
+
+// Exec runs every statement in source, stopping at the first one that fails
+func (db *DB) Exec(source string, args ...any) (Result, error) {
+	return db.ExecContext(context.Background(), source, args...)
+}
+
+// ExecContext is like Exec, statements still to run fail with ErrQueryCanceled once ctx is done
+func (db *DB) ExecContext(ctx context.Context, source string, args ...any) (Result, error) {
+	affected, rows, err := db.run(ctx, source, args)
+	if err != nil {
+		return Result{}, err
+	}
+	rows.locker = mutexLocker{&db.mu}
+	return Result{RowsAffected: affected}, rows.Close()
+}
+
+// Query runs every statement in source and returns the rows of the last select
+func (db *DB) Query(source string, args ...any) (*Rows, error) {
+	return db.QueryContext(context.Background(), source, args...)
+}
+
+// QueryContext is like Query, ctx keeps being checked while the rows are read so a deadline stops a long query
+// midway
+func (db *DB) QueryContext(ctx context.Context, source string, args ...any) (*Rows, error) {
+	_, rows, err := db.run(ctx, source, args)
+	if err != nil {
+		return nil, err
+	}
+	rows.locker = mutexLocker{&db.mu}
+	return rows, nil
+}
+
+func (db *DB) run(ctx context.Context, source string, args []any) (int64, *Rows, error) {
+	stmt, err := PrepareContext(ctx, source)
+	if err != nil {
+		return 0, nil, err
+	}
+	ast, err := stmt.Bind(args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return runStatements(ctx, db.backend, ast)
+}
@@ -0,0 +1,212 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	res, err := db.Exec(`
+		CREATE TABLE users (id INT, name TEXT);
+		INSERT INTO users VALUES (1, "Carlos");
+		INSERT INTO users VALUES ($1, $2);`, 2, "Ana")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), res.RowsAffected)
+
+	rows, err := db.Query(`INSERT INTO users VALUES (3, "Luis"); SELECT id, name FROM users;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "name"}, rows.Columns())
+
+	ids := []int{}
+	names := []string{}
+	for rows.Next() {
+		var id int
+		var name string
+		assert.Nil(t, rows.Scan(&id, &name))
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+	assert.Nil(t, rows.Close())
+	assert.Equal(t, []int{1, 2, 3}, ids)
+	assert.Equal(t, []string{"Carlos", "Ana", "Luis"}, names)
+
+	rows, err = db.Query(`SELECT name, id FROM users;`)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+
+	var id int64
+	var name string
+	var anything any
+	assert.True(t, errors.Is(rows.Scan(&id, &name), ErrInvalidDatatype))
+	assert.True(t, errors.Is(rows.Scan(&name), ErrWrongNumberOfArguments))
+	assert.Nil(t, rows.Scan(&name, &anything))
+	assert.Equal(t, int64(1), anything)
+
+	// A script without selects has no rows
+	rows, err = db.Query(`INSERT INTO users VALUES (?, ?);`, 4, nil)
+	assert.Nil(t, err)
+	assert.False(t, rows.Next())
+
+	rows, err = db.Query(`SELECT name FROM users;`)
+	assert.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		assert.True(t, rows.Next())
+	}
+	assert.True(t, errors.Is(rows.Scan(&name), ErrNullValue))
+	assert.False(t, rows.Next())
+
+	_, err = db.Query(`SELECT id FROM missing;`)
+	assert.Equal(t, ErrTableDoesNotExist, err)
+}
+
+func TestDB_insertColumns(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE users (id INT, name TEXT DEFAULT 'anonymous', age INT DEFAULT 18 + 2, team TEXT);
+		INSERT INTO users (id) VALUES (1);
+		INSERT INTO users (age, id, team) VALUES (30, 2, 'red');
+		INSERT INTO users VALUES (3, NULL, NULL, NULL);`)
+	assert.Nil(t, err)
+
+	rows, err := queryAll(t, db, `SELECT id, name, age, team FROM users;`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]any{
+		{int64(1), "anonymous", int64(20), nil},
+		{int64(2), "anonymous", int64(30), "red"},
+		{int64(3), nil, nil, nil},
+	}, rows)
+
+	tests := []struct {
+		source string
+		err    error
+	}{
+		{
+			source: `INSERT INTO users (id, missing) VALUES (4, 1);`,
+			err:    ErrColumnDoesNotExist,
+		},
+		{
+			source: `INSERT INTO users (id, id) VALUES (4, 5);`,
+			err:    ErrDuplicateColumn,
+		},
+		{
+			source: `INSERT INTO users (id, name) VALUES (4);`,
+			err:    ErrMissingValues,
+		},
+		{
+			source: `CREATE TABLE wrong (id INT DEFAULT X'01');`,
+			err:    ErrInvalidDatatype,
+		},
+		{
+			source: `CREATE TABLE wrong (id INT DEFAULT other);`,
+			err:    ErrColumnDoesNotExist,
+		},
+	}
+	for _, test := range tests {
+		_, err := db.Exec(test.source)
+		assert.True(t, errors.Is(err, test.err), "%s: %v", test.source, err)
+	}
+
+	// A table the backend itself rejects is left out, even without the checks run before it
+	mb := NewMemoryBackend()
+	ast, err := Parse(`CREATE TABLE t (a INT, b INT DEFAULT 'abc', c TEXT);`)
+	assert.Nil(t, err)
+	err = mb.CreateTable(context.Background(), ast.Statements[0].CreateTableStatement)
+	assert.True(t, errors.Is(err, ErrInvalidDatatype), err)
+	execute(t, mb, `CREATE TABLE t (a INT);`)
+	execute(t, mb, `INSERT INTO t VALUES (1);`)
+	assert.Equal(t, 1, len(collect(t, execute(t, mb, `SELECT * FROM t;`))))
+
+	_, err = db.Exec(`CREATE TABLE users (id INT);`)
+	assert.True(t, errors.Is(err, ErrTableAlreadyExists), err)
+
+	// Defaults keep their quotes, and their errors say where they are in the statement
+	_, err = db.Exec(`CREATE TABLE quotes (id INT, said TEXT DEFAULT 'say "hi"', other TEXT DEFAULT "it's");
+		INSERT INTO quotes (id) VALUES (1);`)
+	assert.Nil(t, err)
+	rows, err = queryAll(t, db, `SELECT said, other FROM quotes;`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]any{{`say "hi"`, "it's"}}, rows)
+
+	_, err = db.Exec(`CREATE TABLE bad2 (n INT DEFAULT n);`)
+	assert.True(t, errors.Is(err, ErrColumnDoesNotExist), err)
+	assert.ErrorContains(t, err, "at 0:33")
+}
+
+func TestDB_disk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.db")
+
+	backend, err := OpenDiskBackend(path)
+	assert.Nil(t, err)
+	db := OpenBackend(backend)
+	_, err = db.Exec(`CREATE TABLE users (id INT, name VARCHAR(10) DEFAULT 'x' || '"y"'); INSERT INTO users VALUES (1, 'a');`)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	backend, err = OpenDiskBackend(path)
+	assert.Nil(t, err)
+	db = OpenBackend(backend)
+	defer db.Close()
+
+	// The default survived reopening
+	_, err = db.Exec(`INSERT INTO users (id) VALUES (2);`)
+	assert.Nil(t, err)
+
+	var id int
+	var name string
+	rows, err := db.Query(`SELECT id, name FROM users;`)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	assert.Nil(t, rows.Scan(&id, &name))
+	assert.Equal(t, 1, id)
+	assert.True(t, rows.Next())
+	assert.Nil(t, rows.Scan(&id, &name))
+	assert.Equal(t, `x"y"`, name)
+	assert.Nil(t, rows.Close())
+}
+
+func TestDB_cancel(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`CREATE TABLE numbers (n INT);`)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec(`INSERT INTO numbers VALUES ($1);`, i)
+		assert.Nil(t, err)
+	}
+
+	// Canceling stops a query midway through its rows
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := db.QueryContext(ctx, `SELECT n FROM numbers;`)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	assert.True(t, rows.Next())
+	cancel()
+	assert.False(t, rows.Next())
+	assert.True(t, errors.Is(rows.Err(), ErrQueryCanceled))
+	assert.True(t, errors.Is(rows.Err(), context.Canceled))
+
+	// Statements that didn't start yet don't run at all
+	_, err = db.ExecContext(ctx, `INSERT INTO numbers VALUES (100);`)
+	assert.True(t, errors.Is(err, ErrQueryCanceled))
+
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err = db.QueryContext(ctx, `SELECT n FROM numbers;`)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	rows, err = db.Query(`SELECT n FROM numbers;`)
+	assert.Nil(t, err)
+	all := collect(t, rows)
+	assert.Equal(t, 100, len(all))
+}
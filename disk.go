@@ -0,0 +1,828 @@
+package gosql
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*
+Our disk backend keeps the same tables as the memory backend but in a single database file. The first page of
+the file is a header, the second one is the root of the catalog, a B+tree with the definition of every table,
+including the root page of the B+tree holding its rows.
+
+Each statement is committed to a write-ahead log next to the database file before returning, see wal.go. Once
+the log grows past checkpointSize it's folded back into the database file.
+*/
+
+const (
+	diskMagic       = "gosqldb\x00"
+	diskVersion     = 1
+	metaPage        = pageID(0)
+	catalogRootPage = pageID(1)
+
+	defaultBufferPoolSize = 256
+	defaultCheckpointSize = 4 << 20
+)
+
+var ErrNotADatabase = errors.New("File is not a gosql database")
+
+type diskTable struct {
+	columns         []string
+	columnTypes     []ColumnType
+	columnModifiers []typeModifier
+	columnDefaults  []*expression
+	rows            *btree
+	nextRowID       uint64
+}
+
+type DiskBackend struct {
+	pager          *pager
+	pool           *bufferPool
+	wal            *wal
+	checkpointSize int64
+	catalog        *btree
+	nextCatalogID  uint64
+	tables         map[string]*diskTable
+	// Collected by ANALYZE, they aren't saved to the file
+	stats map[string]*tableStats
+}
+
+// OpenDiskBackend opens the database file at path, creating it if it doesn't exist. The write-ahead log lives in
+// the same directory with a -wal suffix, and whatever was committed to it before a crash is recovered here.
+func OpenDiskBackend(path string) (*DiskBackend, error) {
+	p, err := openPager(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := openWAL(path + "-wal")
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+
+	db := &DiskBackend{
+		pager:          p,
+		wal:            w,
+		checkpointSize: defaultCheckpointSize,
+		tables:         map[string]*diskTable{},
+		stats:          map[string]*tableStats{},
+	}
+
+	err = db.recover()
+	if err == nil {
+		db.pool = newBufferPool(p, defaultBufferPoolSize)
+		db.pool.wal = w
+		if p.numPages == 0 {
+			err = db.initialize()
+		} else {
+			err = db.load()
+		}
+	}
+	if err != nil {
+		w.close()
+		p.close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// recover writes back every page committed to the log since the last checkpoint
+func (db *DiskBackend) recover() error {
+	err := db.wal.replay(func(id pageID, data *[pageSize]byte) error {
+		if uint32(id) >= db.pager.numPages {
+			db.pager.numPages = uint32(id) + 1
+		}
+		return db.pager.write(id, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := db.pager.sync(); err != nil {
+		return err
+	}
+	return db.wal.reset()
+}
+
+func (db *DiskBackend) initialize() error {
+	err := db.transaction(func() error {
+		meta, err := db.pool.allocate()
+		if err != nil {
+			return err
+		}
+		copy(meta.data[:], diskMagic)
+		binary.BigEndian.PutUint16(meta.data[len(diskMagic):], diskVersion)
+		db.pool.unpin(meta)
+
+		db.catalog, err = createBtree(db.pool)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return db.Checkpoint()
+}
+
+func (db *DiskBackend) load() error {
+	meta, err := db.pool.fetch(metaPage)
+	if err != nil {
+		return err
+	}
+	magic := string(meta.data[:len(diskMagic)])
+	version := binary.BigEndian.Uint16(meta.data[len(diskMagic):])
+	db.pool.unpin(meta)
+
+	if magic != diskMagic {
+		return ErrNotADatabase
+	}
+	if version != diskVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrNotADatabase, version)
+	}
+
+	db.catalog = &btree{pool: db.pool, root: catalogRootPage}
+	cur, err := db.catalog.first()
+	if err != nil {
+		return err
+	}
+	for {
+		id, value, ok, err := cur.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		db.nextCatalogID = id + 1
+
+		name, t, err := decodeTableDefinition(value)
+		if err != nil {
+			return err
+		}
+		t.rows.pool = db.pool
+
+		maxRowID, ok, err := t.rows.maxKey()
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.nextRowID = maxRowID + 1
+		}
+		db.tables[name] = t
+	}
+	return nil
+}
+
+// transaction runs fn and commits every page it changed to the log, or undoes them all if it fails
+func (db *DiskBackend) transaction(fn func() error) error {
+	// Checkpoint before rather than after, so a failed checkpoint never reports a committed statement as failed
+	if db.wal.size >= db.checkpointSize {
+		if err := db.Checkpoint(); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(); err != nil {
+		db.pool.rollback()
+		return err
+	}
+
+	if err := db.pool.commit(); err != nil {
+		db.pool.rollback()
+		return err
+	}
+	return nil
+}
+
+// Checkpoint writes every committed page to the database file and empties the log
+func (db *DiskBackend) Checkpoint() error {
+	if err := db.pool.flush(); err != nil {
+		return err
+	}
+	return db.wal.reset()
+}
+
+// Close checkpoints and closes both the database file and the log
+func (db *DiskBackend) Close() error {
+	err := db.Checkpoint()
+	if werr := db.wal.close(); err == nil {
+		err = werr
+	}
+	if perr := db.pager.close(); err == nil {
+		err = perr
+	}
+	return err
+}
+
+func (db *DiskBackend) CreateTable(ctx context.Context, crt *CreateTableStatement) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := db.tables[crt.name.value]; ok {
+		return ErrTableAlreadyExists
+	}
+
+	t := &diskTable{}
+	for _, col := range crt.cols {
+		dt, mod, err := columnTypeFromDefinition(col)
+		if err != nil {
+			return err
+		}
+		def, err := columnDefault(col, dt, mod)
+		if err != nil {
+			return err
+		}
+		t.columns = append(t.columns, col.name.value)
+		t.columnTypes = append(t.columnTypes, dt)
+		t.columnModifiers = append(t.columnModifiers, mod)
+		t.columnDefaults = append(t.columnDefaults, def)
+	}
+
+	err := db.transaction(func() error {
+		rows, err := createBtree(db.pool)
+		if err != nil {
+			return err
+		}
+		t.rows = rows
+
+		return db.catalog.insert(db.nextCatalogID, encodeTableDefinition(crt.name.value, t))
+	})
+	if err != nil {
+		return err
+	}
+	db.nextCatalogID++
+	db.tables[crt.name.value] = t
+	return nil
+}
+
+func (db *DiskBackend) Insert(ctx context.Context, inst *InsertStatement) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+
+	t, ok := db.tables[inst.table.value]
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+	if inst.values == nil {
+		return nil
+	}
+
+	row, err := insertedRow(inst, t.columns, t.columnTypes, t.columnModifiers, t.columnDefaults)
+	if err != nil {
+		return err
+	}
+
+	err = db.transaction(func() error {
+		return t.rows.insert(t.nextRowID, encodeRow(row))
+	})
+	if err != nil {
+		return err
+	}
+	t.nextRowID++
+	return nil
+}
+
+func (db *DiskBackend) Select(ctx context.Context, slct *SelectStatement) (*Rows, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+
+	op, err := planSelect(ctx, slct, db)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(op), nil
+}
+
+func (db *DiskBackend) Explain(ctx context.Context, expl *ExplainStatement) (*Rows, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return explain(ctx, expl, db)
+}
+
+// CreateIndex fails, indexes are only kept by the memory backend for now
+func (db *DiskBackend) CreateIndex(ctx context.Context, ci *CreateIndexStatement) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+	if _, ok := db.tables[ci.table.value]; !ok {
+		return ErrTableDoesNotExist
+	}
+	return fmt.Errorf("%w: indexes on disk tables", ErrNotSupported)
+}
+
+func (db *DiskBackend) Analyze(ctx context.Context, anlz *AnalyzeStatement) error {
+	names := []string{}
+	if anlz.table != nil {
+		if _, ok := db.tables[anlz.table.value]; !ok {
+			return ErrTableDoesNotExist
+		}
+		names = append(names, anlz.table.value)
+	} else {
+		for name := range db.tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		stats, err := analyzeTable(ctx, db, name)
+		if err != nil {
+			return err
+		}
+		db.stats[name] = stats
+	}
+	return nil
+}
+
+func (db *DiskBackend) tableStats(name string) *tableStats {
+	return db.stats[name]
+}
+
+func (db *DiskBackend) tableIndexes(string) []indexInfo {
+	return nil
+}
+
+func (db *DiskBackend) scanIndex(context.Context, string, string, indexRange, []int) (operator, error) {
+	return nil, fmt.Errorf("%w: indexes on disk tables", ErrNotSupported)
+}
+
+func (db *DiskBackend) tableColumns(name string) ([]resultColumn, error) {
+	t, ok := db.tables[name]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+
+	cols := []resultColumn{}
+	for i, name := range t.columns {
+		cols = append(cols, resultColumn{name: name, typ: valueType(t.columnTypes[i]), char: t.columnTypes[i] == CharType})
+	}
+	return cols, nil
+}
+
+func (db *DiskBackend) scanTable(ctx context.Context, name string, positions []int) (operator, error) {
+	t, ok := db.tables[name]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+	cols, err := db.tableColumns(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := t.rows.first()
+	if err != nil {
+		return nil, err
+	}
+	return &diskScan{ctx: ctx, table: name, cols: pickColumns(cols, positions), positions: positions, cursor: cur}, nil
+}
+
+// diskScan walks the leaves of a table decoding one row at a time
+type diskScan struct {
+	ctx       context.Context
+	table     string
+	cols      []resultColumn
+	positions []int
+	cursor    *btreeCursor
+}
+
+func (s *diskScan) columns() []resultColumn {
+	return s.cols
+}
+
+func (s *diskScan) next() ([]MemoryCell, bool, error) {
+	if err := canceled(s.ctx); err != nil {
+		return nil, false, err
+	}
+	_, value, ok, err := s.cursor.next()
+	if !ok || err != nil {
+		return nil, false, err
+	}
+
+	row, err := decodeRow(value)
+	if err != nil {
+		return nil, false, err
+	}
+	return pickColumns(row, s.positions), true, nil
+}
+
+func (s *diskScan) close() error {
+	return nil
+}
+
+func (s *diskScan) describe() string {
+	return "Seq Scan on " + s.table
+}
+
+func (s *diskScan) children() []operator {
+	return nil
+}
+
+/*
+Encoding
+--------
+Rows are a cell count followed by every cell prefixed with its length, NULL cells have a length of 0xFFFFFFFF.
+Table definitions are the table name, the root page of its rows and the name and type of every column.
+*/
+
+const nullCellLength = ^uint32(0)
+
+func encodeRow(row []MemoryCell) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, uint16(len(row)))
+	for _, cell := range row {
+		if cell == nil {
+			binary.Write(buf, binary.BigEndian, nullCellLength)
+			continue
+		}
+		binary.Write(buf, binary.BigEndian, uint32(len(cell)))
+		buf.Write(cell)
+	}
+	return buf.Bytes()
+}
+
+func decodeRow(data []byte) ([]MemoryCell, error) {
+	r := bytes.NewReader(data)
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+
+	row := make([]MemoryCell, 0, count)
+	for i := 0; i < int(count); i++ {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length == nullCellLength {
+			row = append(row, nil)
+			continue
+		}
+		if int64(length) > int64(r.Len()) {
+			return nil, ErrInvalidCell
+		}
+
+		cell := make(MemoryCell, length)
+		r.Read(cell)
+		row = append(row, cell)
+	}
+	return row, nil
+}
+
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.BigEndian, uint16(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func readString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	s := make([]byte, length)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+func encodeTableDefinition(name string, t *diskTable) []byte {
+	buf := new(bytes.Buffer)
+	writeString(buf, name)
+	binary.Write(buf, binary.BigEndian, uint32(t.rows.root))
+	binary.Write(buf, binary.BigEndian, uint16(len(t.columns)))
+	for i, col := range t.columns {
+		writeColumn(buf, col, t.columnTypes[i], t.columnModifiers[i], t.columnDefaults[i])
+	}
+	return buf.Bytes()
+}
+
+func decodeTableDefinition(data []byte) (string, *diskTable, error) {
+	r := bytes.NewReader(data)
+	name, err := readString(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var root uint32
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &root); err != nil {
+		return "", nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return "", nil, err
+	}
+
+	t := &diskTable{rows: &btree{root: pageID(root)}}
+	for i := 0; i < int(count); i++ {
+		col, dt, mod, def, err := readColumn(r)
+		if err != nil {
+			return "", nil, err
+		}
+		t.columns = append(t.columns, col)
+		t.columnTypes = append(t.columnTypes, dt)
+		t.columnModifiers = append(t.columnModifiers, mod)
+		t.columnDefaults = append(t.columnDefaults, def)
+	}
+	return name, t, nil
+}
+
+// columnDefaultFlag is set in the type byte of columns with a default, which is written after the modifier, see
+// writeExpression. Types don't use that bit, so files written before defaults existed read the same.
+const columnDefaultFlag = 0x80
+
+func writeColumn(w io.Writer, name string, typ ColumnType, mod typeModifier, def *expression) error {
+	if err := writeString(w, name); err != nil {
+		return err
+	}
+	flags := byte(typ)
+	if def != nil {
+		flags |= columnDefaultFlag
+	}
+	if _, err := w.Write([]byte{flags}); err != nil {
+		return err
+	}
+	if err := writeTypeModifier(w, typ, mod); err != nil {
+		return err
+	}
+	if def != nil {
+		return writeExpression(w, def)
+	}
+	return nil
+}
+
+func readColumn(r io.Reader) (string, ColumnType, typeModifier, *expression, error) {
+	name, err := readString(r)
+	if err != nil {
+		return "", 0, typeModifier{}, nil, err
+	}
+	var flags [1]byte
+	if _, err := io.ReadFull(r, flags[:]); err != nil {
+		return "", 0, typeModifier{}, nil, err
+	}
+	typ := ColumnType(flags[0] &^ columnDefaultFlag)
+	mod, err := readTypeModifier(r, typ)
+	if err != nil {
+		return "", 0, typeModifier{}, nil, err
+	}
+	if flags[0]&columnDefaultFlag == 0 {
+		return name, typ, mod, nil, nil
+	}
+
+	def, err := readExpression(r)
+	if err != nil {
+		return "", 0, typeModifier{}, nil, err
+	}
+	return name, typ, mod, def, nil
+}
+
+// writeExpression writes an expression as its tree: its kind, then its tokens as their kind, value and location and
+// the expressions in it, with the number of them before lists. Values are written as they are, so unlike the code
+// of an expression nothing in them needs escaping.
+func writeExpression(w io.Writer, e *expression) error {
+	if _, err := w.Write([]byte{byte(e.kind)}); err != nil {
+		return err
+	}
+	switch e.kind {
+	case literalKind:
+		return writeToken(w, *e.literal)
+	case binaryKind:
+		if err := writeToken(w, e.binary.op); err != nil {
+			return err
+		}
+		return writeExpressions(w, e.binary.a, e.binary.b)
+	case callKind:
+		if err := writeToken(w, e.call.name); err != nil {
+			return err
+		}
+		if err := writeBool(w, e.call.star); err != nil {
+			return err
+		}
+		return writeExpressionList(w, e.call.args)
+	case castKind:
+		if err := writeToken(w, e.cast.datatype); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint16(len(e.cast.modifiers))); err != nil {
+			return err
+		}
+		for _, modifier := range e.cast.modifiers {
+			if err := writeToken(w, modifier); err != nil {
+				return err
+			}
+		}
+		if err := writeBool(w, e.cast.array); err != nil {
+			return err
+		}
+		return writeExpression(w, e.cast.exp)
+	case arrayKind:
+		if err := writeLocation(w, e.array.loc); err != nil {
+			return err
+		}
+		return writeExpressionList(w, e.array.elements)
+	case subscriptKind:
+		return writeExpressions(w, e.subscript.exp, e.subscript.index)
+	}
+	return fmt.Errorf("unknown expression kind %d", e.kind)
+}
+
+func writeExpressions(w io.Writer, exps ...*expression) error {
+	for _, e := range exps {
+		if err := writeExpression(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExpressionList(w io.Writer, exps []*expression) error {
+	if err := binary.Write(w, binary.BigEndian, uint16(len(exps))); err != nil {
+		return err
+	}
+	return writeExpressions(w, exps...)
+}
+
+func writeToken(w io.Writer, t token) error {
+	if _, err := w.Write([]byte{byte(t.kind)}); err != nil {
+		return err
+	}
+	if err := writeString(w, t.value); err != nil {
+		return err
+	}
+	return writeLocation(w, t.loc)
+}
+
+func writeLocation(w io.Writer, loc location) error {
+	return binary.Write(w, binary.BigEndian, [2]uint32{uint32(loc.line), uint32(loc.col)})
+}
+
+func writeBool(w io.Writer, b bool) error {
+	flag := byte(0)
+	if b {
+		flag = 1
+	}
+	_, err := w.Write([]byte{flag})
+	return err
+}
+
+// readExpression reads an expression written by writeExpression
+func readExpression(r io.Reader) (*expression, error) {
+	var kind [1]byte
+	if _, err := io.ReadFull(r, kind[:]); err != nil {
+		return nil, err
+	}
+	e := &expression{kind: expressionKind(kind[0])}
+	var err error
+	switch e.kind {
+	case literalKind:
+		var t token
+		t, err = readToken(r)
+		e.literal = &t
+	case binaryKind:
+		e.binary = &binaryExpression{}
+		if e.binary.op, err = readToken(r); err != nil {
+			return nil, err
+		}
+		if e.binary.a, err = readExpression(r); err != nil {
+			return nil, err
+		}
+		e.binary.b, err = readExpression(r)
+	case callKind:
+		e.call = &callExpression{}
+		if e.call.name, err = readToken(r); err != nil {
+			return nil, err
+		}
+		if e.call.star, err = readBool(r); err != nil {
+			return nil, err
+		}
+		e.call.args, err = readExpressionList(r)
+	case castKind:
+		e.cast = &castExpression{}
+		if e.cast.datatype, err = readToken(r); err != nil {
+			return nil, err
+		}
+		var count uint16
+		if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(count); i++ {
+			modifier, err := readToken(r)
+			if err != nil {
+				return nil, err
+			}
+			e.cast.modifiers = append(e.cast.modifiers, modifier)
+		}
+		if e.cast.array, err = readBool(r); err != nil {
+			return nil, err
+		}
+		e.cast.exp, err = readExpression(r)
+	case arrayKind:
+		e.array = &arrayExpression{}
+		if e.array.loc, err = readLocation(r); err != nil {
+			return nil, err
+		}
+		e.array.elements, err = readExpressionList(r)
+	case subscriptKind:
+		e.subscript = &subscriptExpression{}
+		if e.subscript.exp, err = readExpression(r); err != nil {
+			return nil, err
+		}
+		e.subscript.index, err = readExpression(r)
+	default:
+		return nil, fmt.Errorf("unknown expression kind %d", e.kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func readExpressionList(r io.Reader) ([]*expression, error) {
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	exps := []*expression{}
+	for i := 0; i < int(count); i++ {
+		e, err := readExpression(r)
+		if err != nil {
+			return nil, err
+		}
+		exps = append(exps, e)
+	}
+	return exps, nil
+}
+
+func readToken(r io.Reader) (token, error) {
+	var kind [1]byte
+	if _, err := io.ReadFull(r, kind[:]); err != nil {
+		return token{}, err
+	}
+	value, err := readString(r)
+	if err != nil {
+		return token{}, err
+	}
+	loc, err := readLocation(r)
+	if err != nil {
+		return token{}, err
+	}
+	return token{value: value, kind: tokenKind(kind[0]), loc: loc}, nil
+}
+
+func readLocation(r io.Reader) (location, error) {
+	var loc [2]uint32
+	if err := binary.Read(r, binary.BigEndian, &loc); err != nil {
+		return location{}, err
+	}
+	return location{line: uint(loc[0]), col: uint(loc[1])}, nil
+}
+
+func readBool(r io.Reader) (bool, error) {
+	var flag [1]byte
+	if _, err := io.ReadFull(r, flag[:]); err != nil {
+		return false, err
+	}
+	return flag[0] != 0, nil
+}
+
+// writeTypeModifier writes the precision and scale of a NUMERIC column, or the length of a VARCHAR or CHAR one,
+// arrays of them having those of their elements.
+// Columns of other types have nothing written, so files written before modifiers existed read the same.
+func writeTypeModifier(w io.Writer, typ ColumnType, mod typeModifier) error {
+	switch elementType(typ) {
+	case NumericType:
+		return binary.Write(w, binary.BigEndian, [2]uint16{uint16(mod.precision), uint16(mod.scale)})
+	case VarcharType, CharType:
+		return binary.Write(w, binary.BigEndian, uint32(mod.length))
+	}
+	return nil
+}
+
+func readTypeModifier(r io.Reader, typ ColumnType) (typeModifier, error) {
+	switch elementType(typ) {
+	case NumericType:
+		var numbers [2]uint16
+		if err := binary.Read(r, binary.BigEndian, &numbers); err != nil {
+			return typeModifier{}, err
+		}
+		return typeModifier{precision: int(numbers[0]), scale: int(numbers[1])}, nil
+	case VarcharType, CharType:
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return typeModifier{}, err
+		}
+		return typeModifier{length: int(length)}, nil
+	}
+	return typeModifier{}, nil
+}
@@ -0,0 +1,166 @@
+package gosql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func execute(t *testing.T, b Backend, source string) *Rows {
+	ast, err := Parse(source)
+	assert.Nil(t, err, source)
+
+	_, rows, err := runStatements(context.Background(), b, ast)
+	assert.Nil(t, err, source)
+	return rows
+}
+
+// collect reads every remaining row and closes rows
+func collect(t *testing.T, rows *Rows) [][]Cell {
+	var all [][]Cell
+	for rows.Next() {
+		all = append(all, rows.Row())
+	}
+	assert.Nil(t, rows.Err())
+	assert.Nil(t, rows.Close())
+	return all
+}
+
+func TestBtree_insert(t *testing.T) {
+	p, err := openPager(filepath.Join(t.TempDir(), "btree.db"))
+	assert.Nil(t, err)
+	defer p.close()
+
+	// A tiny pool forces pages to be evicted and read back while the tree grows
+	pool := newBufferPool(p, 16)
+	tree, err := createBtree(pool)
+	assert.Nil(t, err)
+
+	keys := rand.Perm(5000)
+	for _, k := range keys {
+		assert.Nil(t, tree.insert(uint64(k), []byte(fmt.Sprintf("value %d", k))))
+	}
+
+	cur, err := tree.first()
+	assert.Nil(t, err)
+	for i := 0; i < len(keys); i++ {
+		key, value, ok, err := cur.next()
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(i), key)
+		assert.Equal(t, fmt.Sprintf("value %d", i), string(value))
+	}
+	_, _, ok, err := cur.next()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	max, ok, err := tree.maxKey()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(len(keys)-1), max)
+
+	// Values bigger than a leaf can hold go to overflow pages, replacing the small value under the same key
+	big := bytes.Repeat([]byte("0123456789"), 3*pageSize/10)
+	assert.Nil(t, tree.insert(0, big))
+	assert.Nil(t, tree.insert(uint64(len(keys)), make([]byte, overflowCapacity)))
+	cur, err = tree.first()
+	assert.Nil(t, err)
+	_, value, _, err := cur.next()
+	assert.Nil(t, err)
+	assert.Equal(t, big, value)
+	for i := 1; i < len(keys); i++ {
+		_, _, _, err = cur.next()
+		assert.Nil(t, err)
+	}
+	key, value, _, err := cur.next()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(len(keys)), key)
+	assert.Equal(t, make([]byte, overflowCapacity), value)
+
+	err = tree.insert(0, make([]byte, maxRowSize+1))
+	assert.True(t, errors.Is(err, ErrRowTooLarge), err)
+}
+
+func TestDiskBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := OpenDiskBackend(path)
+	assert.Nil(t, err)
+	execute(t, db, `CREATE TABLE users (id INT, name TEXT);`)
+	execute(t, db, `CREATE TABLE accounts (balance NUMERIC(6, 2), code CHAR(3), tags CHAR(2)[]);`)
+	for i := 0; i < 2000; i++ {
+		execute(t, db, fmt.Sprintf(`INSERT INTO users VALUES (%d, "user %d");`, i, i))
+	}
+	assert.Nil(t, db.Close())
+
+	db, err = OpenDiskBackend(path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	assert.Equal(t, ErrTableAlreadyExists, db.CreateTable(context.Background(), &CreateTableStatement{name: token{value: "users"}}))
+	execute(t, db, `INSERT INTO users VALUES (2000, "user 2000");`)
+
+	rows := execute(t, db, `SELECT name, id FROM users;`)
+	assert.Equal(t, []string{"name", "id"}, rows.Columns())
+	assert.Equal(t, []ColumnType{TextType, IntType}, rows.ColumnTypes())
+	all := collect(t, rows)
+	assert.Equal(t, 2001, len(all))
+	for i, row := range all {
+		assert.Equal(t, fmt.Sprintf("user %d", i), row[0].AsText())
+		assert.Equal(t, int32(i), row[1].AsInt())
+	}
+
+	// The scale and the length of the columns survived reopening, the length of array elements too
+	execute(t, db, `INSERT INTO accounts VALUES (1.005, 'ab', ARRAY['x', 'yz']);`)
+	all = collect(t, execute(t, db, `SELECT balance, code, tags FROM accounts;`))
+	assert.Equal(t, "1.01", all[0][0].AsNumeric().FloatString(2))
+	assert.Equal(t, "ab ", all[0][1].AsText())
+	assert.Equal(t, "{\"x \",yz}", arrayText(all[0][2].(MemoryCell)))
+	for _, insert := range []string{
+		`INSERT INTO accounts VALUES (1, 'abcd', NULL);`,
+		`INSERT INTO accounts VALUES (1, 'a', '{xyz}');`,
+	} {
+		ast, err := Parse(insert)
+		assert.Nil(t, err)
+		_, _, err = runStatements(context.Background(), db, ast)
+		assert.True(t, errors.Is(err, ErrValueTooLong), err)
+	}
+}
+
+func TestDiskBackend_largeRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large.db")
+	text := strings.Repeat("abcdefghij", 200)
+	payload := `{"items": [` + strings.Repeat(`{"name": "item"}, `, 300) + `{"name": "last"}]}`
+	data := bytes.Repeat([]byte{0xDE, 0xAD, 0xBE, 0xEF}, 5000)
+
+	// The default alone doesn't fit in a leaf of the catalog
+	db, err := OpenDiskBackend(path)
+	assert.Nil(t, err)
+	execute(t, db, fmt.Sprintf(`CREATE TABLE docs (id INT, body TEXT DEFAULT '%s', payload JSON, data BYTEA);`, text))
+	execute(t, db, fmt.Sprintf(`INSERT INTO docs VALUES (1, '%s', '%s', X'%X');`, text, payload, data))
+	execute(t, db, `INSERT INTO docs (id) VALUES (2);`)
+	assert.Nil(t, db.Close())
+
+	db, err = OpenDiskBackend(path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	all := collect(t, execute(t, db, `SELECT id, body, payload ->> 'items', data FROM docs;`))
+	assert.Equal(t, 2, len(all))
+	assert.Equal(t, text, all[0][1].AsText())
+	assert.Equal(t, 301, strings.Count(all[0][2].AsText(), "name"))
+	assert.Equal(t, data, all[0][3].AsBytes())
+	assert.Equal(t, text, all[1][1].AsText())
+
+	ast, err := Parse(fmt.Sprintf(`INSERT INTO docs VALUES (3, '%s', NULL, NULL);`, strings.Repeat("x", maxRowSize)))
+	assert.Nil(t, err)
+	_, _, err = runStatements(context.Background(), db, ast)
+	assert.True(t, errors.Is(err, ErrRowTooLarge), err)
+}
@@ -0,0 +1,280 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+database/sql Driver
+-------------------
+The driver registers itself as "gosql" and serves named in-memory databases, every connection opened with the
+same "mem://name" data source shares the same tables for as long as the process lives:
+
+	db, err := sql.Open("gosql", "mem://users")
+
+Statements are prepared, so they take $1, ? or :name parameters, see prepare.go. They run one at a time per
+database, waiting for each other until their context is done. A transaction holds the database for itself until
+it commits or rolls back, and rolling back restores a copy of the tables taken when it began. Statements from
+other connections don't wait for a transaction, which could be waiting for them, they fail with
+ErrDatabaseLocked.
+*/
+
+const memoryScheme = "mem://"
+
+var ErrInvalidDSN = errors.New("Invalid data source name")
+
+func init() {
+	sql.Register("gosql", &Driver{})
+}
+
+type memoryDatabase struct {
+	// Taken by a statement while it runs and produces rows, or by a transaction until it ends
+	sem chan struct{}
+	// Set while a transaction holds sem
+	inTransaction atomic.Bool
+	backend       *MemoryBackend
+}
+
+// lock takes the database once it's free, failing when ctx is done first or right away when a transaction holds
+// it, see the comment above
+func (db *memoryDatabase) lock(ctx context.Context) error {
+	if db.inTransaction.Load() {
+		return ErrDatabaseLocked
+	}
+	select {
+	case db.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return canceled(ctx)
+	}
+}
+
+func (db *memoryDatabase) unlock() {
+	<-db.sem
+}
+
+// databaseLocker takes the database for the rows of a query, with the context of the query
+type databaseLocker struct {
+	db  *memoryDatabase
+	ctx context.Context
+}
+
+func (l databaseLocker) lock() error {
+	return l.db.lock(l.ctx)
+}
+
+func (l databaseLocker) unlock() {
+	l.db.unlock()
+}
+
+var (
+	memoryDatabasesMu sync.Mutex
+	memoryDatabases   = map[string]*memoryDatabase{}
+)
+
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	if !strings.HasPrefix(dsn, memoryScheme) {
+		return nil, fmt.Errorf("%w: %q, expected %sname", ErrInvalidDSN, dsn, memoryScheme)
+	}
+	name := strings.TrimPrefix(dsn, memoryScheme)
+
+	memoryDatabasesMu.Lock()
+	defer memoryDatabasesMu.Unlock()
+
+	db, ok := memoryDatabases[name]
+	if !ok {
+		db = &memoryDatabase{sem: make(chan struct{}, 1), backend: NewMemoryBackend()}
+		memoryDatabases[name] = db
+	}
+	return &conn{db: db}, nil
+}
+
+type conn struct {
+	db *memoryDatabase
+	tx *tx
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	prepared, err := PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{conn: c, prepared: prepared}, nil
+}
+
+func (c *conn) Close() error {
+	if c.tx != nil {
+		return c.tx.Rollback()
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("transaction already in progress")
+	}
+
+	if err := c.db.lock(ctx); err != nil {
+		return nil, err
+	}
+	c.db.inTransaction.Store(true)
+	c.tx = &tx{conn: c, tables: c.db.backend.copyTables()}
+	return c.tx, nil
+}
+
+// run binds the arguments and executes the statement, returning the number of inserted rows and the rows of the
+// last select
+func (c *conn) run(ctx context.Context, prepared *Stmt, args []driver.NamedValue) (int64, *Rows, error) {
+	values := []any{}
+	for _, arg := range args {
+		if arg.Name != "" {
+			values = append(values, sql.Named(arg.Name, arg.Value))
+			continue
+		}
+		values = append(values, arg.Value)
+	}
+
+	ast, err := prepared.Bind(values...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Inside a transaction the database is already ours
+	if c.tx != nil {
+		return runStatements(ctx, c.db.backend, ast)
+	}
+
+	if err := c.db.lock(ctx); err != nil {
+		return 0, nil, err
+	}
+	affected, rows, err := runStatements(ctx, c.db.backend, ast)
+	c.db.unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+	rows.locker = databaseLocker{db: c.db, ctx: ctx}
+	return affected, rows, nil
+}
+
+type tx struct {
+	conn *conn
+	// The tables as they were when the transaction began
+	tables map[string]*table
+}
+
+func (t *tx) Commit() error {
+	t.end()
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.conn.db.backend.tables = t.tables
+	t.end()
+	return nil
+}
+
+func (t *tx) end() {
+	t.conn.tx = nil
+	t.conn.db.inTransaction.Store(false)
+	t.conn.db.unlock()
+}
+
+type stmt struct {
+	conn     *conn
+	prepared *Stmt
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.prepared.NumInput()
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := []driver.NamedValue{}
+	for i, arg := range args {
+		named = append(named, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+	return named
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+// ExecContext and QueryContext are what let database/sql pass sql.Named arguments and deadlines through
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	affected, rows, err := s.conn.run(ctx, s.prepared, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(affected), rows.Close()
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	_, r, err := s.conn.run(ctx, s.prepared, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: r}, nil
+}
+
+type rows struct {
+	rows *Rows
+}
+
+func (r *rows) Columns() []string {
+	return r.rows.Columns()
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.rows.ColumnTypes()[index].String()
+}
+
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
+
+// Next maps every cell to the Go value matching the type of its column
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	types := r.rows.ColumnTypes()
+	for i, cell := range r.rows.row {
+		value, err := cellValue(cell, types[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = value
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriver(t *testing.T) {
+	db, err := sql.Open("gosql", "mem://driver")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (id INT, name TEXT);`)
+	assert.Nil(t, err)
+
+	res, err := db.Exec(`INSERT INTO users VALUES (1, "Carlos"); INSERT INTO users VALUES (2, "Ana");`)
+	assert.Nil(t, err)
+	affected, err := res.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	// Rolled back inserts are gone, committed ones stay
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec(`INSERT INTO users VALUES (3, "Rolled back");`)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+
+	tx, err = db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec(`INSERT INTO users VALUES (4, "Committed");`)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+
+	// A second handle on the same name sees the same tables
+	other, err := sql.Open("gosql", "mem://driver")
+	assert.Nil(t, err)
+	defer other.Close()
+
+	rows, err := other.Query(`SELECT id, name FROM users;`)
+	assert.Nil(t, err)
+	columns, err := rows.Columns()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "name"}, columns)
+	types, err := rows.ColumnTypes()
+	assert.Nil(t, err)
+	assert.Equal(t, "INT", types[0].DatabaseTypeName())
+
+	ids := []int{}
+	names := []string{}
+	for rows.Next() {
+		var id int
+		var name string
+		assert.Nil(t, rows.Scan(&id, &name))
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []int{1, 2, 4}, ids)
+	assert.Equal(t, []string{"Carlos", "Ana", "Committed"}, names)
+
+	var name string
+	assert.Nil(t, db.QueryRow(`SELECT name FROM users;`).Scan(&name))
+	assert.Equal(t, "Carlos", name)
+
+	// Placeholders, both positional and named
+	_, err = db.Exec(`INSERT INTO users VALUES ($1, $2);`, 5, "Luis")
+	assert.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO users VALUES (:id, :name);`, sql.Named("id", 6), sql.Named("name", "Eva"))
+	assert.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO users VALUES (?, ?);`, 7)
+	assert.NotNil(t, err)
+
+	var count int
+	rows, err = db.Query(`SELECT id FROM users;`)
+	assert.Nil(t, err)
+	for rows.Next() {
+		count++
+	}
+	assert.Equal(t, 5, count)
+
+	_, err = db.Exec(`CREATE TABLE flags (id INT, active BOOLEAN); INSERT INTO flags VALUES ($1, $2);`, 1, true)
+	assert.Nil(t, err)
+	var active int
+	assert.Nil(t, db.QueryRow(`SELECT id FROM flags WHERE active = $1;`, true).Scan(&active))
+	assert.Equal(t, 1, active)
+
+	_, err = db.Exec(`SELECT id FROM missing;`)
+	assert.Equal(t, ErrTableDoesNotExist, err)
+
+	bad, err := sql.Open("gosql", "users.db")
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(bad.Ping(), ErrInvalidDSN))
+}
+
+func TestDriver_locking(t *testing.T) {
+	db, err := sql.Open("gosql", "mem://locking")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (id INT); INSERT INTO users VALUES (1); INSERT INTO users VALUES (2);`)
+	assert.Nil(t, err)
+	rows, err := db.Query(`SELECT id FROM users;`)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+
+	// Other connections fail instead of waiting for a transaction, rows being read too
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec(`CREATE INDEX users_id ON users (id); INSERT INTO users VALUES (3);`)
+	assert.Nil(t, err)
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM users;`).Scan(&count)
+	assert.True(t, errors.Is(err, ErrDatabaseLocked), err)
+	_, err = db.Begin()
+	assert.True(t, errors.Is(err, ErrDatabaseLocked), err)
+	assert.False(t, rows.Next())
+	assert.True(t, errors.Is(rows.Err(), ErrDatabaseLocked), rows.Err())
+	assert.Nil(t, tx.QueryRow(`SELECT count(*) FROM users;`).Scan(&count))
+	assert.Equal(t, 3, count)
+
+	// Rolling back drops the rows and the index
+	assert.Nil(t, tx.Rollback())
+	assert.Nil(t, db.QueryRow(`SELECT count(*) FROM users;`).Scan(&count))
+	assert.Equal(t, 2, count)
+	_, err = db.Exec(`CREATE INDEX users_id ON users (id);`)
+	assert.Nil(t, err)
+
+	// Waiting for another statement stops once the context is done
+	memoryDatabases["locking"].sem <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = db.QueryContext(ctx, `SELECT id FROM users;`)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	<-memoryDatabases["locking"].sem
+	assert.Nil(t, db.QueryRow(`SELECT count(*) FROM users;`).Scan(&count))
+}
@@ -15,4 +15,18 @@ var (
 	ErrInvalidCell               = errors.New("Cell is invalid")
 	ErrInvalidOperands           = errors.New("Operands are invalid")
 	ErrPrimaryKeyAlreadyExists   = errors.New("Primary key already exists")
+	ErrMixedParameters           = errors.New("Positional and named parameters can't be mixed")
+	ErrWrongNumberOfArguments    = errors.New("Wrong number of arguments")
+	ErrMissingArgument           = errors.New("Missing argument for parameter")
+	ErrQueryCanceled             = errors.New("Query canceled")
+	ErrAmbiguousColumn           = errors.New("Column reference is ambiguous")
+	ErrFunctionDoesNotExist      = errors.New("Function does not exist")
+	ErrDivisionByZero            = errors.New("Division by zero")
+	ErrIntegerOutOfRange         = errors.New("Integer out of range")
+	ErrFloatOutOfRange           = errors.New("Float out of range")
+	ErrNumericOutOfRange         = errors.New("Numeric out of range")
+	ErrValueTooLong              = errors.New("Value too long for type")
+	ErrDuplicateColumn           = errors.New("Column specified more than once")
+	ErrNotSupported              = errors.New("Not supported")
+	ErrDatabaseLocked            = errors.New("Database is locked by a transaction")
 )
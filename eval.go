@@ -0,0 +1,684 @@
+package gosql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+Expressions
+-----------
+Before running, every expression is compiled against the columns of the rows it will see into a closure that
+evaluates it for one row. Compiling is where columns are resolved and operand types are checked, so evaluating
+only has to deal with the values.
+
+Any operation on NULL gives NULL, except for AND and OR which follow three-valued logic: false AND NULL is false
+and true OR NULL is true.
+
+Numbers of different types can be compared and combined, the narrower one is promoted first: a SMALLINT with an
+INT gives an INT, any integer with a NUMERIC gives a NUMERIC and two REALs give a REAL, but any integer or NUMERIC
+with any float or a REAL with a DOUBLE PRECISION give a DOUBLE PRECISION. Integer literals are INTs, or BIGINTs
+when they don't fit, and numeric literals with a decimal point or an exponent, like 1.5 or 2e3, are DOUBLE
+PRECISION. Integer arithmetic fails instead of wrapping around when the result doesn't fit its type.
+*/
+
+type evaluator func(row []MemoryCell) (MemoryCell, error)
+
+func intCell(i int32) MemoryCell {
+	return binary.BigEndian.AppendUint32(nil, uint32(i))
+}
+
+// integerCell encodes an integer with the width of its type, failing when it doesn't fit
+func integerCell(i int64, typ ColumnType) (MemoryCell, error) {
+	switch typ {
+	case SmallIntType:
+		if i < math.MinInt16 || i > math.MaxInt16 {
+			return nil, fmt.Errorf("%w: %d doesn't fit in SMALLINT", ErrIntegerOutOfRange, i)
+		}
+		return binary.BigEndian.AppendUint16(nil, uint16(i)), nil
+	case BigIntType:
+		return binary.BigEndian.AppendUint64(nil, uint64(i)), nil
+	}
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: %d doesn't fit in INT", ErrIntegerOutOfRange, i)
+	}
+	return intCell(int32(i)), nil
+}
+
+func parseInteger(s string) (int64, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%w: %s", ErrIntegerOutOfRange, s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s is not an integer", ErrInvalidDatatype, s)
+	}
+	return i, nil
+}
+
+// floatCell encodes a float for a REAL or DOUBLE PRECISION, failing when it doesn't fit
+func floatCell(f float64, typ ColumnType) (MemoryCell, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, ErrFloatOutOfRange
+	}
+	if typ == RealType {
+		if math.Abs(f) > math.MaxFloat32 {
+			return nil, ErrFloatOutOfRange
+		}
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))), nil
+	}
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)), nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%w: %s", ErrFloatOutOfRange, s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s is not a number", ErrInvalidDatatype, s)
+	}
+	return f, nil
+}
+
+func isInteger(typ ColumnType) bool {
+	return typ == SmallIntType || typ == IntType || typ == BigIntType
+}
+
+// isExact is true for the numbers that are never rounded, integers and NUMERICs
+func isExact(typ ColumnType) bool {
+	return isInteger(typ) || typ == NumericType
+}
+
+func isNumeric(typ ColumnType) bool {
+	return isExact(typ) || typ == RealType || typ == DoubleType
+}
+
+// numericRanks orders the exact numbers and the floats by width
+var numericRanks = map[ColumnType]int{
+	SmallIntType: 0,
+	IntType:      1,
+	BigIntType:   2,
+	NumericType:  3,
+	RealType:     0,
+	DoubleType:   1,
+}
+
+// promoteNumeric is the type two numbers are converted to before combining them
+func promoteNumeric(a, b ColumnType) ColumnType {
+	if isExact(a) != isExact(b) {
+		return DoubleType
+	}
+	if numericRanks[a] > numericRanks[b] {
+		return a
+	}
+	return b
+}
+
+// numericValue reads any number as a float64, which holds integers exactly up to 2^53
+func numericValue(cell MemoryCell, typ ColumnType) float64 {
+	switch {
+	case isInteger(typ):
+		return float64(cell.AsInt64())
+	case typ == NumericType:
+		return decodeNumeric(cell).float()
+	}
+	return cell.AsFloat()
+}
+
+// converts is true when values of one type can be converted to another without losing anything, like an INT to a
+// BIGINT or a DATE to a TIMESTAMP, and likewise for arrays of them
+func converts(from, to ColumnType) bool {
+	if isArray(from) && isArray(to) {
+		return converts(elementType(from), elementType(to))
+	}
+	if isArray(from) || isArray(to) {
+		return false
+	}
+	if isNumeric(from) && isNumeric(to) {
+		return promoteNumeric(from, to) == to
+	}
+	return from == to || (from == DateType && to == TimestampType)
+}
+
+// commonType is the type two values are converted to before comparing them, the one of them the other converts to
+func commonType(a, b ColumnType) (ColumnType, bool) {
+	switch {
+	case a == b:
+		return a, true
+	case isNumeric(a) && isNumeric(b):
+		return promoteNumeric(a, b), true
+	case converts(a, b):
+		return b, true
+	case converts(b, a):
+		return a, true
+	}
+	return 0, false
+}
+
+// convertEvaluator converts the values ev produces from one type to a wider one, see converts
+func convertEvaluator(ev evaluator, from, to ColumnType) evaluator {
+	if from == to {
+		return ev
+	}
+	return func(row []MemoryCell) (MemoryCell, error) {
+		cell, err := ev(row)
+		if err != nil || cell == nil {
+			return nil, err
+		}
+		switch {
+		case isArray(to):
+			return convertArray(cell, elementType(to))
+		case from == DateType:
+			return microsCell(cellDays(cell) * microsPerDay), nil
+		case isInteger(to):
+			return integerCell(cell.AsInt64(), to)
+		case to == NumericType:
+			return numericCell(integerDecimal(cell.AsInt64())), nil
+		}
+		return floatCell(numericValue(cell, from), to)
+	}
+}
+
+func boolCell(b bool) MemoryCell {
+	if b {
+		return MemoryCell{1}
+	}
+	return MemoryCell{0}
+}
+
+// cellText formats a cell the way it would be written in SQL, without quotes
+func cellText(cell MemoryCell, typ ColumnType) string {
+	switch typ {
+	case SmallIntType, IntType, BigIntType:
+		return strconv.FormatInt(cell.AsInt64(), 10)
+	case BoolType:
+		return strconv.FormatBool(cell.AsBool())
+	case RealType:
+		return strconv.FormatFloat(cell.AsFloat(), 'g', -1, 32)
+	case DoubleType:
+		return strconv.FormatFloat(cell.AsFloat(), 'g', -1, 64)
+	case NumericType:
+		return decodeNumeric(cell).String()
+	case DateType, TimeType, TimestampType, IntervalType:
+		return temporalText(cell, typ)
+	case ByteaType:
+		return byteaText(cell)
+	case UuidType:
+		return uuidText(cell)
+	}
+	if isArray(typ) {
+		return arrayText(cell)
+	}
+	return cell.AsText()
+}
+
+// compareCells orders two non NULL cells of the same type
+func compareCells(a, b MemoryCell, typ ColumnType) int {
+	switch typ {
+	case SmallIntType, IntType, BigIntType:
+		x, y := a.AsInt64(), b.AsInt64()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case BoolType:
+		return int(a[0]) - int(b[0])
+	case RealType, DoubleType:
+		x, y := a.AsFloat(), b.AsFloat()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case NumericType:
+		return decodeNumeric(a).cmp(decodeNumeric(b))
+	case DateType, TimeType, TimestampType, IntervalType:
+		return compareTemporal(a, b, typ)
+	}
+	if isArray(typ) {
+		return compareArrays(a, b)
+	}
+	return bytes.Compare(a, b)
+}
+
+// resolveColumn finds the position of the column an identifier refers to, qualified like users.id or not
+func resolveColumn(cols []resultColumn, identifier string) (int, error) {
+	table, name := "", identifier
+	if i := strings.LastIndex(identifier, "."); i >= 0 {
+		table, name = identifier[:i], identifier[i+1:]
+	}
+
+	found := -1
+	for i, col := range cols {
+		if col.name != name || (table != "" && col.table != table) {
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("%w: %s", ErrAmbiguousColumn, identifier)
+		}
+		found = i
+	}
+
+	if found < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrColumnDoesNotExist, identifier)
+	}
+	return found, nil
+}
+
+func isNullLiteral(exp *expression) bool {
+	return exp.kind == literalKind && exp.literal.kind == nullKind
+}
+
+func isStringLiteral(exp *expression) bool {
+	return exp.kind == literalKind && exp.literal.kind == stringKind
+}
+
+// compileExpression turns exp into an evaluator for rows with the given columns, also returning the type of its
+// result. An expression that a column already holds, like an aggregate computed below, is read from it. Errors
+// tell where the innermost expression that failed is, see atLocation.
+func compileExpression(exp *expression, cols []resultColumn) (ev evaluator, typ ColumnType, err error) {
+	defer func() {
+		err = atLocation(err, exp.loc())
+	}()
+
+	code := exp.generateCode()
+	for i, col := range cols {
+		if col.expr != "" && col.expr == code {
+			return columnEvaluator(i), col.typ, nil
+		}
+	}
+
+	switch exp.kind {
+	case literalKind:
+		return compileLiteral(exp.literal, cols)
+	case binaryKind:
+		return compileBinary(exp.binary, cols)
+	case callKind:
+		if isAggregate(exp.call) {
+			return nil, 0, fmt.Errorf("%w: aggregate %s isn't allowed here", ErrInvalidSelectItem, code)
+		}
+		return compileCall(exp.call, cols)
+	case castKind:
+		return compileCast(exp.cast, cols)
+	case arrayKind:
+		return compileArray(exp.array, cols)
+	case subscriptKind:
+		return compileSubscript(exp.subscript, cols)
+	}
+	return nil, 0, ErrInvalidSelectItem
+}
+
+// volatileFunctions give a different value every time, so calls to them are never folded into a constant
+var volatileFunctions = map[string]bool{
+	"gen_random_uuid": true,
+}
+
+func compileCall(call *callExpression, cols []resultColumn) (evaluator, ColumnType, error) {
+	switch call.name.value {
+	case "unnest":
+		return nil, 0, fmt.Errorf("%w: unnest is only allowed as a select item", ErrInvalidSelectItem)
+	case "any":
+		return nil, 0, fmt.Errorf("%w: ANY is only allowed on the right of a comparison", ErrInvalidOperands)
+	}
+
+	overloads, ok := functions[call.name.value]
+	if !ok || call.star {
+		return nil, 0, fmt.Errorf("%w: %s", ErrFunctionDoesNotExist, call.name.value)
+	}
+
+	args, types := []evaluator{}, []ColumnType{}
+	for _, arg := range call.args {
+		ev, typ, err := compileExpression(arg, cols)
+		if err != nil {
+			return nil, 0, err
+		}
+		args, types = append(args, ev), append(types, typ)
+	}
+	return compileOverload(call, overloads, args, types)
+}
+
+// coerceLiteral reads a string literal compared with a value of another type as one, like the one in
+// d > '2024-01-02' or id = '12', see textToCell. One compared with binary data is read as its bytes.
+func coerceLiteral(exp *expression, ev evaluator, typ, other ColumnType) (evaluator, ColumnType, error) {
+	if !isStringLiteral(exp) {
+		return ev, typ, nil
+	}
+	if other == TextType || other == JsonType {
+		return ev, typ, nil
+	}
+
+	cell, err := textToCell(exp.literal.value, other, typeModifier{})
+	if err != nil {
+		return nil, 0, atLocation(err, exp.loc())
+	}
+	return constantEvaluator(cell), other, nil
+}
+
+// exactLiteral reads a number literal combined with or compared with a NUMERIC as a NUMERIC, so amt + 0.1 stays
+// exact instead of going through a DOUBLE PRECISION
+func exactLiteral(exp *expression, ev evaluator, typ, other ColumnType) (evaluator, ColumnType) {
+	if exp.kind != literalKind || exp.literal.kind != numericKind || typ != DoubleType || other != NumericType {
+		return ev, typ
+	}
+	d, err := parseNumeric(exp.literal.value)
+	if err != nil {
+		// Its DOUBLE PRECISION already read, so it's a number
+		return ev, typ
+	}
+	return constantEvaluator(numericCell(d)), NumericType
+}
+
+func columnEvaluator(i int) evaluator {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		return row[i], nil
+	}
+}
+
+// charLiteral reads a string literal compared with a CHAR column as a CHAR too, without trailing spaces, so
+// code = 'ab ' is true when code holds 'ab'
+func charLiteral(exp *expression, ev evaluator, other *expression, cols []resultColumn) evaluator {
+	if !isStringLiteral(exp) || other.kind != literalKind || other.literal.kind != identifierKind {
+		return ev
+	}
+	if i, err := resolveColumn(cols, other.literal.value); err != nil || !cols[i].char {
+		return ev
+	}
+	return charEvaluator(ev)
+}
+
+// charEvaluator reads the values of a CHAR column without the spaces that pad them, like Postgres does when using
+// one as a TEXT, so a CHAR(4) holding 'xy' equals 'xy' and its length is 2
+func charEvaluator(ev evaluator) evaluator {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		cell, err := ev(row)
+		if err != nil || cell == nil {
+			return nil, err
+		}
+		return MemoryCell(strings.TrimRight(cell.AsText(), " ")), nil
+	}
+}
+
+func constantEvaluator(cell MemoryCell) evaluator {
+	return func([]MemoryCell) (MemoryCell, error) {
+		return cell, nil
+	}
+}
+
+func compileLiteral(t *token, cols []resultColumn) (evaluator, ColumnType, error) {
+	switch t.kind {
+	case identifierKind:
+		i, err := resolveColumn(cols, t.value)
+		if err != nil {
+			return nil, 0, err
+		}
+		if cols[i].char {
+			return charEvaluator(columnEvaluator(i)), cols[i].typ, nil
+		}
+		return columnEvaluator(i), cols[i].typ, nil
+	case numericKind:
+		if strings.ContainsAny(t.value, ".eE") {
+			f, err := parseFloat(t.value)
+			if err != nil {
+				return nil, 0, err
+			}
+			cell, err := floatCell(f, DoubleType)
+			if err != nil {
+				return nil, 0, err
+			}
+			return constantEvaluator(cell), DoubleType, nil
+		}
+
+		i, err := parseInteger(t.value)
+		if err != nil {
+			return nil, 0, err
+		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			cell, _ := integerCell(i, BigIntType)
+			return constantEvaluator(cell), BigIntType, nil
+		}
+		return constantEvaluator(intCell(int32(i))), IntType, nil
+	case stringKind:
+		return constantEvaluator(MemoryCell(t.value)), TextType, nil
+	case hexKind:
+		cell, err := parseHex(t.value)
+		if err != nil {
+			return nil, 0, err
+		}
+		return constantEvaluator(cell), ByteaType, nil
+	case boolKind:
+		return constantEvaluator(boolCell(t.value == string(trueKeyword))), BoolType, nil
+	case nullKind:
+		// NULL has no type of its own, operators take the type of their other operand
+		return constantEvaluator(nil), TextType, nil
+	case parameterKind:
+		return nil, 0, fmt.Errorf("%w %s", ErrMissingArgument, t.value)
+	}
+	return nil, 0, fmt.Errorf("%w: %s", ErrInvalidSelectItem, t.value)
+}
+
+func compileBinary(be *binaryExpression, cols []resultColumn) (evaluator, ColumnType, error) {
+	if isAny(be.b) && isComparison(be.op.value) {
+		return compileAny(be, cols)
+	}
+
+	a, at, err := compileExpression(be.a, cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	b, bt, err := compileExpression(be.b, cols)
+	if err != nil {
+		return nil, 0, err
+	}
+	if isNullLiteral(be.a) {
+		at = bt
+	}
+	if isNullLiteral(be.b) {
+		bt = at
+	}
+
+	op := be.op.value
+	invalid := fmt.Errorf("%w: %s %s %s", ErrInvalidOperands, at, op, bt)
+
+	switch op {
+	case string(andKeyword), string(orKeyword):
+		if at != BoolType || bt != BoolType {
+			return nil, 0, invalid
+		}
+		return logicEvaluator(a, b, op == string(orKeyword)), BoolType, nil
+	case string(eqSymbol), string(neqSymbol), string(neqSymbol2), string(ltSymbol), string(lteSymbol),
+		string(gtSymbol), string(gteSymbol):
+		if a, at, err = coerceLiteral(be.a, a, at, bt); err != nil {
+			return nil, 0, err
+		}
+		if b, bt, err = coerceLiteral(be.b, b, bt, at); err != nil {
+			return nil, 0, err
+		}
+		a, at = exactLiteral(be.a, a, at, bt)
+		b, bt = exactLiteral(be.b, b, bt, at)
+		a, b = charLiteral(be.a, a, be.b, cols), charLiteral(be.b, b, be.a, cols)
+
+		if at != bt {
+			typ, ok := commonType(at, bt)
+			if !ok {
+				return nil, 0, invalid
+			}
+			a, b = convertEvaluator(a, at, typ), convertEvaluator(b, bt, typ)
+			at = typ
+		}
+		return comparisonEvaluator(a, b, at, op), BoolType, nil
+	case string(plusSymbol), string(minusSymbol), string(asteriskSymbol), string(slashSymbol):
+		if isTemporal(at) || isTemporal(bt) {
+			return compileTemporalArithmetic(a, at, b, bt, op)
+		}
+		if !isNumeric(at) || !isNumeric(bt) {
+			return nil, 0, invalid
+		}
+		a, at = exactLiteral(be.a, a, at, bt)
+		b, bt = exactLiteral(be.b, b, bt, at)
+		typ := promoteNumeric(at, bt)
+		a, b = convertEvaluator(a, at, typ), convertEvaluator(b, bt, typ)
+		switch {
+		case isInteger(typ):
+			return arithmeticEvaluator(a, b, op, typ), typ, nil
+		case typ == NumericType:
+			return numericArithmeticEvaluator(a, b, op), typ, nil
+		}
+		return floatArithmeticEvaluator(a, b, op, typ), typ, nil
+	case string(arrowSymbol), string(doubleArrowSymbol):
+		return compileJSONOperator(a, at, b, bt, op)
+	case string(concatSymbol):
+		if isArray(at) || isArray(bt) {
+			return compileArrayConcat(a, at, b, bt)
+		}
+		if at == ByteaType && bt == ByteaType {
+			return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+				return append(append(MemoryCell{}, x...), y...), nil
+			}), ByteaType, nil
+		}
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			return MemoryCell(cellText(x, at) + cellText(y, bt)), nil
+		}), TextType, nil
+	}
+	return nil, 0, invalid
+}
+
+// binaryEvaluator evaluates both operands and applies fn to them, unless one is NULL
+func binaryEvaluator(a, b evaluator, fn func(x, y MemoryCell) (MemoryCell, error)) evaluator {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		x, err := a(row)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b(row)
+		if err != nil {
+			return nil, err
+		}
+		if x == nil || y == nil {
+			return nil, nil
+		}
+		return fn(x, y)
+	}
+}
+
+func logicEvaluator(a, b evaluator, or bool) evaluator {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		x, err := a(row)
+		if err != nil {
+			return nil, err
+		}
+		// The result is already known when the left side is false for AND or true for OR
+		if x != nil && x.AsBool() == or {
+			return x, nil
+		}
+
+		y, err := b(row)
+		if err != nil {
+			return nil, err
+		}
+		if y != nil && y.AsBool() == or {
+			return y, nil
+		}
+		if x == nil || y == nil {
+			return nil, nil
+		}
+		return boolCell(!or), nil
+	}
+}
+
+func comparisonEvaluator(a, b evaluator, typ ColumnType, op string) evaluator {
+	return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+		return boolCell(compare(compareCells(x, y, typ), op)), nil
+	})
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case string(eqSymbol), string(neqSymbol), string(neqSymbol2), string(ltSymbol), string(lteSymbol),
+		string(gtSymbol), string(gteSymbol):
+		return true
+	}
+	return false
+}
+
+// compare applies a comparison operator to the result of compareCells
+func compare(c int, op string) bool {
+	switch op {
+	case string(eqSymbol):
+		return c == 0
+	case string(neqSymbol), string(neqSymbol2):
+		return c != 0
+	case string(ltSymbol):
+		return c < 0
+	case string(lteSymbol):
+		return c <= 0
+	case string(gtSymbol):
+		return c > 0
+	}
+	return c >= 0
+}
+
+// arithmeticEvaluator computes on integers of the given type, the operations themselves are checked for
+// overflowing 64 bits and the result for fitting the type
+func arithmeticEvaluator(a, b evaluator, op string, typ ColumnType) evaluator {
+	return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+		i, j := x.AsInt64(), y.AsInt64()
+		var result int64
+		overflow := false
+		switch op {
+		case string(plusSymbol):
+			result = i + j
+			overflow = (result > i) != (j > 0)
+		case string(minusSymbol):
+			result = i - j
+			overflow = (result < i) != (j > 0)
+		case string(asteriskSymbol):
+			result = i * j
+			overflow = i != 0 && (result/i != j || (i == -1 && j == math.MinInt64))
+		case string(slashSymbol):
+			if j == 0 {
+				return nil, ErrDivisionByZero
+			}
+			result = i / j
+			overflow = i == math.MinInt64 && j == -1
+		}
+
+		if overflow {
+			return nil, fmt.Errorf("%w: %d %s %d doesn't fit in %s", ErrIntegerOutOfRange, i, op, j, typ)
+		}
+		return integerCell(result, typ)
+	})
+}
+
+// floatArithmeticEvaluator computes on REALs or DOUBLE PRECISIONs, failing on division by zero like for INTs and
+// when the result doesn't fit
+func floatArithmeticEvaluator(a, b evaluator, op string, typ ColumnType) evaluator {
+	return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+		i, j := x.AsFloat(), y.AsFloat()
+		var result float64
+		switch op {
+		case string(plusSymbol):
+			result = i + j
+		case string(minusSymbol):
+			result = i - j
+		case string(asteriskSymbol):
+			result = i * j
+		case string(slashSymbol):
+			if j == 0 {
+				return nil, ErrDivisionByZero
+			}
+			result = i / j
+		}
+		return floatCell(result, typ)
+	})
+}
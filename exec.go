@@ -0,0 +1,540 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Execution
+---------
+Selects run as a tree of operators in the Volcano style: asking an operator for its next row makes it pull rows
+from its children one at a time, so rows flow to the caller as they are produced instead of being gathered in a
+big slice first. Only sorting, grouping and the inner side of a join need to see all their rows before producing
+any. Backends only have to provide the operator scanning a table, the rest is shared.
+*/
+
+// canceled returns an error wrapping both ErrQueryCanceled and the reason once ctx is done
+func canceled(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrQueryCanceled, err)
+	}
+	return nil
+}
+
+type resultColumn struct {
+	name string
+	typ  ColumnType
+	// The table or alias the column belongs to, empty for computed columns
+	table string
+	// The code of the expression the column holds when it was computed by an operator, like count(*)
+	expr string
+	// CHAR columns hold values padded with spaces, which expressions read without them, see charEvaluator
+	char bool
+}
+
+// pickColumns keeps the items at positions, all of them when positions is nil
+func pickColumns[T any](items []T, positions []int) []T {
+	if positions == nil {
+		return items
+	}
+
+	picked := make([]T, 0, len(positions))
+	for _, i := range positions {
+		picked = append(picked, items[i])
+	}
+	return picked
+}
+
+type operator interface {
+	columns() []resultColumn
+	// next returns the following row, ok is false once there are no more
+	next() (row []MemoryCell, ok bool, err error)
+	close() error
+
+	// describe and children are what EXPLAIN shows
+	describe() string
+	children() []operator
+}
+
+// values produces a fixed list of rows, like the lines of a plan or the single row of a select without FROM
+type values struct {
+	name  string
+	cols  []resultColumn
+	rows  [][]MemoryCell
+	index int
+}
+
+func (v *values) columns() []resultColumn {
+	return v.cols
+}
+
+func (v *values) next() ([]MemoryCell, bool, error) {
+	if v.index >= len(v.rows) {
+		return nil, false, nil
+	}
+	v.index++
+	return v.rows[v.index-1], true, nil
+}
+
+func (v *values) close() error {
+	return nil
+}
+
+func (v *values) describe() string {
+	return v.name
+}
+
+func (v *values) children() []operator {
+	return nil
+}
+
+// filter only lets through the rows its condition is true for
+type filter struct {
+	child     operator
+	condition evaluator
+	code      string
+}
+
+func (f *filter) columns() []resultColumn {
+	return f.child.columns()
+}
+
+func (f *filter) next() ([]MemoryCell, bool, error) {
+	for {
+		row, ok, err := f.child.next()
+		if !ok || err != nil {
+			return nil, false, err
+		}
+
+		keep, err := f.condition(row)
+		if err != nil {
+			return nil, false, err
+		}
+		if keep != nil && keep.AsBool() {
+			return row, true, nil
+		}
+	}
+}
+
+func (f *filter) close() error {
+	return f.child.close()
+}
+
+func (f *filter) describe() string {
+	return "Filter " + f.code
+}
+
+func (f *filter) children() []operator {
+	return []operator{f.child}
+}
+
+// nestedLoopJoin pairs every row of its left child with every row of its right child, keeping the pairs its
+// condition is true for. The right rows are read once and kept in memory.
+type nestedLoopJoin struct {
+	left      operator
+	right     operator
+	condition evaluator
+	code      string
+	cols      []resultColumn
+
+	rightRows [][]MemoryCell
+	loaded    bool
+	leftRow   []MemoryCell
+	index     int
+}
+
+func newNestedLoopJoin(left, right operator, condition evaluator, code string) *nestedLoopJoin {
+	cols := append(append([]resultColumn{}, left.columns()...), right.columns()...)
+	return &nestedLoopJoin{left: left, right: right, condition: condition, code: code, cols: cols}
+}
+
+func (j *nestedLoopJoin) columns() []resultColumn {
+	return j.cols
+}
+
+func (j *nestedLoopJoin) next() ([]MemoryCell, bool, error) {
+	if !j.loaded {
+		for {
+			row, ok, err := j.right.next()
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				break
+			}
+			j.rightRows = append(j.rightRows, row)
+		}
+		j.loaded = true
+	}
+
+	for {
+		if j.leftRow == nil {
+			row, ok, err := j.left.next()
+			if !ok || err != nil {
+				return nil, false, err
+			}
+			j.leftRow, j.index = row, 0
+		}
+
+		for j.index < len(j.rightRows) {
+			row := append(append([]MemoryCell{}, j.leftRow...), j.rightRows[j.index]...)
+			j.index++
+			if j.condition == nil {
+				return row, true, nil
+			}
+
+			keep, err := j.condition(row)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep != nil && keep.AsBool() {
+				return row, true, nil
+			}
+		}
+		j.leftRow = nil
+	}
+}
+
+func (j *nestedLoopJoin) close() error {
+	return errors.Join(j.left.close(), j.right.close())
+}
+
+func (j *nestedLoopJoin) describe() string {
+	if j.condition == nil {
+		return "Nested Loop Join"
+	}
+	return "Nested Loop Join " + j.code
+}
+
+func (j *nestedLoopJoin) children() []operator {
+	return []operator{j.left, j.right}
+}
+
+// hashAggregate groups the rows of its child by its keys and computes the aggregates of every group. Its rows are
+// the keys followed by the aggregates, groups come out in the order they were first seen.
+type hashAggregate struct {
+	child      operator
+	keys       []evaluator
+	aggregates []*aggregateCall
+	cols       []resultColumn
+
+	results [][]MemoryCell
+	done    bool
+	index   int
+}
+
+func (h *hashAggregate) columns() []resultColumn {
+	return h.cols
+}
+
+func (h *hashAggregate) next() ([]MemoryCell, bool, error) {
+	if !h.done {
+		if err := h.aggregate(); err != nil {
+			return nil, false, err
+		}
+		h.done = true
+	}
+
+	if h.index >= len(h.results) {
+		return nil, false, nil
+	}
+	h.index++
+	return h.results[h.index-1], true, nil
+}
+
+func (h *hashAggregate) aggregate() error {
+	type group struct {
+		keys   []MemoryCell
+		states []aggregateState
+	}
+	groups := map[string]*group{}
+	order := []*group{}
+
+	for {
+		row, ok, err := h.child.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		keys := []MemoryCell{}
+		for _, key := range h.keys {
+			cell, err := key(row)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, cell)
+		}
+
+		encoded := string(encodeRow(keys))
+		g, ok := groups[encoded]
+		if !ok {
+			g = &group{keys: keys, states: make([]aggregateState, len(h.aggregates))}
+			groups[encoded] = g
+			order = append(order, g)
+		}
+
+		for i, agg := range h.aggregates {
+			if err := agg.step(&g.states[i], row); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Without GROUP BY there's always a single group, even for no rows at all
+	if len(h.keys) == 0 && len(order) == 0 {
+		order = append(order, &group{states: make([]aggregateState, len(h.aggregates))})
+	}
+
+	for _, g := range order {
+		row := append([]MemoryCell{}, g.keys...)
+		for i, agg := range h.aggregates {
+			cell, err := agg.result(&g.states[i])
+			if err != nil {
+				return err
+			}
+			row = append(row, cell)
+		}
+		h.results = append(h.results, row)
+	}
+	return nil
+}
+
+func (h *hashAggregate) close() error {
+	return h.child.close()
+}
+
+func (h *hashAggregate) describe() string {
+	keys := []string{}
+	for _, col := range h.cols[:len(h.keys)] {
+		keys = append(keys, col.expr)
+	}
+	if len(keys) == 0 {
+		return "Aggregate"
+	}
+	return fmt.Sprintf("Hash Aggregate (group by %s)", strings.Join(keys, ", "))
+}
+
+func (h *hashAggregate) children() []operator {
+	return []operator{h.child}
+}
+
+// sorter reads every row of its child and produces them ordered by its keys. NULLs come last in ascending order
+// and first in descending order.
+type sorter struct {
+	child operator
+	keys  []evaluator
+	types []ColumnType
+	desc  []bool
+	codes []string
+
+	rows   [][]MemoryCell
+	sorted bool
+	index  int
+}
+
+func (s *sorter) columns() []resultColumn {
+	return s.child.columns()
+}
+
+func (s *sorter) next() ([]MemoryCell, bool, error) {
+	if !s.sorted {
+		if err := s.sort(); err != nil {
+			return nil, false, err
+		}
+		s.sorted = true
+	}
+
+	if s.index >= len(s.rows) {
+		return nil, false, nil
+	}
+	s.index++
+	return s.rows[s.index-1], true, nil
+}
+
+func (s *sorter) sort() error {
+	type keyed struct {
+		row  []MemoryCell
+		keys []MemoryCell
+	}
+
+	all := []keyed{}
+	for {
+		row, ok, err := s.child.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		keys := []MemoryCell{}
+		for _, key := range s.keys {
+			cell, err := key(row)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, cell)
+		}
+		all = append(all, keyed{row: row, keys: keys})
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		for k := range s.keys {
+			a, b := all[i].keys[k], all[j].keys[k]
+			var c int
+			switch {
+			case a == nil && b == nil:
+				c = 0
+			case a == nil:
+				c = 1
+			case b == nil:
+				c = -1
+			default:
+				c = compareCells(a, b, s.types[k])
+			}
+
+			if s.desc[k] {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	for _, k := range all {
+		s.rows = append(s.rows, k.row)
+	}
+	return nil
+}
+
+func (s *sorter) close() error {
+	return s.child.close()
+}
+
+func (s *sorter) describe() string {
+	return fmt.Sprintf("Sort (%s)", strings.Join(s.codes, ", "))
+}
+
+func (s *sorter) children() []operator {
+	return []operator{s.child}
+}
+
+// projection computes the select items out of every row of its child. Set-returning items, unnest(tags), give
+// arrays instead, and the row becomes as many rows as the longest of them has elements, the others being NULL past
+// their end and the rest of the items being repeated. A row whose arrays are all empty or NULL gives no rows.
+type projection struct {
+	child operator
+	items []evaluator
+	sets  []bool
+	cols  []resultColumn
+	codes []string
+
+	// The rows left of the last row of the child when there are set-returning items
+	pending [][]MemoryCell
+}
+
+func (p *projection) columns() []resultColumn {
+	return p.cols
+}
+
+func (p *projection) next() ([]MemoryCell, bool, error) {
+	for len(p.pending) == 0 {
+		row, ok, err := p.child.next()
+		if !ok || err != nil {
+			return nil, false, err
+		}
+
+		result := make([]MemoryCell, 0, len(p.items))
+		for _, item := range p.items {
+			cell, err := item(row)
+			if err != nil {
+				return nil, false, err
+			}
+			result = append(result, cell)
+		}
+		if !slices.Contains(p.sets, true) {
+			return result, true, nil
+		}
+		p.pending = p.expand(result)
+	}
+
+	row := p.pending[0]
+	p.pending = p.pending[1:]
+	return row, true, nil
+}
+
+// expand turns a row with arrays for its set-returning items into a row for every element
+func (p *projection) expand(result []MemoryCell) [][]MemoryCell {
+	elements := make([][]MemoryCell, len(result))
+	count := 0
+	for i, cell := range result {
+		if p.sets[i] && cell != nil {
+			_, elements[i] = decodeArray(cell)
+			count = max(count, len(elements[i]))
+		}
+	}
+
+	rows := [][]MemoryCell{}
+	for n := 0; n < count; n++ {
+		row := make([]MemoryCell, len(result))
+		for i, cell := range result {
+			switch {
+			case !p.sets[i]:
+				row[i] = cell
+			case n < len(elements[i]):
+				row[i] = elements[i][n]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (p *projection) close() error {
+	return p.child.close()
+}
+
+func (p *projection) describe() string {
+	if slices.Contains(p.sets, true) {
+		return fmt.Sprintf("ProjectSet (%s)", strings.Join(p.codes, ", "))
+	}
+	return fmt.Sprintf("Projection (%s)", strings.Join(p.codes, ", "))
+}
+
+func (p *projection) children() []operator {
+	return []operator{p.child}
+}
+
+// analyzed wraps an operator for EXPLAIN ANALYZE, counting the rows it produces and the time spent producing them,
+// which includes the time spent by its children
+type analyzed struct {
+	operator
+	rows    int
+	elapsed time.Duration
+}
+
+func (a *analyzed) next() ([]MemoryCell, bool, error) {
+	start := time.Now()
+	row, ok, err := a.operator.next()
+	a.elapsed += time.Since(start)
+	if ok {
+		a.rows++
+	}
+	return row, ok, err
+}
+
+func (a *analyzed) describe() string {
+	ms := float64(a.elapsed.Microseconds()) / 1000
+	return fmt.Sprintf("%s (actual rows=%d time=%.3fms)", a.operator.describe(), a.rows, ms)
+}
@@ -0,0 +1,340 @@
+package gosql
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+/*
+Functions
+---------
+Functions other than aggregates are looked up by their name and the types of their arguments. A name has one or
+more overloads, each taking arguments of given types, and a call uses the first one, in the order they're declared
+below, taking exactly the types of its arguments or, failing that, the first one its arguments convert to, see
+converts. NULL and string literals fit any type, a string literal being read as the type it's given as, so
+date_trunc('day', '2024-01-02 10:30') truncates a TIMESTAMP. Calling a function that doesn't exist fails with
+ErrFunctionDoesNotExist, and calling one with arguments none of its overloads take with ErrInvalidOperands.
+
+Overloads taking anyElement take arguments of any type, the same one for all of them: the one all the others
+convert to, like for a comparison.
+
+	lower(s), upper(s)                s in lower or upper case
+	length(s)                         the characters of a string or the bytes of binary data
+	substr(s, start[, count])         count characters or bytes from start, counted from 1, also substring
+	trim(s[, characters])             s without the spaces, or any of the characters, at its start and end
+	replace(s, from, to)              s with every from replaced by to
+	coalesce(x, ...)                  the first x that isn't NULL, without evaluating the ones after it
+	nullif(x, y)                      NULL when x = y, x otherwise
+	abs(x)                            the absolute value of a number, of its type
+	round(x[, places])                x rounded half away from zero to an integer or to a number of decimal places,
+	                                  negative ones rounding to tens, hundreds...
+	greatest(x, ...), least(x, ...)   the largest or smallest x, ignoring NULLs
+
+Unlike in Postgres, round takes a DOUBLE PRECISION and a number of places too, since 2.567 is one here. It's
+rounded as the NUMERIC it's written as, so round(2.675, 2) is 2.68.
+*/
+
+// anyElement stands for any type in the arguments of an overload, see the comment above. No value has it.
+const anyElement ColumnType = 1 << 16
+
+// An overload is one of the ways to call a function, compiled from its arguments converted to the types it takes
+type overload struct {
+	args []ColumnType
+	// The last argument can be repeated, at least once
+	variadic bool
+	compile  func(args []evaluator, types []ColumnType) (evaluator, ColumnType, error)
+}
+
+var (
+	textArg      = []ColumnType{TextType}
+	substringArg = [][]ColumnType{
+		{TextType, BigIntType}, {TextType, BigIntType, BigIntType},
+		{ByteaType, BigIntType}, {ByteaType, BigIntType, BigIntType},
+	}
+)
+
+// functions are the overloads of every function, by name
+var functions = map[string][]overload{
+	"lower":     {{args: textArg, compile: compileTextFunction(strings.ToLower)}},
+	"upper":     {{args: textArg, compile: compileTextFunction(strings.ToUpper)}},
+	"length":    {{args: textArg, compile: compileLength}, {args: []ColumnType{ByteaType}, compile: compileLength}},
+	"substr":    overloads(compileSubstring, substringArg...),
+	"substring": overloads(compileSubstring, substringArg...),
+	"trim":      overloads(compileTrim, textArg, []ColumnType{TextType, TextType}),
+	"replace":   overloads(compileReplace, []ColumnType{TextType, TextType, TextType}),
+
+	"coalesce": {{args: []ColumnType{anyElement}, variadic: true, compile: compileCoalesce}},
+	"nullif":   overloads(compileNullif, []ColumnType{anyElement, anyElement}),
+	"greatest": {{args: []ColumnType{anyElement}, variadic: true, compile: compileExtreme(1)}},
+	"least":    {{args: []ColumnType{anyElement}, variadic: true, compile: compileExtreme(-1)}},
+
+	"abs": overloads(compileAbs,
+		[]ColumnType{SmallIntType}, []ColumnType{IntType}, []ColumnType{BigIntType},
+		[]ColumnType{NumericType}, []ColumnType{RealType}, []ColumnType{DoubleType}),
+	"round": overloads(compileRound,
+		[]ColumnType{NumericType}, []ColumnType{DoubleType},
+		[]ColumnType{NumericType, IntType}, []ColumnType{DoubleType, IntType}),
+
+	"now":        overloads(compileNow, []ColumnType{}),
+	"date_trunc": overloads(compileDateTrunc, []ColumnType{TextType, TimestampType}),
+	"extract": overloads(compileExtract,
+		[]ColumnType{TextType, DateType}, []ColumnType{TextType, TimeType},
+		[]ColumnType{TextType, TimestampType}, []ColumnType{TextType, IntervalType}),
+
+	"json_extract":      overloads(compileJSONExtract, []ColumnType{JsonType, TextType}),
+	"json_array_length": overloads(compileJSONArrayLength, []ColumnType{JsonType}),
+
+	"gen_random_uuid": overloads(compileGenRandomUUID, []ColumnType{}),
+}
+
+// overloads declares the overloads of a function compiled the same way
+func overloads(
+	compile func([]evaluator, []ColumnType) (evaluator, ColumnType, error), args ...[]ColumnType,
+) []overload {
+	all := []overload{}
+	for _, a := range args {
+		all = append(all, overload{args: a, compile: compile})
+	}
+	return all
+}
+
+// compileOverload compiles a call with the overload it uses, see the comment above
+func compileOverload(
+	call *callExpression, overloads []overload, args []evaluator, types []ColumnType,
+) (evaluator, ColumnType, error) {
+	for _, exact := range []bool{true, false} {
+		for _, o := range overloads {
+			params, ok := o.params(call.args, types)
+			if !ok || !takes(call.args, types, params, exact) {
+				continue
+			}
+
+			converted := []evaluator{}
+			for i, arg := range call.args {
+				ev := args[i]
+				switch {
+				case isNullLiteral(arg), types[i] == params[i]:
+				case isStringLiteral(arg):
+					cell, err := textToCell(arg.literal.value, params[i], typeModifier{})
+					if err != nil {
+						return nil, 0, atLocation(err, arg.loc())
+					}
+					ev = constantEvaluator(cell)
+				default:
+					ev = convertEvaluator(ev, types[i], params[i])
+				}
+				converted = append(converted, ev)
+			}
+			return o.compile(converted, params)
+		}
+	}
+
+	names := []string{}
+	for _, typ := range types {
+		names = append(names, typ.String())
+	}
+	return nil, 0, fmt.Errorf("%w: %s(%s)", ErrInvalidOperands, call.name.value, strings.Join(names, ", "))
+}
+
+// params are the types an overload takes for the arguments of a call, with anyElement replaced by the type the
+// arguments it stands for have in common
+func (o overload) params(args []*expression, types []ColumnType) ([]ColumnType, bool) {
+	if len(args) != len(o.args) && (!o.variadic || len(args) < len(o.args)) {
+		return nil, false
+	}
+
+	// NULLs and string literals alone are TEXT
+	params, element, found := []ColumnType{}, TextType, false
+	for i, arg := range args {
+		param := o.args[min(i, len(o.args)-1)]
+		params = append(params, param)
+		if param != anyElement || isNullLiteral(arg) || isStringLiteral(arg) {
+			continue
+		}
+		if !found {
+			element, found = types[i], true
+			continue
+		}
+		typ, ok := commonType(element, types[i])
+		if !ok {
+			return nil, false
+		}
+		element = typ
+	}
+
+	for i, param := range params {
+		if param == anyElement {
+			params[i] = element
+		}
+	}
+	return params, true
+}
+
+// takes is true when the arguments of a call have the types of the params or, unless exact, convert to them
+func takes(args []*expression, types, params []ColumnType, exact bool) bool {
+	for i, arg := range args {
+		switch {
+		case types[i] == params[i], isNullLiteral(arg):
+		case exact:
+			return false
+		case !isStringLiteral(arg) && !converts(types[i], params[i]):
+			return false
+		}
+	}
+	return true
+}
+
+// strictEvaluator evaluates every argument and gives NULL when any of them is NULL, like most functions do
+func strictEvaluator(args []evaluator, fn func(values []MemoryCell) (MemoryCell, error)) evaluator {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		values := []MemoryCell{}
+		for _, arg := range args {
+			cell, err := arg(row)
+			if err != nil || cell == nil {
+				return nil, err
+			}
+			values = append(values, cell)
+		}
+		return fn(values)
+	}
+}
+
+// compileTextFunction compiles a function changing a string into another
+func compileTextFunction(fn func(string) string) func([]evaluator, []ColumnType) (evaluator, ColumnType, error) {
+	return func(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+		return strictEvaluator(args, func(values []MemoryCell) (MemoryCell, error) {
+			return MemoryCell(fn(values[0].AsText())), nil
+		}), TextType, nil
+	}
+}
+
+func compileTrim(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return strictEvaluator(args, func(values []MemoryCell) (MemoryCell, error) {
+		characters := " "
+		if len(values) == 2 {
+			characters = values[1].AsText()
+		}
+		return MemoryCell(strings.Trim(values[0].AsText(), characters)), nil
+	}), TextType, nil
+}
+
+func compileReplace(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return strictEvaluator(args, func(values []MemoryCell) (MemoryCell, error) {
+		s, from := values[0].AsText(), values[1].AsText()
+		// Like in Postgres, replacing nothing changes nothing instead of inserting between every character
+		if from == "" {
+			return values[0], nil
+		}
+		return MemoryCell(strings.ReplaceAll(s, from, values[2].AsText())), nil
+	}), TextType, nil
+}
+
+func compileCoalesce(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		for _, arg := range args {
+			cell, err := arg(row)
+			if err != nil || cell != nil {
+				return cell, err
+			}
+		}
+		return nil, nil
+	}, types[0], nil
+}
+
+func compileNullif(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	typ := types[0]
+	return binaryOrNullEvaluator(args[0], args[1], func(x, y MemoryCell) MemoryCell {
+		if x != nil && y != nil && compareCells(x, y, typ) == 0 {
+			return nil
+		}
+		return x
+	}), typ, nil
+}
+
+// binaryOrNullEvaluator evaluates two arguments, passing NULLs on to fn instead of giving NULL
+func binaryOrNullEvaluator(a, b evaluator, fn func(x, y MemoryCell) MemoryCell) evaluator {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		x, err := a(row)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b(row)
+		if err != nil {
+			return nil, err
+		}
+		return fn(x, y), nil
+	}
+}
+
+// compileExtreme compiles greatest, for a sign of 1, or least, for -1
+func compileExtreme(sign int) func([]evaluator, []ColumnType) (evaluator, ColumnType, error) {
+	return func(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+		typ := types[0]
+		return func(row []MemoryCell) (MemoryCell, error) {
+			var extreme MemoryCell
+			for _, arg := range args {
+				cell, err := arg(row)
+				if err != nil {
+					return nil, err
+				}
+				if cell != nil && (extreme == nil || sign*compareCells(cell, extreme, typ) > 0) {
+					extreme = cell
+				}
+			}
+			return extreme, nil
+		}, typ, nil
+	}
+}
+
+func compileAbs(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	typ := types[0]
+	return strictEvaluator(args, func(values []MemoryCell) (MemoryCell, error) {
+		switch {
+		case isInteger(typ):
+			i := values[0].AsInt64()
+			if i == math.MinInt64 {
+				return nil, fmt.Errorf("%w: abs(%d) doesn't fit in BIGINT", ErrIntegerOutOfRange, i)
+			}
+			return integerCell(max(i, -i), typ)
+		case typ == NumericType:
+			d := decodeNumeric(values[0])
+			return numericCell(decimal{unscaled: new(big.Int).Abs(d.unscaled), scale: d.scale}), nil
+		}
+		return floatCell(math.Abs(values[0].AsFloat()), typ)
+	}), typ, nil
+}
+
+// maxRoundPlaces bounds the places round takes, so it doesn't build numbers of any size
+const maxRoundPlaces = 1000
+
+func compileRound(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	typ := types[0]
+	return strictEvaluator(args, func(values []MemoryCell) (MemoryCell, error) {
+		places := int64(0)
+		if len(values) == 2 {
+			places = values[1].AsInt64()
+		}
+		if places < -maxRoundPlaces || places > maxRoundPlaces {
+			return nil, fmt.Errorf("%w: round can't round to %d places", ErrInvalidOperands, places)
+		}
+
+		cell := values[0]
+		if typ == DoubleType {
+			var err error
+			if cell, err = castCell(cell, DoubleType, NumericType); err != nil {
+				return nil, err
+			}
+		}
+		d := decodeNumeric(cell).rescale(int(places))
+		if places < 0 {
+			d = d.rescale(0)
+		}
+
+		if typ == DoubleType {
+			return floatCell(d.float(), DoubleType)
+		}
+		return numericCell(d), nil
+	}), typ, nil
+}
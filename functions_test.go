@@ -0,0 +1,98 @@
+package gosql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctions(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE items (id INT, name VARCHAR(20), price NUMERIC(6, 2), qty SMALLINT, weight REAL, nick TEXT);
+		INSERT INTO items VALUES (1, '  Lamp ', 19.99, -3, 1.25, NULL);
+		INSERT INTO items VALUES (2, 'Desk', -120.5, 4, NULL, 'big desk');`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+	}{
+		{
+			query: `SELECT lower(name), upper(name), length(name), trim(name), trim('xxhixx', 'x') FROM items WHERE id = 1;`,
+			rows:  [][]any{{"  lamp ", "  LAMP ", int64(7), "Lamp", "hi"}},
+		},
+		{
+			query: `SELECT substr(name, 2, 3), substr('héllo', 2), substring(name, 2, 2), replace(name, 'e', 'E'), replace('ab', '', 'x') FROM items WHERE id = 2;`,
+			rows:  [][]any{{"esk", "éllo", "es", "DEsk", "ab"}},
+		},
+		{
+			query: `SELECT coalesce(nick, name), coalesce(NULL, weight, qty), nullif(qty, 4), nullif(id, 2) FROM items ORDER BY id;`,
+			rows: [][]any{
+				{"  Lamp ", float64(1.25), int64(-3), int64(1)},
+				{"big desk", float64(4), nil, nil},
+			},
+		},
+		{
+			query: `SELECT coalesce(NULL, NULL), coalesce(nick, 'none'), coalesce(id, 1 / 0) FROM items WHERE id = 1;`,
+			rows:  [][]any{{nil, "none", int64(1)}},
+		},
+		{
+			query: `SELECT abs(qty), abs(price), abs(weight), abs(-2.5), abs(-7::BIGINT) FROM items ORDER BY id;`,
+			rows: [][]any{
+				{int64(3), "19.99", float64(1.25), 2.5, int64(7)},
+				{int64(4), "120.50", nil, 2.5, int64(7)},
+			},
+		},
+		{
+			query: `SELECT round(price), round(price, 1), round(2.675, 2), round(2.5), round(-2.5), round(1234, -2), round(qty) FROM items WHERE id = 2;`,
+			rows:  [][]any{{"-121", "-120.5", 2.68, 3.0, -3.0, "1200", "4"}},
+		},
+		{
+			query: `SELECT greatest(1, qty, 2.5), least(id, qty, NULL), greatest(name, nick), least(NULL, NULL), greatest(DATE '2024-01-02', '2024-03-04') FROM items WHERE id = 2;`,
+			rows:  [][]any{{4.0, int64(2), "big desk", nil, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			query: `SELECT id FROM items WHERE lower(trim(name)) = 'lamp' AND coalesce(nick, '') = '';`,
+			rows:  [][]any{{int64(1)}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	errorTests := []struct {
+		query string
+		err   error
+	}{
+		{`SELECT soundex(name) FROM items;`, ErrFunctionDoesNotExist},
+		{`SELECT lower(id) FROM items;`, ErrInvalidOperands},
+		{`SELECT upper(name, name) FROM items;`, ErrInvalidOperands},
+		{`SELECT abs(name) FROM items;`, ErrInvalidOperands},
+		{`SELECT round(price, 1.5) FROM items;`, ErrInvalidOperands},
+		{`SELECT coalesce() FROM items;`, ErrInvalidOperands},
+		{`SELECT coalesce(id, name) FROM items;`, ErrInvalidOperands},
+		{`SELECT greatest(id, 'two') FROM items;`, ErrInvalidDatatype},
+		{`SELECT abs(CAST(-32768 AS SMALLINT)) FROM items;`, ErrIntegerOutOfRange},
+		{`SELECT round(price, 5000) FROM items;`, ErrInvalidOperands},
+	}
+	for _, test := range errorTests {
+		_, err := queryAll(t, db, test.query)
+		assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+	}
+	_, err = queryAll(t, db, `SELECT abs(CAST(-9223372036854775807 AS BIGINT) - 1) FROM items;`)
+	assert.ErrorContains(t, err, "abs(-9223372036854775808) doesn't fit in BIGINT")
+
+	rows, err := db.Query(`SELECT lower(name), coalesce(qty, id), greatest(qty, 1.5), round(weight), abs(qty) FROM items;`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []ColumnType{TextType, IntType, DoubleType, DoubleType, SmallIntType}, rows.ColumnTypes())
+		rows.Close()
+	}
+}
@@ -0,0 +1,282 @@
+package gosql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Indexes
+-------
+An index keeps the rows of a table ordered by one of its columns, so a condition like id = 3 or id > 10 only has
+to look at the rows matching it instead of scanning the whole table. Memory indexes are a sorted slice with the
+value and position of every row. NULLs aren't indexed, no comparison is ever true for them.
+
+	CREATE INDEX users_id ON users (id);
+
+The optimizer decides when an index is worth using, see optimizer.go.
+*/
+
+type indexInfo struct {
+	name   string
+	column string
+}
+
+type indexEntry struct {
+	value MemoryCell
+	row   int
+}
+
+type index struct {
+	name    string
+	column  int
+	typ     ColumnType
+	entries []indexEntry
+}
+
+// add indexes the value of the row at the given position, rows with equal values stay in insertion order
+func (idx *index) add(value MemoryCell, row int) {
+	if value == nil {
+		return
+	}
+
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return compareCells(idx.entries[i].value, value, idx.typ) > 0
+	})
+	idx.entries = append(idx.entries, indexEntry{})
+	copy(idx.entries[i+1:], idx.entries[i:])
+	idx.entries[i] = indexEntry{value: value, row: row}
+}
+
+// indexRange bounds the values an index scan looks for, a nil bound leaves that side open
+type indexRange struct {
+	lower          MemoryCell
+	lowerInclusive bool
+	upper          MemoryCell
+	upperInclusive bool
+}
+
+// lookup returns the positions of the rows whose value is within r, in index order
+func (idx *index) lookup(r indexRange) []int {
+	start := 0
+	if r.lower != nil {
+		start = sort.Search(len(idx.entries), func(i int) bool {
+			c := compareCells(idx.entries[i].value, r.lower, idx.typ)
+			return c > 0 || (c == 0 && r.lowerInclusive)
+		})
+	}
+
+	end := len(idx.entries)
+	if r.upper != nil {
+		end = sort.Search(len(idx.entries), func(i int) bool {
+			c := compareCells(idx.entries[i].value, r.upper, idx.typ)
+			return c > 0 || (c == 0 && !r.upperInclusive)
+		})
+	}
+
+	positions := []int{}
+	for i := start; i < end; i++ {
+		positions = append(positions, idx.entries[i].row)
+	}
+	return positions
+}
+
+// intersect narrows r to the values that are also within other
+func (r indexRange) intersect(other indexRange, typ ColumnType) indexRange {
+	if other.lower != nil {
+		c := 1
+		if r.lower != nil {
+			c = compareCells(other.lower, r.lower, typ)
+		}
+		if c > 0 {
+			r.lower, r.lowerInclusive = other.lower, other.lowerInclusive
+		} else if c == 0 {
+			r.lowerInclusive = r.lowerInclusive && other.lowerInclusive
+		}
+	}
+
+	if other.upper != nil {
+		c := -1
+		if r.upper != nil {
+			c = compareCells(other.upper, r.upper, typ)
+		}
+		if c < 0 {
+			r.upper, r.upperInclusive = other.upper, other.upperInclusive
+		} else if c == 0 {
+			r.upperInclusive = r.upperInclusive && other.upperInclusive
+		}
+	}
+	return r
+}
+
+// describe writes the range as the condition on column it stands for, like (id >= 1 AND id < 10)
+func (r indexRange) describe(column string, typ ColumnType) string {
+	if r.lower != nil && r.upper != nil && r.lowerInclusive && r.upperInclusive &&
+		compareCells(r.lower, r.upper, typ) == 0 {
+		return fmt.Sprintf("(%s = %s)", column, cellCode(r.lower, typ))
+	}
+
+	conditions := []string{}
+	if r.lower != nil {
+		op := ">"
+		if r.lowerInclusive {
+			op = ">="
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s %s", column, op, cellCode(r.lower, typ)))
+	}
+	if r.upper != nil {
+		op := "<"
+		if r.upperInclusive {
+			op = "<="
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s %s", column, op, cellCode(r.upper, typ)))
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+// cellCode writes a cell the way it would be written as a literal
+func cellCode(cell MemoryCell, typ ColumnType) string {
+	if isArray(typ) {
+		return `"` + strings.ReplaceAll(arrayText(cell), `"`, `""`) + `"`
+	}
+	switch typ {
+	case TextType, JsonType:
+		return `"` + strings.ReplaceAll(cell.AsText(), `"`, `""`) + `"`
+	case UuidType:
+		return `"` + uuidText(cell) + `"`
+	case BoolType:
+		return strings.ToUpper(cellText(cell, typ))
+	case DateType, TimeType, TimestampType, IntervalType:
+		return typ.String() + " '" + cellText(cell, typ) + "'"
+	case ByteaType:
+		return "X'" + hexDigits(cell) + "'"
+	}
+	return cellText(cell, typ)
+}
+
+func (mb *MemoryBackend) CreateIndex(ctx context.Context, ci *CreateIndexStatement) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+
+	t, ok := mb.tables[ci.table.value]
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+
+	// Index names are shared by every table
+	for _, other := range mb.tables {
+		for _, idx := range other.indexes {
+			if idx.name == ci.name.value {
+				return ErrIndexAlreadyExists
+			}
+		}
+	}
+
+	column := -1
+	for i, name := range t.columns {
+		if name == ci.column.value {
+			column = i
+		}
+	}
+	if column < 0 {
+		return fmt.Errorf("%w: %s", ErrColumnDoesNotExist, ci.column.value)
+	}
+
+	t.indexes = append(t.indexes, newIndex(t, ci.name.value, column))
+	return nil
+}
+
+// newIndex builds an index on a column with every row the table already has
+func newIndex(t *table, name string, column int) *index {
+	idx := &index{name: name, column: column, typ: valueType(t.columnTypes[column])}
+	for i, row := range t.rows {
+		idx.add(row[column], i)
+	}
+	return idx
+}
+
+func (mb *MemoryBackend) tableIndexes(name string) []indexInfo {
+	t, ok := mb.tables[name]
+	if !ok {
+		return nil
+	}
+
+	infos := []indexInfo{}
+	for _, idx := range t.indexes {
+		infos = append(infos, indexInfo{name: idx.name, column: t.columns[idx.column]})
+	}
+	return infos
+}
+
+func (mb *MemoryBackend) scanIndex(
+	ctx context.Context, name, indexName string, r indexRange, positions []int,
+) (operator, error) {
+	t, ok := mb.tables[name]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+	cols, err := mb.tableColumns(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, idx := range t.indexes {
+		if idx.name == indexName {
+			return &memoryIndexScan{
+				ctx:       ctx,
+				table:     name,
+				index:     indexName,
+				condition: r.describe(t.columns[idx.column], idx.typ),
+				cols:      pickColumns(cols, positions),
+				positions: positions,
+				rows:      t.rows,
+				found:     idx.lookup(r),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("index %s does not exist on %s", indexName, name)
+}
+
+// memoryIndexScan produces the rows an index found, like memoryScan it doesn't see rows inserted once started
+type memoryIndexScan struct {
+	ctx       context.Context
+	table     string
+	index     string
+	condition string
+	cols      []resultColumn
+	positions []int
+	rows      [][]MemoryCell
+	// The positions of the rows the index found
+	found  []int
+	cursor int
+}
+
+func (s *memoryIndexScan) columns() []resultColumn {
+	return s.cols
+}
+
+func (s *memoryIndexScan) next() ([]MemoryCell, bool, error) {
+	if err := canceled(s.ctx); err != nil {
+		return nil, false, err
+	}
+	if s.cursor >= len(s.found) {
+		return nil, false, nil
+	}
+	s.cursor++
+	return pickColumns(s.rows[s.found[s.cursor-1]], s.positions), true, nil
+}
+
+func (s *memoryIndexScan) close() error {
+	return nil
+}
+
+func (s *memoryIndexScan) describe() string {
+	return fmt.Sprintf("Index Scan using %s on %s %s", s.index, s.table, s.condition)
+}
+
+func (s *memoryIndexScan) children() []operator {
+	return nil
+}
@@ -0,0 +1,183 @@
+package gosql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+JSON
+----
+JSON columns hold documents as they were written, checked to be valid JSON when inserted. They're queried with the
+operators Postgres has for them:
+
+  - doc -> 'key' is the value of a key of an object and doc -> 2 the element of an array at an index counted from
+    0, or from the end when negative. Both give JSON, so they can be chained like doc -> 'items' -> 0.
+  - doc ->> 'key' and doc ->> 2 are the same values as TEXT: strings without their quotes, and anything else as
+    written.
+
+Values that aren't there are NULL, not errors. json_extract(doc, '$.items[0].name') follows a path of keys and
+indexes at once, and json_array_length(doc) counts the elements of an array.
+*/
+
+func isJSONOperator(op string) bool {
+	return op == string(arrowSymbol) || op == string(doubleArrowSymbol)
+}
+
+// parseJSON checks a document is valid JSON
+func parseJSON(s string) (MemoryCell, error) {
+	if !json.Valid([]byte(s)) {
+		return nil, fmt.Errorf("%w: %q is not valid JSON", ErrInvalidDatatype, s)
+	}
+	return MemoryCell(s), nil
+}
+
+// jsonField is the value of a key of an object, false when doc isn't an object or doesn't have it
+func jsonField(doc []byte, key string) (json.RawMessage, bool) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &object); err != nil || object == nil {
+		return nil, false
+	}
+	value, ok := object[key]
+	return value, ok
+}
+
+// jsonElement is the element of an array at an index, negative ones counting from the end
+func jsonElement(doc []byte, i int64) (json.RawMessage, bool) {
+	var array []json.RawMessage
+	if err := json.Unmarshal(doc, &array); err != nil || array == nil {
+		return nil, false
+	}
+	if i < 0 {
+		i += int64(len(array))
+	}
+	if i < 0 || i >= int64(len(array)) {
+		return nil, false
+	}
+	return array[i], true
+}
+
+// jsonText is a value as TEXT, strings being unquoted and null being NULL
+func jsonText(value json.RawMessage) MemoryCell {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return MemoryCell(s)
+	}
+	if bytes.Equal(value, []byte("null")) {
+		return nil
+	}
+	return MemoryCell(value)
+}
+
+// compileJSONOperator compiles -> and ->>, which take a key as TEXT or an index as any integer
+func compileJSONOperator(
+	a evaluator, at ColumnType, b evaluator, bt ColumnType, op string,
+) (evaluator, ColumnType, error) {
+	if at != JsonType || (bt != TextType && !isInteger(bt)) {
+		return nil, 0, fmt.Errorf("%w: %s %s %s", ErrInvalidOperands, at, op, bt)
+	}
+
+	typ := JsonType
+	if op == string(doubleArrowSymbol) {
+		typ = TextType
+	}
+	return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+		var value json.RawMessage
+		var ok bool
+		if bt == TextType {
+			value, ok = jsonField(x, y.AsText())
+		} else {
+			value, ok = jsonElement(x, y.AsInt64())
+		}
+		if !ok {
+			return nil, nil
+		}
+		if typ == TextType {
+			return jsonText(value), nil
+		}
+		return MemoryCell(value), nil
+	}), typ, nil
+}
+
+// parseJSONPath splits a path like $.items[0].name into the keys and indexes it follows, keys being strings and
+// indexes int64s
+func parseJSONPath(path string) ([]any, error) {
+	invalid := fmt.Errorf("%w: %s is not a JSON path", ErrInvalidOperands, path)
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, invalid
+	}
+
+	steps := []any{}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			if end == 1 {
+				return nil, invalid
+			}
+			steps = append(steps, rest[1:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, invalid
+			}
+			i, err := strconv.ParseInt(rest[1:end], 10, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			steps = append(steps, i)
+			rest = rest[end+1:]
+		default:
+			return nil, invalid
+		}
+	}
+	return steps, nil
+}
+
+// compileJSONExtract follows a path into a document, giving NULL when any step isn't there
+func compileJSONExtract(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return binaryEvaluator(args[0], args[1], func(doc, path MemoryCell) (MemoryCell, error) {
+		steps, err := parseJSONPath(path.AsText())
+		if err != nil {
+			return nil, err
+		}
+
+		value := json.RawMessage(doc)
+		for _, step := range steps {
+			var ok bool
+			switch s := step.(type) {
+			case string:
+				value, ok = jsonField(value, s)
+			case int64:
+				value, ok = jsonElement(value, s)
+			}
+			if !ok {
+				return nil, nil
+			}
+		}
+		return MemoryCell(value), nil
+	}), JsonType, nil
+}
+
+// compileJSONArrayLength counts the elements of an array, failing for anything else like Postgres does
+func compileJSONArrayLength(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return func(row []MemoryCell) (MemoryCell, error) {
+		doc, err := args[0](row)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		var array []json.RawMessage
+		if err := json.Unmarshal(doc, &array); err != nil || array == nil {
+			return nil, fmt.Errorf("%w: json_array_length of %s, which is not an array", ErrInvalidOperands, doc)
+		}
+		return integerCell(int64(len(array)), IntType)
+	}, IntType, nil
+}
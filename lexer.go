@@ -16,20 +16,60 @@ type location struct {
 type keyword string
 
 const (
-	selectKeyword keyword = "select"
-	fromKeyword   keyword = "from"
-	asKeyword     keyword = "as"
-	tableKeyword  keyword = "table"
-	createKeyword keyword = "create"
-	insertKeyword keyword = "insert"
-	intoKeyword   keyword = "into"
-	valuesKeyword keyword = "values"
-	intKeyword    keyword = "int"
-	textKeyword   keyword = "text"
-	whereKeyword  keyword = "where"
-	trueKeyword   keyword = "true"
-	falseKeyword  keyword = "false"
-	nullKeyword   keyword = "null"
+	selectKeyword    keyword = "select"
+	fromKeyword      keyword = "from"
+	asKeyword        keyword = "as"
+	tableKeyword     keyword = "table"
+	createKeyword    keyword = "create"
+	insertKeyword    keyword = "insert"
+	intoKeyword      keyword = "into"
+	valuesKeyword    keyword = "values"
+	intKeyword       keyword = "int"
+	textKeyword      keyword = "text"
+	whereKeyword     keyword = "where"
+	trueKeyword      keyword = "true"
+	falseKeyword     keyword = "false"
+	nullKeyword      keyword = "null"
+	andKeyword       keyword = "and"
+	orKeyword        keyword = "or"
+	joinKeyword      keyword = "join"
+	innerKeyword     keyword = "inner"
+	crossKeyword     keyword = "cross"
+	onKeyword        keyword = "on"
+	groupKeyword     keyword = "group"
+	orderKeyword     keyword = "order"
+	byKeyword        keyword = "by"
+	ascKeyword       keyword = "asc"
+	descKeyword      keyword = "desc"
+	explainKeyword   keyword = "explain"
+	analyzeKeyword   keyword = "analyze"
+	indexKeyword     keyword = "index"
+	realKeyword      keyword = "real"
+	floatKeyword     keyword = "float"
+	doubleKeyword    keyword = "double"
+	precisionKeyword keyword = "precision"
+	smallintKeyword  keyword = "smallint"
+	integerKeyword   keyword = "integer"
+	bigintKeyword    keyword = "bigint"
+	numericKeyword   keyword = "numeric"
+	decimalKeyword   keyword = "decimal"
+	dateKeyword      keyword = "date"
+	timeKeyword      keyword = "time"
+	timestampKeyword keyword = "timestamp"
+	intervalKeyword  keyword = "interval"
+	varcharKeyword   keyword = "varchar"
+	charKeyword      keyword = "char"
+	characterKeyword keyword = "character"
+	varyingKeyword   keyword = "varying"
+	byteaKeyword     keyword = "bytea"
+	blobKeyword      keyword = "blob"
+	jsonKeyword      keyword = "json"
+	uuidKeyword      keyword = "uuid"
+	defaultKeyword   keyword = "default"
+	arrayKeyword     keyword = "array"
+	castKeyword      keyword = "cast"
+	boolKeyword      keyword = "bool"
+	booleanKeyword   keyword = "boolean"
 )
 
 // para guardar la sintaxis SQL
@@ -46,10 +86,20 @@ const (
 	neqSymbol2       symbol = "!="
 	concatSymbol     symbol = "||"
 	plusSymbol       symbol = "+"
+	minusSymbol      symbol = "-"
+	slashSymbol      symbol = "/"
 	ltSymbol         symbol = "<"
 	lteSymbol        symbol = "<="
 	gtSymbol         symbol = ">"
 	gteSymbol        symbol = ">="
+	// Of JSON, see json.go
+	arrowSymbol       symbol = "->"
+	doubleArrowSymbol symbol = "->>"
+	// Of arrays, see array.go
+	leftBracketSymbol  symbol = "["
+	rightBracketSymbol symbol = "]"
+	// Of casts, see cast.go
+	doubleColonSymbol symbol = "::"
 )
 
 type tokenKind uint
@@ -62,6 +112,9 @@ const (
 	numericKind
 	boolKind
 	nullKind
+	parameterKind
+	// The digits of a hex literal like X'DEADBEEF'
+	hexKind
 )
 
 type token struct {
@@ -137,7 +190,7 @@ func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
 
 		isDigit := c >= '0' && c <= '9'
 		isPeriod := c == '.'
-		isExpMarker := c == 'e'
+		isExpMarker := c == 'e' || c == 'E'
 
 		slog.Debug("Character", slog.String("char", string(c)))
 		slog.Debug("Pointers", slog.Int("cur pointer", int(cur.pointer)), slog.Int("ic pointer", int(ic.pointer)), slog.Int("cur loc", int(cur.loc.col)))
@@ -192,6 +245,9 @@ func lexNumeric(source string, ic cursor) (*token, cursor, bool) {
 		return nil, ic, false
 	}
 
+	// The loop counted the character that ended the number too
+	cur.loc.col = ic.loc.col + (cur.pointer - ic.pointer)
+
 	slog.Debug("Final Value", slog.Int("cur pointer", int(cur.pointer)), slog.Int("ic pointer", int(ic.pointer)), slog.String("value", source[ic.pointer:cur.pointer]))
 	return &token{
 		value: source[ic.pointer:cur.pointer],
@@ -243,7 +299,29 @@ func lexCharacterDelimited(source string, ic cursor, delimiter byte) (*token, cu
 	return nil, ic, false
 }
 
+// Binary data is written as hex digits between single quotes after an X, like X'DEADBEEF'. The digits are
+// checked when the literal is read as a value.
+func lexHex(source string, ic cursor) (*token, cursor, bool) {
+	if c := source[ic.pointer]; c != 'x' && c != 'X' {
+		return nil, ic, false
+	}
+
+	next := ic
+	next.pointer++
+	next.loc.col++
+	t, cur, ok := lexCharacterDelimited(source, next, '\'')
+	if !ok {
+		return nil, ic, false
+	}
+	t.kind, t.loc = hexKind, ic.loc
+	return t, cur, true
+}
+
+// Strings can be written between double or single quotes, like "abc" or 'abc'
 func lexString(source string, ic cursor) (*token, cursor, bool) {
+	if token, newCursor, ok := lexCharacterDelimited(source, ic, '\''); ok {
+		return token, newCursor, true
+	}
 	return lexCharacterDelimited(source, ic, '"')
 }
 
@@ -279,10 +357,17 @@ func lexSymbol(source string, ic cursor) (*token, cursor, bool) {
 		gtSymbol,
 		gteSymbol,
 		concatSymbol,
+		arrowSymbol,
+		doubleArrowSymbol,
 		plusSymbol,
+		minusSymbol,
+		slashSymbol,
 		commaSymbol,
 		leftParenSymbol,
 		rightParenSymbol,
+		leftBracketSymbol,
+		rightBracketSymbol,
+		doubleColonSymbol,
 		semicolonSymbol,
 		asteriskSymbol,
 	}
@@ -327,6 +412,46 @@ func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
 		falseKeyword,
 		nullKeyword,
 		intKeyword,
+		andKeyword,
+		orKeyword,
+		joinKeyword,
+		innerKeyword,
+		crossKeyword,
+		onKeyword,
+		groupKeyword,
+		orderKeyword,
+		byKeyword,
+		ascKeyword,
+		descKeyword,
+		explainKeyword,
+		analyzeKeyword,
+		indexKeyword,
+		realKeyword,
+		floatKeyword,
+		doubleKeyword,
+		precisionKeyword,
+		smallintKeyword,
+		integerKeyword,
+		bigintKeyword,
+		numericKeyword,
+		decimalKeyword,
+		dateKeyword,
+		timeKeyword,
+		timestampKeyword,
+		intervalKeyword,
+		varcharKeyword,
+		charKeyword,
+		characterKeyword,
+		varyingKeyword,
+		byteaKeyword,
+		blobKeyword,
+		jsonKeyword,
+		uuidKeyword,
+		defaultKeyword,
+		arrayKeyword,
+		castKeyword,
+		boolKeyword,
+		booleanKeyword,
 	}
 
 	var options []string
@@ -339,6 +464,12 @@ func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
 		return nil, ic, false
 	}
 
+	// A keyword followed by more identifier characters is the start of an identifier, like int in internal
+	end := ic.pointer + uint(len(match))
+	if end < uint(len(source)) && isIdentifierChar(source[end]) {
+		return nil, ic, false
+	}
+
 	cur.pointer = ic.pointer + uint(len(match))
 	cur.loc.col = ic.loc.col + uint(len(match))
 
@@ -359,8 +490,63 @@ func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
 
 }
 
+//	A parameter is a placeholder for a value bound when the statement runs. It can be positional, either numbered
+//	like $1 or just ?, or named like :name
+
+func lexParameter(source string, ic cursor) (*token, cursor, bool) {
+	cur := ic
+	c := source[cur.pointer]
+	cur.pointer++
+	cur.loc.col++
+
+	if c == '?' {
+		return &token{
+			value: "?",
+			loc:   ic.loc,
+			kind:  parameterKind,
+		}, cur, true
+	}
+
+	if c != '$' && c != ':' {
+		return nil, ic, false
+	}
+
+	for ; cur.pointer < uint(len(source)); cur.pointer++ {
+		c := source[cur.pointer]
+		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+		isNumeric := c >= '0' && c <= '9'
+
+		// $ takes a position, : takes a name that must start with a letter
+		isValid := isNumeric
+		if source[ic.pointer] == ':' {
+			isValid = isAlphabetical || c == '_' || (isNumeric && cur.pointer > ic.pointer+1)
+		}
+		if !isValid {
+			break
+		}
+		cur.loc.col++
+	}
+
+	if cur.pointer == ic.pointer+1 {
+		return nil, ic, false
+	}
+
+	return &token{
+		value: source[ic.pointer:cur.pointer],
+		loc:   ic.loc,
+		kind:  parameterKind,
+	}, cur, true
+}
+
 //	An identifier is either a double-quoted string or a group of characters starting with an alphabetical character
-//	and possibly containing numbers and underscores
+//	and possibly containing numbers and underscores. Column names can be qualified with their table like users.id,
+//	the whole thing is a single identifier.
+
+func isIdentifierChar(c byte) bool {
+	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+	isNumeric := c >= '0' && c <= '9'
+	return isAlphabetical || isNumeric || c == '$' || c == '_'
+}
 
 func lexIdentifier(source string, ic cursor) (*token, cursor, bool) {
 	// Handle separately if is a double-quoted identifier
@@ -384,9 +570,16 @@ func lexIdentifier(source string, ic cursor) (*token, cursor, bool) {
 		c = source[cur.pointer]
 
 		//Other characters count too, big ignorign non-ascii for now
-		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-		isNumeric := c >= '0' && c <= '9'
-		if isAlphabetical || isNumeric || c == '$' || c == '_' {
+		if isIdentifierChar(c) {
+			value = append(value, c)
+			cur.loc.col++
+			continue
+		}
+
+		// A period only belongs to the identifier when a name follows it
+		next := cur.pointer + 1
+		isQualifier := c == '.' && next < uint(len(source)) && isIdentifierChar(source[next]) && source[next] != '$'
+		if isQualifier {
 			value = append(value, c)
 			cur.loc.col++
 			continue
@@ -419,7 +612,7 @@ func lex(source string) ([]*token, error) {
 
 lex:
 	for cur.pointer < uint(len(source)) {
-		lexers := []lexer{lexKeyword, lexSymbol, lexString, lexNumeric, lexIdentifier}
+		lexers := []lexer{lexKeyword, lexSymbol, lexString, lexHex, lexNumeric, lexParameter, lexIdentifier}
 		for _, l := range lexers {
 			if token, newCursor, ok := l(source, cur); ok {
 				cur = newCursor
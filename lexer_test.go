@@ -66,6 +66,10 @@ func TestToken_lexNumeric(t *testing.T) {
 			number: true,
 			value:  "1e-1",
 		},
+		{
+			number: true,
+			value:  "1.5E3",
+		},
 		{
 			number: true,
 			value:  ".1",
@@ -131,6 +135,14 @@ func TestToken_lexString(t *testing.T) {
 			string: true,
 			value:  "\"a \"\" b\"",
 		},
+		{
+			string: true,
+			value:  "'2024-01-02'",
+		},
+		{
+			string: true,
+			value:  "'a \" b' ",
+		},
 		// false tests
 		{
 			string: false,
@@ -181,6 +193,116 @@ func TestToken_lexSymbol(t *testing.T) {
 	}
 }
 
+func TestToken_lexParameter(t *testing.T) {
+	tests := []struct {
+		parameter bool
+		input     string
+		value     string
+	}{
+		{
+			parameter: true,
+			input:     "$1",
+			value:     "$1",
+		},
+		{
+			parameter: true,
+			input:     "$12)",
+			value:     "$12",
+		},
+		{
+			parameter: true,
+			input:     "?,",
+			value:     "?",
+		},
+		{
+			parameter: true,
+			input:     ":name",
+			value:     ":name",
+		},
+		{
+			parameter: true,
+			input:     ":user_id2 ",
+			value:     ":user_id2",
+		},
+		// false tests
+		{
+			parameter: false,
+			input:     "$",
+		},
+		{
+			parameter: false,
+			input:     "$a",
+		},
+		{
+			parameter: false,
+			input:     ":1",
+		},
+		{
+			parameter: false,
+			input:     "name",
+		},
+	}
+
+	for _, test := range tests {
+		tok, _, ok := lexParameter(test.input, cursor{})
+		assert.Equal(t, test.parameter, ok, test.input)
+		if ok {
+			assert.Equal(t, test.value, tok.value, test.input)
+			assert.Equal(t, parameterKind, tok.kind, test.input)
+		}
+	}
+}
+
+func TestToken_lexHex(t *testing.T) {
+	tests := []struct {
+		hex   bool
+		input string
+		value string
+	}{
+		{
+			hex:   true,
+			input: "X'DEADBEEF'",
+			value: "DEADBEEF",
+		},
+		{
+			hex:   true,
+			input: "x'00ff',",
+			value: "00ff",
+		},
+		{
+			hex:   true,
+			input: "X''",
+			value: "",
+		},
+		// false tests
+		{
+			hex:   false,
+			input: "X",
+		},
+		{
+			hex:   false,
+			input: "x 'ab'",
+		},
+		{
+			hex:   false,
+			input: "xy'ab'",
+		},
+		{
+			hex:   false,
+			input: "'ab'",
+		},
+	}
+
+	for _, test := range tests {
+		tok, _, ok := lexHex(test.input, cursor{})
+		assert.Equal(t, test.hex, ok, test.input)
+		if ok {
+			assert.Equal(t, test.value, tok.value, test.input)
+			assert.Equal(t, hexKind, tok.kind, test.input)
+		}
+	}
+}
+
 func TestToken_lexIdentifier(t *testing.T) {
 	tests := []struct {
 		Identifier bool
@@ -222,6 +344,16 @@ func TestToken_lexIdentifier(t *testing.T) {
 			input:      `"userName"`,
 			value:      "userName",
 		},
+		{
+			Identifier: true,
+			input:      "users.id,",
+			value:      "users.id",
+		},
+		{
+			Identifier: true,
+			input:      "users. id",
+			value:      "users",
+		},
 		// false tests
 		{
 			Identifier: false,
@@ -284,6 +416,14 @@ func TestToken_lexKeyword(t *testing.T) {
 			keyword: false,
 			value:   "flubbrety",
 		},
+		{
+			keyword: false,
+			value:   "internal",
+		},
+		{
+			keyword: false,
+			value:   "orders",
+		},
 	}
 
 	for _, test := range tests {
@@ -437,17 +577,17 @@ func TestLex(t *testing.T) {
 					kind:  numericKind,
 				},
 				{
-					loc:   location{col: 30, line: 0},
+					loc:   location{col: 29, line: 0},
 					value: ",",
 					kind:  symbolKind,
 				},
 				{
-					loc:   location{col: 32, line: 0},
+					loc:   location{col: 31, line: 0},
 					value: "233",
 					kind:  numericKind,
 				},
 				{
-					loc:   location{col: 36, line: 0},
+					loc:   location{col: 34, line: 0},
 					value: ")",
 					kind:  symbolKind,
 				},
@@ -1,10 +1,14 @@
 package gosql
 
 import (
-	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 /*
@@ -16,28 +20,75 @@ Each column will have a name and type. Each row will have a list of byte arrays.
 type MemoryCell []byte
 
 func (mc MemoryCell) AsInt() int32 {
-	var i int32
-	err := binary.Read(bytes.NewBuffer(mc), binary.BigEndian, &i)
-	if err != nil {
-		panic(err)
+	return int32(mc.AsInt64())
+}
+
+// AsInt64 reads the cell of any integer column, which differ in their length
+func (mc MemoryCell) AsInt64() int64 {
+	switch len(mc) {
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(mc)))
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(mc)))
+	case 8:
+		return int64(binary.BigEndian.Uint64(mc))
 	}
-	return i
+	panic(fmt.Sprintf("an integer cell can't have %d bytes", len(mc)))
 }
 
 func (mc MemoryCell) AsText() string {
 	return string(mc)
 }
 
+func (mc MemoryCell) AsBool() bool {
+	return len(mc) > 0 && mc[0] != 0
+}
+
+// AsFloat reads the cell of a REAL or DOUBLE PRECISION column, which differ in their length
+func (mc MemoryCell) AsFloat() float64 {
+	switch len(mc) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(mc)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(mc))
+	}
+	panic(fmt.Sprintf("a float cell can't have %d bytes", len(mc)))
+}
+
 type table struct {
-	columns     []string
-	columnTypes []ColumnType
-	rows        [][]MemoryCell
+	columns         []string
+	columnTypes     []ColumnType
+	columnModifiers []typeModifier
+	// nil for columns without a default
+	columnDefaults []*expression
+	rows           [][]MemoryCell
+	indexes        []*index
+	// Collected by ANALYZE, nil until then
+	stats *tableStats
 }
 
 type MemoryBackend struct {
 	tables map[string]*table
 }
 
+// copyTables copies the tables so that changing the backend leaves the copy as it was. Rows are only ever
+// appended, so the copy shares them and only keeps how many there were, but indexes are sorted in place.
+func (mb *MemoryBackend) copyTables() map[string]*table {
+	tables := map[string]*table{}
+	for name, t := range mb.tables {
+		copied := *t
+		copied.rows = t.rows[:len(t.rows):len(t.rows)]
+		copied.indexes = nil
+		for _, idx := range t.indexes {
+			i := *idx
+			i.entries = append([]indexEntry{}, idx.entries...)
+			copied.indexes = append(copied.indexes, &i)
+		}
+		tables[name] = &copied
+	}
+	return tables
+}
+
 func NewMemoryBackend() *MemoryBackend {
 	return &MemoryBackend{
 		tables: map[string]*table{},
@@ -47,40 +98,206 @@ func NewMemoryBackend() *MemoryBackend {
 /*
 Create Table Support
 --------------------
-When creating a table, we'll make a new entry in the backend tables map. Then we'll create columns as
-specified by the AST
+When creating a table, we'll create columns as specified by the AST. Then we'll make a new entry in the backend
+tables map, failing when there's one already
 */
 
-func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
-	t := table{}
-	mb.tables[crt.name.value] = &t
-	if crt.cols == nil {
-		return nil
+func (mb *MemoryBackend) CreateTable(ctx context.Context, crt *CreateTableStatement) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := mb.tables[crt.name.value]; ok {
+		return ErrTableAlreadyExists
 	}
-	for _, col := range crt.cols {
-		t.columns = append(t.columns, col.name.value)
 
-		var dt ColumnType
-		switch col.datatype.value {
-		case "int":
-			dt = IntType
-		case "text":
-			dt = TextType
-		default:
-			return ErrorInvalidDataType
+	// The table is only added once every column is valid, so a failed statement leaves nothing behind
+	t := table{}
+	for _, col := range crt.cols {
+		dt, mod, err := columnTypeFromDefinition(col)
+		if err != nil {
+			return err
+		}
+		def, err := columnDefault(col, dt, mod)
+		if err != nil {
+			return err
 		}
+		t.columns = append(t.columns, col.name.value)
 		t.columnTypes = append(t.columnTypes, dt)
+		t.columnModifiers = append(t.columnModifiers, mod)
+		t.columnDefaults = append(t.columnDefaults, def)
 	}
+	mb.tables[crt.name.value] = &t
 	return nil
 }
 
+// columnDefault checks the default of a column gives a value it can hold, and returns it for backends to keep
+func columnDefault(col *columnDefinition, typ ColumnType, mod typeModifier) (*expression, error) {
+	def := col.def
+	if def == nil {
+		return nil, nil
+	}
+	// Compiling it rejects columns, which inserting a literal alone wouldn't
+	if _, _, err := compileExpression(def, nil); err != nil {
+		return nil, fmt.Errorf("default of %s: %w", col.name.value, err)
+	}
+	if _, err := expressionToCell(def, typ, mod); err != nil {
+		return nil, fmt.Errorf("default of %s: %w", col.name.value, err)
+	}
+	return def, nil
+}
+
+// typeModifier holds the numbers a column type was given, zero when it had none
+type typeModifier struct {
+	// Of NUMERIC columns
+	precision int
+	scale     int
+	// The most characters a VARCHAR or CHAR column holds
+	length int
+}
+
+// maxLength is the longest VARCHAR or CHAR a column can be declared with
+const maxLength = 10485760
+
+// columnTypeFromDefinition maps the datatype of a column definition to its ColumnType and modifier
+func columnTypeFromDefinition(col *columnDefinition) (ColumnType, typeModifier, error) {
+	// An array takes the modifiers of its elements, like VARCHAR(10)[]
+	if col.array {
+		element := *col
+		element.array = false
+		dt, mod, err := columnTypeFromDefinition(&element)
+		if err != nil {
+			return 0, typeModifier{}, err
+		}
+		return arrayOf(dt), mod, nil
+	}
+
+	dt, err := columnTypeFromToken(col.datatype)
+	if err != nil {
+		return 0, typeModifier{}, err
+	}
+	if len(col.modifiers) == 0 {
+		// CHAR is CHAR(1), a VARCHAR without a length has no limit
+		if dt == CharType {
+			return dt, typeModifier{length: 1}, nil
+		}
+		return dt, typeModifier{}, nil
+	}
+
+	invalid := fmt.Errorf("%w: %s doesn't take these modifiers", ErrorInvalidDataType, dt)
+	numbers := []int{}
+	for _, modifier := range col.modifiers {
+		n, err := strconv.Atoi(modifier.value)
+		if err != nil {
+			return 0, typeModifier{}, invalid
+		}
+		numbers = append(numbers, n)
+	}
+
+	switch dt {
+	case NumericType:
+		if len(numbers) > 2 {
+			return 0, typeModifier{}, invalid
+		}
+		mod := typeModifier{precision: numbers[0]}
+		if len(numbers) == 2 {
+			mod.scale = numbers[1]
+		}
+		if mod.precision < 1 || mod.precision > numericMaxPrecision || mod.scale < 0 || mod.scale > mod.precision {
+			return 0, typeModifier{}, invalid
+		}
+		return dt, mod, nil
+	case VarcharType, CharType:
+		if len(numbers) > 1 || numbers[0] < 1 || numbers[0] > maxLength {
+			return 0, typeModifier{}, invalid
+		}
+		return dt, typeModifier{length: numbers[0]}, nil
+	}
+	return 0, typeModifier{}, invalid
+}
+
+// valueType is the type of the values a column declared with a type holds
+func valueType(typ ColumnType) ColumnType {
+	if isArray(typ) {
+		return arrayOf(valueType(elementType(typ)))
+	}
+	if typ == VarcharType || typ == CharType {
+		return TextType
+	}
+	return typ
+}
+
+// fitText checks a string fits a VARCHAR or CHAR column, padding it for CHAR. Like in Postgres, spaces past the
+// length are dropped instead of failing.
+func fitText(s string, typ ColumnType, mod typeModifier) (MemoryCell, error) {
+	length := utf8.RuneCountInString(s)
+	if mod.length > 0 && length > mod.length {
+		trimmed := strings.TrimRight(s, " ")
+		if utf8.RuneCountInString(trimmed) > mod.length {
+			return nil, fmt.Errorf("%w: %q is longer than %s(%d)", ErrValueTooLong, s, typ, mod.length)
+		}
+		s = trimmed + strings.Repeat(" ", mod.length-utf8.RuneCountInString(trimmed))
+		length = mod.length
+	}
+	if typ == CharType && length < mod.length {
+		s += strings.Repeat(" ", mod.length-length)
+	}
+	return MemoryCell(s), nil
+}
+
+// columnTypeFromToken maps the datatype of a column definition to its ColumnType
+func columnTypeFromToken(datatype token) (ColumnType, error) {
+	switch datatype.value {
+	case "int", "integer":
+		return IntType, nil
+	case "smallint":
+		return SmallIntType, nil
+	case "bigint":
+		return BigIntType, nil
+	case "text":
+		return TextType, nil
+	case "bool", "boolean":
+		return BoolType, nil
+	case "real":
+		return RealType, nil
+	case "float", "double precision":
+		return DoubleType, nil
+	case "numeric", "decimal":
+		return NumericType, nil
+	case "date":
+		return DateType, nil
+	case "time":
+		return TimeType, nil
+	case "timestamp":
+		return TimestampType, nil
+	case "interval":
+		return IntervalType, nil
+	case "bytea", "blob":
+		return ByteaType, nil
+	case "json":
+		return JsonType, nil
+	case "uuid":
+		return UuidType, nil
+	case "varchar", "character varying":
+		return VarcharType, nil
+	case "char", "character":
+		return CharType, nil
+	default:
+		return 0, ErrorInvalidDataType
+	}
+}
+
 /*
 Insert Support
 --------------
-Keeping things simple, we'll assume the value passed can be correctly mapped to the type of the column specified
+Values are read or converted to the type of their column, see expressionToCell, failing for those that can't be
 */
 
-func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
+func (mb *MemoryBackend) Insert(ctx context.Context, inst *InsertStatement) error {
+	if err := canceled(ctx); err != nil {
+		return err
+	}
+
 	table, ok := mb.tables[inst.table.value]
 	if !ok {
 		return ErrTableDoesNotExist
@@ -89,100 +306,280 @@ func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
 		return nil
 	}
 
-	row := []MemoryCell{}
+	row, err := insertedRow(inst, table.columns, table.columnTypes, table.columnModifiers, table.columnDefaults)
+	if err != nil {
+		return err
+	}
+	table.rows = append(table.rows, row)
+	for _, idx := range table.indexes {
+		idx.add(row[idx.column], len(table.rows)-1)
+	}
+	return nil
+}
+
+// insertedRow computes the row an insert adds to a table. Columns the insert leaves out get their default, or
+// NULL when they have none.
+func insertedRow(inst *InsertStatement, columns []string, types []ColumnType, mods []typeModifier,
+	defaults []*expression) ([]MemoryCell, error) {
+	values := inst.values
+	if inst.columns != nil {
+		if len(inst.columns) != len(inst.values) {
+			return nil, ErrMissingValues
+		}
 
-	if len(inst.values) != len(table.columns) {
-		return ErrMissingValues
+		values = make([]*expression, len(columns))
+		for i, column := range inst.columns {
+			found := false
+			for j, name := range columns {
+				if name != column.value {
+					continue
+				}
+				if values[j] != nil {
+					return nil, fmt.Errorf("%w: %s", ErrDuplicateColumn, name)
+				}
+				values[j], found = inst.values[i], true
+			}
+			if !found {
+				return nil, fmt.Errorf("%w: %s", ErrColumnDoesNotExist, column.value)
+			}
+		}
+	} else if len(values) != len(columns) {
+		return nil, ErrMissingValues
 	}
 
-	for _, value := range inst.values {
-		if value.kind != literalKind {
-			fmt.Println("Skipping non-literal.")
+	row := []MemoryCell{}
+	for i, value := range values {
+		if value == nil {
+			value = defaults[i]
+		}
+		if value == nil {
+			row = append(row, nil)
 			continue
 		}
-		row = append(row, mb.tokenToCell(value.literal))
+		cell, err := expressionToCell(value, types[i], mods[i])
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, cell)
 	}
-	table.rows = append(table.rows, row)
-	return nil
+	return row, nil
 }
 
-// tokenToCell helper will write numbers as binary bytes and will write strings as bytes
-func (mb *MemoryBackend) tokenToCell(t *token) MemoryCell {
-	if t.kind == numericKind {
-		buf := new(bytes.Buffer)
-		i, err := strconv.Atoi(t.value)
-		if err != nil {
-			panic(err)
+// tokenToCell reads a literal inserted into a column. Strings are read as a value of the column's type, see
+// textToCell, which is how NUMERIC values can be passed exactly as parameters. Numbers are written in the width of
+// the column and only go into number columns, hex literals only go into BYTEA columns.
+func tokenToCell(t *token, typ ColumnType, mod typeModifier) (MemoryCell, error) {
+	invalid := fmt.Errorf("%w: %s is not a %s", ErrInvalidDatatype, t.value, typ)
+	switch t.kind {
+	case nullKind:
+		return nil, nil
+	case stringKind:
+		return textToCell(t.value, typ, mod)
+	case hexKind:
+		if typ != ByteaType {
+			return nil, fmt.Errorf("%w: X'%s' is not a %s", ErrInvalidDatatype, t.value, typ)
+		}
+		return parseHex(t.value)
+	case boolKind:
+		if typ != BoolType {
+			return nil, invalid
+		}
+		return boolCell(t.value == string(trueKeyword)), nil
+	case numericKind:
+		if !isNumeric(typ) {
+			return nil, invalid
+		}
+		// Integer columns don't take numbers with a decimal point or an exponent, even when they're whole
+		if isInteger(typ) {
+			i, err := parseInteger(t.value)
+			if err != nil {
+				return nil, err
+			}
+			return integerCell(i, typ)
+		}
+		return textToCell(t.value, typ, mod)
+	case identifierKind:
+		return nil, fmt.Errorf("%w: %s, values can't refer to columns", ErrColumnDoesNotExist, t.value)
+	}
+	return nil, invalid
+}
+
+// expressionToCell computes an inserted value for a column. Literals are read as the type of the column, anything
+// else, like DATE '2024-01-02' or 1 + 2, is evaluated and must have a type with an assignment coercion to it, see
+// coercionOf.
+func expressionToCell(exp *expression, typ ColumnType, mod typeModifier) (MemoryCell, error) {
+	if exp.kind == literalKind {
+		return tokenToCell(exp.literal, typ, mod)
+	}
+	if exp.kind == arrayKind && isArray(typ) {
+		return arrayToCell(exp.array, typ, mod)
+	}
+
+	eval, t, err := compileExpression(exp, nil)
+	if err != nil {
+		return nil, err
+	}
+	if t != valueType(typ) {
+		if coercionOf(t, typ) < assignmentCoercion {
+			return nil, fmt.Errorf("%w: can't insert a %s into a %s column", ErrInvalidDatatype, t, typ)
 		}
-		err = binary.Write(buf, binary.BigEndian, int32(i))
+		eval = castEvaluator(eval, t, valueType(typ))
+	}
+
+	cell, err := eval(nil)
+	if err != nil || cell == nil {
+		return cell, err
+	}
+	return fitCell(cell, typ, mod)
+}
+
+// fitCell checks a value fits the modifiers of a NUMERIC, VARCHAR or CHAR column, or of each element of an array of
+// them
+func fitCell(cell MemoryCell, typ ColumnType, mod typeModifier) (MemoryCell, error) {
+	switch typ {
+	case NumericType:
+		d, err := decodeNumeric(cell).fit(mod)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		return MemoryCell(buf.Bytes())
+		return numericCell(d), nil
+	case VarcharType, CharType:
+		return fitText(cell.AsText(), typ, mod)
 	}
-	if t.kind == stringKind {
-		return MemoryCell(t.value)
+	if !isArray(typ) {
+		return cell, nil
 	}
-	return nil
+
+	t, elements := decodeArray(cell)
+	fitted := []MemoryCell{}
+	for _, element := range elements {
+		if element != nil {
+			var err error
+			if element, err = fitCell(element, elementType(typ), mod); err != nil {
+				return nil, err
+			}
+		}
+		fitted = append(fitted, element)
+	}
+	return arrayCell(t, fitted), nil
 }
 
 /*
 Select Support
 --------------
-For select we'll iterate over each row in the table and return the cells according to the columns specified by teh AST
-
+For select we'll iterate over each row in the table and return the cells according to the columns specified by teh AST.
+The planner decides what happens to the rows on the way, see plan.go, and they are produced as they're read, see
+exec.go. All the backend provides is the scan of a table.
 */
 
-func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
-	table, ok := mb.tables[slct.from.value]
+func (mb *MemoryBackend) Select(ctx context.Context, slct *SelectStatement) (*Rows, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+
+	op, err := planSelect(ctx, slct, mb)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(op), nil
+}
+
+func (mb *MemoryBackend) Explain(ctx context.Context, expl *ExplainStatement) (*Rows, error) {
+	if err := canceled(ctx); err != nil {
+		return nil, err
+	}
+	return explain(ctx, expl, mb)
+}
+
+func (mb *MemoryBackend) Analyze(ctx context.Context, anlz *AnalyzeStatement) error {
+	names := []string{}
+	if anlz.table != nil {
+		if _, ok := mb.tables[anlz.table.value]; !ok {
+			return ErrTableDoesNotExist
+		}
+		names = append(names, anlz.table.value)
+	} else {
+		for name := range mb.tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		stats, err := analyzeTable(ctx, mb, name)
+		if err != nil {
+			return err
+		}
+		mb.tables[name].stats = stats
+	}
+	return nil
+}
+
+func (mb *MemoryBackend) tableStats(name string) *tableStats {
+	if t, ok := mb.tables[name]; ok {
+		return t.stats
+	}
+	return nil
+}
+
+func (mb *MemoryBackend) tableColumns(name string) ([]resultColumn, error) {
+	t, ok := mb.tables[name]
 	if !ok {
 		return nil, ErrTableDoesNotExist
 	}
 
-	results := [][]Cell{}
-	columns := []struct {
-		Type ColumnType
-		Name string
-	}{}
+	cols := []resultColumn{}
+	for i, name := range t.columns {
+		cols = append(cols, resultColumn{name: name, typ: valueType(t.columnTypes[i]), char: t.columnTypes[i] == CharType})
+	}
+	return cols, nil
+}
+
+func (mb *MemoryBackend) scanTable(ctx context.Context, name string, positions []int) (operator, error) {
+	t, ok := mb.tables[name]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+	cols, err := mb.tableColumns(name)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryScan{ctx: ctx, table: name, cols: pickColumns(cols, positions), positions: positions, rows: t.rows}, nil
+}
 
-	for i, row := range table.rows {
-		result := []Cell{}
-		isFirstRow := i == 0
+// memoryScan walks the rows a table had when the scan started, rows inserted afterwards aren't seen
+type memoryScan struct {
+	ctx       context.Context
+	table     string
+	cols      []resultColumn
+	positions []int
+	rows      [][]MemoryCell
+	index     int
+}
 
-		for _, exp := range slct.item {
-			if exp.kind != literalKind {
-				// Unsupported, doesn't currently exit, ignore.
-				fmt.Println("Skipping non-literal expression.")
-				continue
-			}
+func (s *memoryScan) columns() []resultColumn {
+	return s.cols
+}
 
-			lit := exp.literal
-			if lit.kind == identifierKind {
-				found := false
-				for i, tableCol := range table.columns {
-					if tableCol == lit.value {
-						if isFirstRow {
-							columns = append(columns, struct {
-								Type ColumnType
-								Name string
-							}{Type: table.columnTypes[i], Name: lit.value})
-						}
-						result = append(result, row[i])
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil, ErrColumnDoesNotExist
-				}
-				continue
-			}
-			return nil, ErrColumnDoesNotExist
-		}
-		results = append(results, result)
+func (s *memoryScan) next() ([]MemoryCell, bool, error) {
+	if err := canceled(s.ctx); err != nil {
+		return nil, false, err
+	}
+	if s.index >= len(s.rows) {
+		return nil, false, nil
 	}
-	return &Results{
-		Columns: columns,
-		Rows:    results,
-	}, nil
+	s.index++
+	return pickColumns(s.rows[s.index-1], s.positions), true, nil
+}
+
+func (s *memoryScan) close() error {
+	return nil
+}
+
+func (s *memoryScan) describe() string {
+	return "Seq Scan on " + s.table
+}
+
+func (s *memoryScan) children() []operator {
+	return nil
 }
@@ -0,0 +1,204 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+/*
+Numerics
+--------
+NUMERIC (or DECIMAL) numbers are exact. They're kept as an integer of any size and a scale, the number of digits
+after the point, so 12.50 is 1250 with scale 2. A cell holds the scale followed by the sign and the magnitude of
+that integer:
+
+	$scale uint16 $negative byte $magnitude...
+
+A column can limit them with a precision, the number of digits in total, and a scale: NUMERIC(5, 2) holds up to
+999.99. Values are rounded to the scale of their column on insert, half away from zero, and fail if they still
+have too many digits. NUMERIC(5) has scale 0, and a plain NUMERIC keeps any value as written.
+
+Adding or subtracting keeps the larger scale and multiplying adds them up, so the results are exact. Dividing
+rounds to at least numericDivisionScale digits. Numerics combined with integers stay NUMERIC, combined with floats
+they are converted to DOUBLE PRECISION. Number literals like 0.1 are read as NUMERICs when combined with or
+compared with one, so amt + 0.1 is exact.
+*/
+
+const (
+	numericMaxPrecision  = 1000
+	numericDivisionScale = 16
+)
+
+type decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// parseNumeric reads a number like 12, -0.50 or 1.5e3 exactly
+func parseNumeric(s string) (decimal, error) {
+	invalid := fmt.Errorf("%w: %s is not a NUMERIC", ErrInvalidDatatype, s)
+
+	mantissa, exponent := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		mantissa = s[:i]
+		if exponent, err = strconv.Atoi(s[i+1:]); err != nil {
+			return decimal{}, invalid
+		}
+	}
+
+	digits, scale := mantissa, 0
+	if i := strings.Index(mantissa, "."); i >= 0 {
+		digits, scale = mantissa[:i]+mantissa[i+1:], len(mantissa)-i-1
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return decimal{}, invalid
+	}
+
+	d := decimal{unscaled: unscaled, scale: scale - exponent}
+	if d.scale < 0 {
+		return d.rescale(0), nil
+	}
+	if d.scale > 0xFFFF {
+		return decimal{}, invalid
+	}
+	return d, nil
+}
+
+func integerDecimal(i int64) decimal {
+	return decimal{unscaled: big.NewInt(i)}
+}
+
+func numericCell(d decimal) MemoryCell {
+	cell := binary.BigEndian.AppendUint16(nil, uint16(d.scale))
+	if d.unscaled.Sign() < 0 {
+		cell = append(cell, 1)
+	} else {
+		cell = append(cell, 0)
+	}
+	return append(cell, d.unscaled.Bytes()...)
+}
+
+func decodeNumeric(cell MemoryCell) decimal {
+	unscaled := new(big.Int).SetBytes(cell[3:])
+	if cell[2] == 1 {
+		unscaled.Neg(unscaled)
+	}
+	return decimal{unscaled: unscaled, scale: int(binary.BigEndian.Uint16(cell))}
+}
+
+// AsNumeric reads the cell of a NUMERIC column
+func (mc MemoryCell) AsNumeric() *big.Rat {
+	d := decodeNumeric(mc)
+	return new(big.Rat).SetFrac(d.unscaled, pow10(d.scale))
+}
+
+func (d decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+func (d decimal) float() float64 {
+	f, _ := new(big.Rat).SetFrac(d.unscaled, pow10(d.scale)).Float64()
+	return f
+}
+
+// rescale changes the number of digits after the point, rounding half away from zero when dropping some
+func (d decimal) rescale(scale int) decimal {
+	if scale >= d.scale {
+		return decimal{unscaled: new(big.Int).Mul(d.unscaled, pow10(scale-d.scale)), scale: scale}
+	}
+	return decimal{unscaled: roundedQuotient(d.unscaled, pow10(d.scale-scale)), scale: scale}
+}
+
+// roundedQuotient divides rounding half away from zero
+func roundedQuotient(x, y *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	// Twice the remainder is at least the divisor when the dropped part is half or more
+	if r.Sign() != 0 && new(big.Int).Abs(new(big.Int).Lsh(r, 1)).CmpAbs(y) >= 0 {
+		if x.Sign()*y.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func (d decimal) cmp(e decimal) int {
+	scale := max(d.scale, e.scale)
+	return d.rescale(scale).unscaled.Cmp(e.rescale(scale).unscaled)
+}
+
+// fit rounds d to the scale of a column and checks it has no more digits than its precision
+func (d decimal) fit(mod typeModifier) (decimal, error) {
+	if mod.precision == 0 {
+		return d, nil
+	}
+
+	d = d.rescale(mod.scale)
+	if len(new(big.Int).Abs(d.unscaled).String()) > mod.precision {
+		return decimal{}, fmt.Errorf("%w: %s doesn't fit in NUMERIC(%d, %d)", ErrNumericOutOfRange, d, mod.precision,
+			mod.scale)
+	}
+	return d, nil
+}
+
+// numericArithmeticEvaluator computes on NUMERICs exactly, except for the rounding of divisions
+func numericArithmeticEvaluator(a, b evaluator, op string) evaluator {
+	return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+		i, j := decodeNumeric(x), decodeNumeric(y)
+		var result decimal
+		switch op {
+		case string(plusSymbol), string(minusSymbol):
+			scale := max(i.scale, j.scale)
+			i, j = i.rescale(scale), j.rescale(scale)
+			result = decimal{unscaled: new(big.Int).Add(i.unscaled, j.unscaled), scale: scale}
+			if op == string(minusSymbol) {
+				result.unscaled.Sub(i.unscaled, j.unscaled)
+			}
+		case string(asteriskSymbol):
+			result = decimal{unscaled: new(big.Int).Mul(i.unscaled, j.unscaled), scale: i.scale + j.scale}
+		case string(slashSymbol):
+			var err error
+			if result, err = divideNumeric(i, j); err != nil {
+				return nil, err
+			}
+		}
+
+		if result.scale > 0xFFFF {
+			return nil, fmt.Errorf("%w: %s has too many digits after the point", ErrNumericOutOfRange, op)
+		}
+		return numericCell(result), nil
+	})
+}
+
+func divideNumeric(i, j decimal) (decimal, error) {
+	if j.unscaled.Sign() == 0 {
+		return decimal{}, ErrDivisionByZero
+	}
+
+	// i / j with the given scale is i * 10^(scale - i.scale + j.scale) / j, rounded
+	scale := max(numericDivisionScale, i.scale, j.scale)
+	x := new(big.Int).Mul(i.unscaled, pow10(scale-i.scale+j.scale))
+	return decimal{unscaled: roundedQuotient(x, j.unscaled), scale: scale}, nil
+}
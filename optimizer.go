@@ -0,0 +1,487 @@
+package gosql
+
+import (
+	"math"
+	"math/bits"
+)
+
+/*
+Optimizer
+---------
+The tables of a select and the conditions of its WHERE and JOIN ON clauses are planned together. Conditions are
+split on AND and every part goes as far down as the tables it refers to allow:
+
+  - A condition on a single table filters its rows while they're scanned. When the table has an index on a column
+    compared with a constant, like id > 10, the index may find those rows instead, see index.go.
+  - A condition on several tables is checked by the lowest join that has all of them.
+
+Inner joins can be done in any order, so every order is costed and the cheapest one is kept. A nested loop join
+costs reading both sides, keeping the right one in memory and comparing every pair of rows, so the right side
+should be the smaller one. How many rows each step produces is estimated with the statistics ANALYZE collected,
+see stats.go. When costs are the same, tables are joined in the order they were written.
+*/
+
+const (
+	// Past this many tables trying every join order takes too long and tables are joined as written
+	maxReorderedTables = 10
+	// Reading a row through an index costs more than reading the next row of a scan
+	indexRowCost = 1.5
+)
+
+// conjunct is one of the conditions ANDed together in the WHERE and JOIN ON of a select
+type conjunct struct {
+	exp *expression
+	// A bit for every table the condition refers to
+	tables uint
+}
+
+// candidate is a plan for joining some of the tables, with the rows it's expected to produce and what it costs
+type candidate struct {
+	node   planNode
+	tables uint
+	rows   float64
+	cost   float64
+}
+
+type optimizer struct {
+	c      catalog
+	tables []*scanNode
+	stats  []*tableStats
+	// The columns of every table in the order they were written, and the table each comes from
+	cols   []resultColumn
+	owners []int
+	// Where the columns of every table start in cols
+	offsets   []int
+	conjuncts []*conjunct
+}
+
+func newOptimizer(c catalog, tables []*scanNode) *optimizer {
+	o := &optimizer{c: c, tables: tables}
+	for i, table := range tables {
+		o.stats = append(o.stats, c.tableStats(table.table))
+		o.offsets = append(o.offsets, len(o.cols))
+		for _, col := range table.cols {
+			o.cols = append(o.cols, col)
+			o.owners = append(o.owners, i)
+		}
+	}
+	return o
+}
+
+// optimizeJoins plans the tables and join conditions of a select along with its WHERE
+func optimizeJoins(c catalog, slct *SelectStatement, tables []*scanNode) (planNode, error) {
+	o := newOptimizer(c, tables)
+
+	// A join condition can only refer to the tables up to its join
+	for i, join := range slct.joins {
+		if join.on == nil {
+			continue
+		}
+		cols := o.cols[:o.offsets[i+1]+len(tables[i+1].cols)]
+		if _, err := compileCondition(join.on, cols); err != nil {
+			return nil, err
+		}
+		if err := o.addConditions(simplifyCondition(join.on), cols); err != nil {
+			return nil, err
+		}
+	}
+
+	if slct.where != nil {
+		if _, err := compileCondition(slct.where, o.cols); err != nil {
+			return nil, err
+		}
+		if err := o.addConditions(simplifyCondition(slct.where), o.cols); err != nil {
+			return nil, err
+		}
+	}
+
+	return o.plan(), nil
+}
+
+// addConditions splits a condition on AND, finding the tables every part refers to when its columns are resolved
+// against cols. Columns that would be ambiguous once every table is joined get qualified, parts that are always
+// true are dropped.
+func (o *optimizer) addConditions(exp *expression, cols []resultColumn) error {
+	for _, part := range splitConjuncts(exp, nil) {
+		if isBoolLiteral(part, true) {
+			continue
+		}
+
+		cj := &conjunct{}
+		qualified, err := mapExpression(part, func(lit *expression) (*expression, error) {
+			if lit.literal.kind != identifierKind {
+				return lit, nil
+			}
+
+			i, err := resolveColumn(cols, lit.literal.value)
+			if err != nil {
+				return nil, err
+			}
+			cj.tables |= 1 << o.owners[i]
+
+			if _, err := resolveColumn(o.cols, lit.literal.value); err == nil {
+				return lit, nil
+			}
+			literal := *lit.literal
+			literal.value = cols[i].table + "." + cols[i].name
+			return &expression{kind: literalKind, literal: &literal}, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		cj.exp = qualified
+		o.conjuncts = append(o.conjuncts, cj)
+	}
+	return nil
+}
+
+func splitConjuncts(exp *expression, parts []*expression) []*expression {
+	if isKeywordOperator(exp, andKeyword) {
+		parts = splitConjuncts(exp.binary.a, parts)
+		return splitConjuncts(exp.binary.b, parts)
+	}
+	return append(parts, exp)
+}
+
+func isKeywordOperator(exp *expression, kw keyword) bool {
+	op := tokenFromKeyword(kw)
+	return exp.kind == binaryKind && exp.binary.op.equals(&op)
+}
+
+// joinConjuncts ANDs conditions back together, nil when there are none
+func joinConjuncts(parts []*expression) *expression {
+	var exp *expression
+	for _, part := range parts {
+		if exp == nil {
+			exp = part
+			continue
+		}
+		and := tokenFromKeyword(andKeyword)
+		exp = &expression{kind: binaryKind, binary: &binaryExpression{a: exp, b: part, op: and}}
+	}
+	return exp
+}
+
+func (o *optimizer) plan() planNode {
+	n := len(o.tables)
+	all := uint(1)<<n - 1
+
+	paths := []*candidate{}
+	best := map[uint]*candidate{}
+	for i := range o.tables {
+		paths = append(paths, o.accessPath(i))
+		best[1<<i] = paths[i]
+	}
+
+	if n > maxReorderedTables {
+		joined := paths[0]
+		for _, path := range paths[1:] {
+			joined = o.join(joined, path)
+		}
+		best[all] = joined
+	} else {
+		// Every subset of tables comes before the sets containing it, so their best plans are known by then.
+		// Later tables are tried on the right first, which is the written order.
+		for set := uint(1); set <= all; set++ {
+			if bits.OnesCount(set) < 2 {
+				continue
+			}
+			for i := n - 1; i >= 0; i-- {
+				if set&(1<<i) == 0 {
+					continue
+				}
+				joined := o.join(best[set&^(1<<i)], best[1<<i])
+				if best[set] == nil || joined.cost < best[set].cost {
+					best[set] = joined
+				}
+			}
+		}
+	}
+
+	// Conditions without columns, like 1 = 1, are checked once everything is joined
+	constant := []*expression{}
+	for _, cj := range o.conjuncts {
+		if cj.tables == 0 {
+			constant = append(constant, cj.exp)
+		}
+	}
+	if len(constant) > 0 {
+		return &filterNode{child: best[all].node, condition: joinConjuncts(constant)}
+	}
+	return best[all].node
+}
+
+// join joins two candidates, checking the conditions that need tables from both
+func (o *optimizer) join(left, right *candidate) *candidate {
+	tables := left.tables | right.tables
+	conditions := []*expression{}
+	selectivity := 1.0
+	for _, cj := range o.conjuncts {
+		if cj.tables == 0 || cj.tables&^tables != 0 || cj.tables&^left.tables == 0 || cj.tables&^right.tables == 0 {
+			continue
+		}
+		conditions = append(conditions, cj.exp)
+		selectivity *= o.selectivity(cj.exp)
+	}
+
+	return &candidate{
+		node:   &joinNode{left: left.node, right: right.node, condition: joinConjuncts(conditions)},
+		tables: tables,
+		rows:   left.rows * right.rows * selectivity,
+		cost:   left.cost + right.cost + right.rows + left.rows*right.rows,
+	}
+}
+
+// accessPath picks how to read a table, scanning it or going through one of its indexes, and filters it with the
+// conditions on it alone
+func (o *optimizer) accessPath(i int) *candidate {
+	table := o.tables[i]
+	rows := o.tableRows(i)
+
+	local := []*expression{}
+	selectivity := 1.0
+	for _, cj := range o.conjuncts {
+		if cj.tables == 1<<i {
+			local = append(local, cj.exp)
+			selectivity *= o.selectivity(cj.exp)
+		}
+	}
+
+	var node planNode = table
+	remaining := local
+	cost := rows
+	for _, info := range o.c.tableIndexes(table.table) {
+		r, used, ok := o.indexRange(i, info.column, local)
+		if !ok {
+			continue
+		}
+
+		matched := rows
+		for _, exp := range used {
+			matched *= o.selectivity(exp)
+		}
+		if indexCost := math.Log2(rows+2) + matched*indexRowCost; indexCost < cost {
+			node = &indexScanNode{table: table.table, index: info.name, cols: table.cols, positions: table.positions, r: r}
+			cost = indexCost
+			remaining = withoutExpressions(local, used)
+		}
+	}
+
+	if len(remaining) > 0 {
+		node = &filterNode{child: node, condition: joinConjuncts(remaining)}
+	}
+	return &candidate{node: node, tables: 1 << i, rows: rows * selectivity, cost: cost}
+}
+
+func withoutExpressions(exps, removed []*expression) []*expression {
+	kept := []*expression{}
+outer:
+	for _, exp := range exps {
+		for _, r := range removed {
+			if exp == r {
+				continue outer
+			}
+		}
+		kept = append(kept, exp)
+	}
+	return kept
+}
+
+// indexRange combines the conditions comparing the given column of a table with a constant into the range of
+// values an index scan has to read, also returning the conditions it covers
+func (o *optimizer) indexRange(table int, column string, conditions []*expression) (indexRange, []*expression, bool) {
+	var r indexRange
+	used := []*expression{}
+	for _, exp := range conditions {
+		col, value, op, ok := o.comparison(exp)
+		if !ok || value == nil || o.owners[col] != table || o.cols[col].name != column {
+			continue
+		}
+		// Indexes keep CHAR values padded, while comparisons ignore the padding
+		if o.cols[col].char {
+			continue
+		}
+
+		bound := indexRange{}
+		switch op {
+		case string(eqSymbol):
+			bound = indexRange{lower: value, lowerInclusive: true, upper: value, upperInclusive: true}
+		case string(gtSymbol), string(gteSymbol):
+			bound = indexRange{lower: value, lowerInclusive: op == string(gteSymbol)}
+		case string(ltSymbol), string(lteSymbol):
+			bound = indexRange{upper: value, upperInclusive: op == string(lteSymbol)}
+		default:
+			continue
+		}
+		r = r.intersect(bound, o.cols[col].typ)
+		used = append(used, exp)
+	}
+	return r, used, len(used) > 0
+}
+
+// comparison recognizes a column compared with a constant, returning the position of the column, the value of
+// the constant and the operator as if the column was on the left
+func (o *optimizer) comparison(exp *expression) (int, MemoryCell, string, bool) {
+	if exp.kind != binaryKind {
+		return 0, nil, "", false
+	}
+
+	flipped := map[string]string{
+		string(eqSymbol):   string(eqSymbol),
+		string(neqSymbol):  string(neqSymbol),
+		string(neqSymbol2): string(neqSymbol2),
+		string(ltSymbol):   string(gtSymbol),
+		string(lteSymbol):  string(gteSymbol),
+		string(gtSymbol):   string(ltSymbol),
+		string(gteSymbol):  string(lteSymbol),
+	}
+	op := exp.binary.op.value
+	if _, ok := flipped[op]; !ok || exp.binary.op.kind != symbolKind {
+		return 0, nil, "", false
+	}
+
+	columnSide, constantSide := exp.binary.a, exp.binary.b
+	col, ok := o.column(columnSide)
+	if !ok {
+		columnSide, constantSide = constantSide, columnSide
+		op = flipped[op]
+		if col, ok = o.column(columnSide); !ok {
+			return 0, nil, "", false
+		}
+	}
+
+	value, ok := o.constant(constantSide, o.cols[col].typ)
+	if !ok {
+		return 0, nil, "", false
+	}
+	return col, value, op, true
+}
+
+// column returns the position of the column exp refers to when it's just a column
+func (o *optimizer) column(exp *expression) (int, bool) {
+	if exp.kind != literalKind || exp.literal.kind != identifierKind {
+		return 0, false
+	}
+	i, err := resolveColumn(o.cols, exp.literal.value)
+	return i, err == nil
+}
+
+// constant evaluates an expression without columns, ok is false when it has any or isn't of the type given
+func (o *optimizer) constant(exp *expression, typ ColumnType) (MemoryCell, bool) {
+	if isNullLiteral(exp) {
+		return nil, true
+	}
+
+	eval, t, err := compileExpression(exp, nil)
+	if err != nil {
+		return nil, false
+	}
+	if eval, t, err = coerceLiteral(exp, eval, t, typ); err != nil {
+		return nil, false
+	}
+	// Values compare with columns of wider types, like an INT with a DOUBLE PRECISION column or a DATE with a
+	// TIMESTAMP one, and integers with any integer column they fit
+	narrowed := isInteger(t) && isInteger(typ)
+	if !narrowed && !converts(t, typ) {
+		return nil, false
+	}
+	eval = convertEvaluator(eval, t, typ)
+	value, err := eval(nil)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (o *optimizer) tableRows(i int) float64 {
+	if o.stats[i] == nil {
+		return defaultTableRows
+	}
+	return float64(o.stats[i].rows)
+}
+
+// columnStats returns the statistics of a column of cols, nil when its table wasn't analyzed
+func (o *optimizer) columnStats(col int) (*columnStats, int) {
+	stats := o.stats[o.owners[col]]
+	if stats == nil {
+		return nil, 0
+	}
+	return &stats.columns[col-o.offsets[o.owners[col]]], stats.rows
+}
+
+// selectivity estimates the share of rows a condition is true for
+func (o *optimizer) selectivity(exp *expression) float64 {
+	if exp.kind != binaryKind {
+		return defaultSelectivity
+	}
+
+	switch {
+	case isKeywordOperator(exp, andKeyword):
+		return o.selectivity(exp.binary.a) * o.selectivity(exp.binary.b)
+	case isKeywordOperator(exp, orKeyword):
+		a, b := o.selectivity(exp.binary.a), o.selectivity(exp.binary.b)
+		return a + b - a*b
+	}
+
+	// Two columns being equal, like in most join conditions
+	if a, ok := o.column(exp.binary.a); ok && exp.binary.op.value == string(eqSymbol) {
+		if b, ok := o.column(exp.binary.b); ok {
+			distinct := 0
+			for _, col := range []int{a, b} {
+				if cs, _ := o.columnStats(col); cs != nil && cs.distinct > distinct {
+					distinct = cs.distinct
+				}
+			}
+			if distinct == 0 {
+				return defaultEqualSelectivity
+			}
+			return 1 / float64(distinct)
+		}
+	}
+
+	col, value, op, ok := o.comparison(exp)
+	if !ok {
+		switch exp.binary.op.value {
+		case string(eqSymbol):
+			return defaultEqualSelectivity
+		case string(ltSymbol), string(lteSymbol), string(gtSymbol), string(gteSymbol):
+			return defaultRangeSelectivity
+		}
+		return defaultSelectivity
+	}
+	if value == nil {
+		// Comparing with NULL is never true
+		return 0
+	}
+
+	cs, rows := o.columnStats(col)
+	if cs == nil {
+		switch op {
+		case string(eqSymbol):
+			return defaultEqualSelectivity
+		case string(neqSymbol), string(neqSymbol2):
+			return 1 - defaultEqualSelectivity
+		}
+		return defaultRangeSelectivity
+	}
+
+	equal := cs.equal(rows)
+	less := cs.less(value, rows)
+	var s float64
+	switch op {
+	case string(eqSymbol):
+		s = equal
+	case string(neqSymbol), string(neqSymbol2):
+		s = cs.nonNull(rows) - equal
+	case string(ltSymbol):
+		s = less
+	case string(lteSymbol):
+		s = less + equal
+	case string(gtSymbol):
+		s = cs.nonNull(rows) - less - equal
+	case string(gteSymbol):
+		s = cs.nonNull(rows) - less
+	}
+	return math.Max(0, math.Min(1, s))
+}
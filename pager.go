@@ -0,0 +1,262 @@
+package gosql
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+/*
+Pager and Buffer Pool
+---------------------
+The disk backend stores everything in a single file split in fixed-size pages. The pager reads and writes whole
+pages by id, and the buffer pool keeps a bounded number of them in memory, evicting the least recently used page
+that nobody is holding when it needs room for another one.
+
+Pages changed by the running statement are pending until it commits. With a write-ahead log pending pages are
+never evicted, since the database file must not see them before the log does, and rolling back restores the
+content they had when the statement started.
+*/
+
+const pageSize = 4096
+
+type pageID uint32
+
+var (
+	ErrBufferPoolFull = errors.New("Buffer pool is full")
+	ErrCorruptPage    = errors.New("Page is corrupt")
+)
+
+type page struct {
+	id    pageID
+	data  [pageSize]byte
+	dirty bool
+	pins  int
+}
+
+type pager struct {
+	file     *os.File
+	numPages uint32
+}
+
+func openPager(path string) (*pager, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size()%pageSize != 0 {
+		f.Close()
+		return nil, fmt.Errorf("%w: file size %d is not a multiple of the page size", ErrCorruptPage, info.Size())
+	}
+
+	return &pager{
+		file:     f,
+		numPages: uint32(info.Size() / pageSize),
+	}, nil
+}
+
+func (p *pager) read(id pageID, buf *[pageSize]byte) error {
+	if uint32(id) >= p.numPages {
+		return fmt.Errorf("%w: page %d out of range", ErrCorruptPage, id)
+	}
+	_, err := p.file.ReadAt(buf[:], int64(id)*pageSize)
+	if err == io.EOF {
+		// The page was allocated but never written before the last close
+		*buf = [pageSize]byte{}
+		return nil
+	}
+	return err
+}
+
+func (p *pager) write(id pageID, buf *[pageSize]byte) error {
+	_, err := p.file.WriteAt(buf[:], int64(id)*pageSize)
+	return err
+}
+
+// allocate hands out the next page id at the end of the file. The file itself grows once the page is written.
+func (p *pager) allocate() pageID {
+	id := pageID(p.numPages)
+	p.numPages++
+	return id
+}
+
+func (p *pager) sync() error {
+	return p.file.Sync()
+}
+
+func (p *pager) close() error {
+	return p.file.Close()
+}
+
+type bufferPool struct {
+	pager    *pager
+	capacity int
+	frames   map[pageID]*list.Element
+	lru      *list.List
+	wal      *wal
+
+	// Content of the pages changed by the running statement before it started, nil for the ones it allocated
+	pending        map[pageID]*[pageSize]byte
+	committedPages uint32
+}
+
+func newBufferPool(p *pager, capacity int) *bufferPool {
+	return &bufferPool{
+		pager:          p,
+		capacity:       capacity,
+		frames:         map[pageID]*list.Element{},
+		lru:            list.New(),
+		pending:        map[pageID]*[pageSize]byte{},
+		committedPages: p.numPages,
+	}
+}
+
+// fetch returns the page pinned, the caller must unpin it once it's done with it
+func (bp *bufferPool) fetch(id pageID) (*page, error) {
+	if e, ok := bp.frames[id]; ok {
+		bp.lru.MoveToFront(e)
+		pg := e.Value.(*page)
+		pg.pins++
+		return pg, nil
+	}
+
+	if err := bp.makeRoom(); err != nil {
+		return nil, err
+	}
+
+	pg := &page{id: id, pins: 1}
+	if err := bp.pager.read(id, &pg.data); err != nil {
+		return nil, err
+	}
+	bp.frames[id] = bp.lru.PushFront(pg)
+	return pg, nil
+}
+
+// allocate creates a new zeroed page at the end of the file, returned pinned and dirty
+func (bp *bufferPool) allocate() (*page, error) {
+	if err := bp.makeRoom(); err != nil {
+		return nil, err
+	}
+
+	pg := &page{id: bp.pager.allocate(), pins: 1}
+	bp.frames[pg.id] = bp.lru.PushFront(pg)
+	bp.modify(pg)
+	return pg, nil
+}
+
+// modify must be called before changing the content of a page
+func (bp *bufferPool) modify(pg *page) {
+	pg.dirty = true
+	if _, ok := bp.pending[pg.id]; ok {
+		return
+	}
+
+	if uint32(pg.id) >= bp.committedPages {
+		bp.pending[pg.id] = nil
+		return
+	}
+	before := pg.data
+	bp.pending[pg.id] = &before
+}
+
+// commit makes the changes of the running statement durable by writing the pending pages to the log
+func (bp *bufferPool) commit() error {
+	if bp.wal != nil && len(bp.pending) > 0 {
+		ids := []pageID{}
+		for id := range bp.pending {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		for _, id := range ids {
+			pg := bp.frames[id].Value.(*page)
+			if err := bp.wal.appendPage(id, &pg.data); err != nil {
+				return err
+			}
+		}
+		if err := bp.wal.commit(); err != nil {
+			return err
+		}
+	}
+
+	bp.pending = map[pageID]*[pageSize]byte{}
+	bp.committedPages = bp.pager.numPages
+	return nil
+}
+
+// rollback undoes every change of the running statement
+func (bp *bufferPool) rollback() {
+	for id, before := range bp.pending {
+		e, ok := bp.frames[id]
+		if !ok {
+			continue
+		}
+
+		if before == nil {
+			bp.lru.Remove(e)
+			delete(bp.frames, id)
+			continue
+		}
+		e.Value.(*page).data = *before
+	}
+
+	bp.pending = map[pageID]*[pageSize]byte{}
+	bp.pager.numPages = bp.committedPages
+}
+
+func (bp *bufferPool) unpin(pg *page) {
+	if pg.pins > 0 {
+		pg.pins--
+	}
+}
+
+// makeRoom evicts the least recently used unpinned page if the pool is at capacity
+func (bp *bufferPool) makeRoom() error {
+	if len(bp.frames) < bp.capacity {
+		return nil
+	}
+
+	for e := bp.lru.Back(); e != nil; e = e.Prev() {
+		pg := e.Value.(*page)
+		if pg.pins > 0 {
+			continue
+		}
+		if _, ok := bp.pending[pg.id]; ok && bp.wal != nil {
+			continue
+		}
+
+		if pg.dirty {
+			if err := bp.pager.write(pg.id, &pg.data); err != nil {
+				return err
+			}
+		}
+		bp.lru.Remove(e)
+		delete(bp.frames, pg.id)
+		return nil
+	}
+	return ErrBufferPoolFull
+}
+
+// flush writes every dirty page back to the file and syncs it, it must not be called with pending pages
+func (bp *bufferPool) flush() error {
+	for e := bp.lru.Front(); e != nil; e = e.Next() {
+		pg := e.Value.(*page)
+		if !pg.dirty {
+			continue
+		}
+		if err := bp.pager.write(pg.id, &pg.data); err != nil {
+			return err
+		}
+		pg.dirty = false
+	}
+	return bp.pager.sync()
+}
@@ -1,6 +1,7 @@
 package gosql
 
 import (
+	"context"
 	"errors"
 	"fmt"
 )
@@ -41,6 +42,11 @@ func helpMessage(tokens []*token, cursor uint, msg string) {
 }
 
 func Parse(source string) (*Ast, error) {
+	return ParseContext(context.Background(), source)
+}
+
+// ParseContext parses like Parse, giving up between statements once ctx is done
+func ParseContext(ctx context.Context, source string) (*Ast, error) {
 
 	// LLamamos al lexer
 	tokens, err := lex(source)
@@ -51,6 +57,10 @@ func Parse(source string) (*Ast, error) {
 	a := Ast{}
 	cursor := uint(0)
 	for cursor < uint(len(tokens)) {
+		if err := canceled(ctx); err != nil {
+			return nil, err
+		}
+
 		stmt, newCursor, ok := parseStatement(tokens, cursor, tokenFromSymbol(semicolonSymbol))
 		if !ok {
 			helpMessage(tokens, cursor, "Expected statement")
@@ -96,6 +106,33 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 		}, newCursor, true
 	}
 
+	// Look for an EXPLAIN statement
+	expl, newCursor, ok := parseExplainStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Kind:             ExplainKind,
+			ExplainStatement: expl,
+		}, newCursor, true
+	}
+
+	// Look for an ANALYZE statement
+	anlz, newCursor, ok := parseAnalyzeStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Kind:             AnalyzeKind,
+			AnalyzeStatement: anlz,
+		}, newCursor, true
+	}
+
+	// Look for a CREATE INDEX statement
+	crtIdx, newCursor, ok := parseCreateIndexStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Kind:                 CreateIndexKind,
+			CreateIndexStatement: crtIdx,
+		}, newCursor, true
+	}
+
 	// Look for CREATE statement
 	crtTbl, newCursor, ok := parseCreateTableStatement(tokens, cursor, delimiter)
 	if ok {
@@ -107,6 +144,15 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 	return nil, initialCursor, false
 }
 
+/*
+	SELECT
+	$expression [, ...]
+	[FROM $table-name [$alias] [[INNER | CROSS] JOIN $table-name [$alias] [ON $expression] ...]]
+	[WHERE $expression]
+	[GROUP BY $expression [, ...]]
+	[ORDER BY $expression [ASC | DESC] [, ...]]
+*/
+
 func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token) (*SelectStatement, uint, bool) {
 	cursor := initialCursor
 	if !expectToken(tokens, cursor, tokenFromKeyword(selectKeyword)) {
@@ -115,7 +161,14 @@ func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token)
 	cursor++
 	slct := SelectStatement{}
 
-	exps, newCursor, ok := parseExpressions(tokens, cursor, []token{tokenFromKeyword(fromKeyword), delimiter})
+	itemDelimiters := []token{
+		tokenFromKeyword(fromKeyword),
+		tokenFromKeyword(whereKeyword),
+		tokenFromKeyword(groupKeyword),
+		tokenFromKeyword(orderKeyword),
+		delimiter,
+	}
+	exps, newCursor, ok := parseExpressions(tokens, cursor, itemDelimiters)
 	if !ok {
 		return nil, initialCursor, false
 	}
@@ -125,18 +178,209 @@ func parseSelectStatement(tokens []*token, initialCursor uint, delimiter token)
 
 	if expectToken(tokens, cursor, tokenFromKeyword(fromKeyword)) {
 		cursor++
-		from, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+		from, alias, newCursor, ok := parseTableReference(tokens, cursor)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected FROM token")
 			return nil, initialCursor, false
 		}
 		slct.from = *from
+		slct.alias = alias
+		cursor = newCursor
+
+		for {
+			join, newCursor, ok := parseJoinClause(tokens, cursor)
+			if !ok {
+				break
+			}
+			slct.joins = append(slct.joins, join)
+			cursor = newCursor
+		}
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(whereKeyword)) {
+		cursor++
+		where, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected WHERE conditionals")
+			return nil, initialCursor, false
+		}
+		slct.where = where
+		cursor = newCursor
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(groupKeyword)) {
+		cursor++
+		if !expectToken(tokens, cursor, tokenFromKeyword(byKeyword)) {
+			helpMessage(tokens, cursor, "Expected BY")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		groupBy, newCursor, ok := parseExpressions(tokens, cursor, []token{tokenFromKeyword(orderKeyword), delimiter})
+		if !ok {
+			return nil, initialCursor, false
+		}
+		slct.groupBy = groupBy
+		cursor = newCursor
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(orderKeyword)) {
+		cursor++
+		if !expectToken(tokens, cursor, tokenFromKeyword(byKeyword)) {
+			helpMessage(tokens, cursor, "Expected BY")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		orderBy, newCursor, ok := parseOrderByItems(tokens, cursor, delimiter)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		slct.orderBy = orderBy
 		cursor = newCursor
 	}
 
 	return &slct, cursor, true
 }
 
+// The parseTableReference helper looks for a table name optionally followed by an alias, with or without AS
+func parseTableReference(tokens []*token, initialCursor uint) (*token, *token, uint, bool) {
+	cursor := initialCursor
+	name, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	if !ok {
+		return nil, nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	hasAs := expectToken(tokens, cursor, tokenFromKeyword(asKeyword))
+	if hasAs {
+		cursor++
+	}
+
+	alias, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	if !ok {
+		if hasAs {
+			helpMessage(tokens, cursor, "Expected table alias")
+			return nil, nil, initialCursor, false
+		}
+		return name, nil, cursor, true
+	}
+	return name, alias, newCursor, true
+}
+
+func parseJoinClause(tokens []*token, initialCursor uint) (*joinClause, uint, bool) {
+	cursor := initialCursor
+
+	cross := false
+	switch {
+	case expectToken(tokens, cursor, tokenFromKeyword(innerKeyword)):
+		cursor++
+	case expectToken(tokens, cursor, tokenFromKeyword(crossKeyword)):
+		cross = true
+		cursor++
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(joinKeyword)) {
+		if cursor != initialCursor {
+			helpMessage(tokens, cursor, "Expected JOIN")
+		}
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, alias, newCursor, ok := parseTableReference(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+	join := &joinClause{table: *table, alias: alias}
+
+	if cross {
+		return join, cursor, true
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(onKeyword)) {
+		helpMessage(tokens, cursor, "Expected ON")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	on, newCursor, ok := parseExpression(tokens, cursor, 0)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected join condition")
+		return nil, initialCursor, false
+	}
+	join.on = on
+	return join, newCursor, true
+}
+
+func parseOrderByItems(tokens []*token, initialCursor uint, delimiter token) ([]*orderByItem, uint, bool) {
+	cursor := initialCursor
+	items := []*orderByItem{}
+	for {
+		if cursor >= uint(len(tokens)) || delimiter.equals(tokens[cursor]) {
+			break
+		}
+
+		if len(items) > 0 {
+			if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
+				helpMessage(tokens, cursor, "Expected comma")
+				return nil, initialCursor, false
+			}
+			cursor++
+		}
+
+		exp, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+		item := &orderByItem{exp: exp}
+
+		if expectToken(tokens, cursor, tokenFromKeyword(descKeyword)) {
+			item.desc = true
+			cursor++
+		} else if expectToken(tokens, cursor, tokenFromKeyword(ascKeyword)) {
+			cursor++
+		}
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		helpMessage(tokens, cursor, "Expected expression")
+		return nil, initialCursor, false
+	}
+	return items, cursor, true
+}
+
+/*
+	EXPLAIN [ANALYZE] $select-statement
+*/
+
+func parseExplainStatement(tokens []*token, initialCursor uint, delimiter token) (*ExplainStatement, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromKeyword(explainKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	expl := ExplainStatement{}
+	if expectToken(tokens, cursor, tokenFromKeyword(analyzeKeyword)) {
+		expl.analyze = true
+		cursor++
+	}
+
+	slct, newCursor, ok := parseSelectStatement(tokens, cursor, delimiter)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected SELECT statement")
+		return nil, initialCursor, false
+	}
+	expl.statement = slct
+	return &expl, newCursor, true
+}
+
 // The parseToken helper will look for a token of a particular token kind
 func parseToken(tokens []*token, initialCursor uint, kind tokenKind) (*token, uint, bool) {
 	cursor := initialCursor
@@ -181,7 +425,7 @@ outer:
 		}
 
 		// Look for expression
-		exp, newCursor, ok := parseExpression(tokens, cursor, tokenFromSymbol(commaSymbol))
+		exp, newCursor, ok := parseExpression(tokens, cursor, 0)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected expression")
 			return nil, initialCursor, false
@@ -194,13 +438,169 @@ outer:
 	return exps, cursor, true
 }
 
-// The parse Expression helper (for now) will look for a numeric, string, or identifier token.
-func parseExpression(token []*token, initialCursor uint, _ token) (*expression, uint, bool) {
+// Binary operators from the loosest to the tightest binding
+var binaryOperators = []struct {
+	op    token
+	power uint
+}{
+	{tokenFromKeyword(orKeyword), 1},
+	{tokenFromKeyword(andKeyword), 2},
+	{tokenFromSymbol(eqSymbol), 3},
+	{tokenFromSymbol(neqSymbol), 3},
+	{tokenFromSymbol(neqSymbol2), 3},
+	{tokenFromSymbol(ltSymbol), 3},
+	{tokenFromSymbol(lteSymbol), 3},
+	{tokenFromSymbol(gtSymbol), 3},
+	{tokenFromSymbol(gteSymbol), 3},
+	{tokenFromSymbol(plusSymbol), 4},
+	{tokenFromSymbol(minusSymbol), 4},
+	{tokenFromSymbol(concatSymbol), 4},
+	{tokenFromSymbol(asteriskSymbol), 5},
+	{tokenFromSymbol(slashSymbol), 5},
+	{tokenFromSymbol(arrowSymbol), 6},
+	{tokenFromSymbol(doubleArrowSymbol), 6},
+}
+
+// bindingPower returns how tightly a binary operator binds, 0 if the token isn't one
+func bindingPower(t *token) uint {
+	for _, bo := range binaryOperators {
+		if bo.op.equals(t) {
+			return bo.power
+		}
+	}
+	return 0
+}
+
+// The parseExpression helper looks for a literal, a function call or a parenthesized expression, possibly followed
+// by binary operators. Operators binding tighter than minBp are parsed first, so precedence is respected and
+// operators of the same precedence group to the left. Parsing stops at the first token that can't continue the
+// expression, the caller decides whether it's valid there.
+func parseExpression(tokens []*token, initialCursor uint, minBp uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	var exp *expression
+	if expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
+		cursor++
+		inner, newCursor, ok := parseExpression(tokens, cursor, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression after opening paren")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
+			helpMessage(tokens, cursor, "Expected closing paren")
+			return nil, initialCursor, false
+		}
+		cursor++
+		exp = inner
+	} else {
+		operand, newCursor, ok := parseOperand(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+		exp = operand
+	}
+
+	// Subscripts and casts bind tighter than any operator, like in tags[1] || x or id::TEXT || x
+	for {
+		if expectToken(tokens, cursor, tokenFromSymbol(leftBracketSymbol)) {
+			index, newCursor, ok := parseExpression(tokens, cursor+1, 0)
+			if !ok || !expectToken(tokens, newCursor, tokenFromSymbol(rightBracketSymbol)) {
+				helpMessage(tokens, cursor+1, "Expected subscript")
+				return nil, initialCursor, false
+			}
+			cursor = newCursor + 1
+			exp = &expression{subscript: &subscriptExpression{exp: exp, index: index}, kind: subscriptKind}
+			continue
+		}
+		if expectToken(tokens, cursor, tokenFromSymbol(doubleColonSymbol)) {
+			cd, newCursor, ok := parseColumnType(tokens, cursor+1)
+			if !ok {
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+			exp = &expression{cast: castFromDefinition(exp, cd), kind: castKind}
+			continue
+		}
+		break
+	}
+
+	for cursor < uint(len(tokens)) {
+		op := tokens[cursor]
+		bp := bindingPower(op)
+		if bp == 0 || bp <= minBp {
+			break
+		}
+
+		b, newCursor, ok := parseExpression(tokens, cursor+1, bp)
+		if !ok {
+			helpMessage(tokens, cursor+1, "Expected right operand")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		exp = &expression{
+			binary: &binaryExpression{a: exp, b: b, op: *op},
+			kind:   binaryKind,
+		}
+	}
+	return exp, cursor, true
+}
+
+// The parseOperand helper looks for a function call, an array or a numeric, string, identifier, boolean, null or
+// parameter token. A lone * stands for every column and a minus sign right before a number makes it negative.
+func parseOperand(tokens []*token, initialCursor uint) (*expression, uint, bool) {
 	cursor := initialCursor
 
-	kinds := []tokenKind{identifierKind, numericKind, stringKind}
+	if call, newCursor, ok := parseCall(tokens, cursor); ok {
+		return &expression{call: call, kind: callKind}, newCursor, true
+	}
+
+	if expectToken(tokens, cursor, tokenFromSymbol(minusSymbol)) {
+		number, newCursor, ok := parseToken(tokens, cursor+1, numericKind)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		negative := *number
+		negative.value = "-" + number.value
+		negative.loc = tokens[cursor].loc
+		return &expression{literal: &negative, kind: literalKind}, newCursor, true
+	}
+
+	if expectToken(tokens, cursor, tokenFromSymbol(asteriskSymbol)) {
+		return &expression{literal: tokens[cursor], kind: literalKind}, cursor + 1, true
+	}
+
+	if cast, newCursor, ok := parseTypedLiteral(tokens, cursor); ok {
+		return &expression{cast: cast, kind: castKind}, newCursor, true
+	}
+
+	if cast, newCursor, ok := parseCast(tokens, cursor); ok {
+		return &expression{cast: cast, kind: castKind}, newCursor, true
+	}
+
+	if expectToken(tokens, cursor, tokenFromKeyword(arrayKeyword)) {
+		if !expectToken(tokens, cursor+1, tokenFromSymbol(leftBracketSymbol)) {
+			helpMessage(tokens, cursor+1, "Expected left bracket")
+			return nil, initialCursor, false
+		}
+		elements, newCursor, ok := parseExpressions(tokens, cursor+2, []token{tokenFromSymbol(rightBracketSymbol)})
+		if !ok || !expectToken(tokens, newCursor, tokenFromSymbol(rightBracketSymbol)) {
+			helpMessage(tokens, cursor+2, "Expected array elements")
+			return nil, initialCursor, false
+		}
+		if elements == nil {
+			elements = []*expression{}
+		}
+		arr := &arrayExpression{elements: elements, loc: tokens[cursor].loc}
+		return &expression{array: arr, kind: arrayKind}, newCursor + 1, true
+	}
+
+	kinds := []tokenKind{identifierKind, numericKind, stringKind, hexKind, boolKind, nullKind, parameterKind}
 	for _, kind := range kinds {
-		t, newCursor, ok := parseToken(token, cursor, kind)
+		t, newCursor, ok := parseToken(tokens, cursor, kind)
 		if ok {
 			return &expression{
 				literal: t,
@@ -211,6 +611,99 @@ func parseExpression(token []*token, initialCursor uint, _ token) (*expression,
 	return nil, initialCursor, false
 }
 
+// The parseTypedLiteral helper looks for a string preceded by the type it's written in, like DATE '2024-01-02'
+func parseTypedLiteral(tokens []*token, initialCursor uint) (*castExpression, uint, bool) {
+	cursor := initialCursor
+	for _, k := range []keyword{dateKeyword, timeKeyword, timestampKeyword, intervalKeyword} {
+		if !expectToken(tokens, cursor, tokenFromKeyword(k)) {
+			continue
+		}
+
+		value, newCursor, ok := parseToken(tokens, cursor+1, stringKind)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		return &castExpression{
+			exp:      &expression{literal: value, kind: literalKind},
+			datatype: *tokens[cursor],
+		}, newCursor, true
+	}
+	return nil, initialCursor, false
+}
+
+// The parseCast helper looks for CAST(x AS type)
+func parseCast(tokens []*token, initialCursor uint) (*castExpression, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromKeyword(castKeyword)) {
+		return nil, initialCursor, false
+	}
+	if !expectToken(tokens, cursor+1, tokenFromSymbol(leftParenSymbol)) {
+		helpMessage(tokens, cursor+1, "Expected left paren")
+		return nil, initialCursor, false
+	}
+
+	exp, newCursor, ok := parseExpression(tokens, cursor+2, 0)
+	if !ok || !expectToken(tokens, newCursor, tokenFromKeyword(asKeyword)) {
+		helpMessage(tokens, newCursor, "Expected AS")
+		return nil, initialCursor, false
+	}
+
+	cd, newCursor, ok := parseColumnType(tokens, newCursor+1)
+	if !ok || !expectToken(tokens, newCursor, tokenFromSymbol(rightParenSymbol)) {
+		helpMessage(tokens, newCursor, "Expected right paren")
+		return nil, initialCursor, false
+	}
+	return castFromDefinition(exp, cd), newCursor + 1, true
+}
+
+func castFromDefinition(exp *expression, cd *columnDefinition) *castExpression {
+	return &castExpression{exp: exp, datatype: cd.datatype, modifiers: cd.modifiers, array: cd.array}
+}
+
+// The parseCall helper looks for a function name followed by its arguments between parens, or by * for calls
+// like count(*). extract takes the field it extracts before FROM, like extract(year FROM ts), which is the same
+// as extract('year', ts).
+func parseCall(tokens []*token, initialCursor uint) (*callExpression, uint, bool) {
+	cursor := initialCursor
+	name, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	if !ok || !expectToken(tokens, newCursor, tokenFromSymbol(leftParenSymbol)) {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor + 1
+	call := &callExpression{name: *name}
+
+	if name.value == "extract" && expectToken(tokens, cursor+1, tokenFromKeyword(fromKeyword)) {
+		field, _, ok := parseToken(tokens, cursor, identifierKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected field to extract")
+			return nil, initialCursor, false
+		}
+		source, newCursor, ok := parseExpression(tokens, cursor+2, 0)
+		if !ok || !expectToken(tokens, newCursor, tokenFromSymbol(rightParenSymbol)) {
+			helpMessage(tokens, newCursor, "Expected expression to extract from")
+			return nil, initialCursor, false
+		}
+
+		literal := *field
+		literal.kind = stringKind
+		call.args = []*expression{{literal: &literal, kind: literalKind}, source}
+		return call, newCursor + 1, true
+	}
+
+	if expectToken(tokens, cursor, tokenFromSymbol(asteriskSymbol)) &&
+		expectToken(tokens, cursor+1, tokenFromSymbol(rightParenSymbol)) {
+		call.star = true
+		return call, cursor + 2, true
+	}
+
+	args, newCursor, ok := parseExpressions(tokens, cursor, []token{tokenFromSymbol(rightParenSymbol)})
+	if !ok {
+		return nil, initialCursor, false
+	}
+	call.args = args
+	return call, newCursor + 1, true
+}
+
 // The parsing insert statements
 func parseInsertStatement(tokens []*token, initialCursor uint, _ token) (*InsertStatement, uint, bool) {
 	cursor := initialCursor
@@ -233,6 +726,7 @@ func parseInsertStatement(tokens []*token, initialCursor uint, _ token) (*Insert
 		INSERT
 		INTO
 		$table-name
+		[( $column-name [, ...] )]
 		VALUES
 		(
 		$expression [, ...]
@@ -245,6 +739,12 @@ func parseInsertStatement(tokens []*token, initialCursor uint, _ token) (*Insert
 	}
 	cursor = newCursor
 
+	columns, newCursor, ok := parseInsertColumns(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
 	// Look for VALUES
 	if !expectToken(tokens, cursor, tokenFromKeyword(valuesKeyword)) {
 		helpMessage(tokens, cursor, "Expected VALUES")
@@ -274,11 +774,44 @@ func parseInsertStatement(tokens []*token, initialCursor uint, _ token) (*Insert
 	cursor++
 
 	return &InsertStatement{
-		table:  *table,
-		values: values,
+		table:   *table,
+		columns: columns,
+		values:  values,
 	}, cursor, true
 }
 
+// The parseInsertColumns helper looks for the names of the columns an insert gives values for between parens,
+// there are none when it doesn't name them
+func parseInsertColumns(tokens []*token, initialCursor uint) ([]token, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
+		return nil, initialCursor, true
+	}
+	cursor++
+
+	columns := []token{}
+	for {
+		if len(columns) > 0 {
+			if expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
+				return columns, cursor + 1, true
+			}
+			if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
+				helpMessage(tokens, cursor, "Expected comma or right paren")
+				return nil, initialCursor, false
+			}
+			cursor++
+		}
+
+		column, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected column name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+		columns = append(columns, *column)
+	}
+}
+
 // Parsing Create statements
 /*
 	CREATE
@@ -364,17 +897,183 @@ func parseColumnDefinitions(tokens []*token, initialCursor uint, delimiter token
 		cursor = newCursor
 
 		// Look for a column type
-		ty, newCursor, ok := parseToken(tokens, cursor, keywordKind)
+		cd, newCursor, ok := parseColumnType(tokens, cursor)
 		if !ok {
-			helpMessage(tokens, cursor, "Expected column type")
 			return nil, initialCursor, false
 		}
 		cursor = newCursor
+		cd.name = *id
+
+		if expectToken(tokens, cursor, tokenFromKeyword(defaultKeyword)) {
+			cd.def, newCursor, ok = parseExpression(tokens, cursor+1, 0)
+			if !ok {
+				helpMessage(tokens, cursor+1, "Expected default value")
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+		}
 
-		cds = append(cds, &columnDefinition{
-			name:     *id,
-			datatype: *ty,
-		})
+		cds = append(cds, cd)
 	}
 	return cds, cursor, true
 }
+
+// parseColumnType looks for a type as columns and casts write it, like INT, VARCHAR(10) or TEXT[], returning
+// a column definition without a name
+func parseColumnType(tokens []*token, initialCursor uint) (*columnDefinition, uint, bool) {
+	cursor := initialCursor
+	ty, newCursor, ok := parseToken(tokens, cursor, keywordKind)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected column type")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	// DOUBLE PRECISION and CHARACTER VARYING are the only types of two words
+	if ty.value == string(doubleKeyword) {
+		if !expectToken(tokens, cursor, tokenFromKeyword(precisionKeyword)) {
+			helpMessage(tokens, cursor, "Expected PRECISION")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		double := *ty
+		double.value = string(doubleKeyword) + " " + string(precisionKeyword)
+		ty = &double
+	}
+	if ty.value == string(characterKeyword) && expectToken(tokens, cursor, tokenFromKeyword(varyingKeyword)) {
+		cursor++
+
+		varying := *ty
+		varying.value = string(characterKeyword) + " " + string(varyingKeyword)
+		ty = &varying
+	}
+
+	modifiers, newCursor, ok := parseTypeModifiers(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	array := expectToken(tokens, cursor, tokenFromSymbol(leftBracketSymbol))
+	if array {
+		if !expectToken(tokens, cursor+1, tokenFromSymbol(rightBracketSymbol)) {
+			helpMessage(tokens, cursor+1, "Expected right bracket")
+			return nil, initialCursor, false
+		}
+		cursor += 2
+	}
+	return &columnDefinition{datatype: *ty, modifiers: modifiers, array: array}, cursor, true
+}
+
+// parseTypeModifiers looks for the numbers a column type may take between parens, like (10, 2)
+func parseTypeModifiers(tokens []*token, initialCursor uint) ([]token, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
+		return nil, initialCursor, true
+	}
+	cursor++
+
+	modifiers := []token{}
+	for {
+		if len(modifiers) > 0 {
+			if expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
+				return modifiers, cursor + 1, true
+			}
+			if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
+				helpMessage(tokens, cursor, "Expected comma or right paren")
+				return nil, initialCursor, false
+			}
+			cursor++
+		}
+
+		modifier, newCursor, ok := parseToken(tokens, cursor, numericKind)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected type modifier")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+		modifiers = append(modifiers, *modifier)
+	}
+}
+
+/*
+	CREATE INDEX $index-name ON $table-name ( $column-name )
+*/
+
+func parseCreateIndexStatement(tokens []*token, initialCursor uint, _ token) (*CreateIndexStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(createKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(indexKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	name, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected index name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(onKeyword)) {
+		helpMessage(tokens, cursor, "Expected ON")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected left paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	column, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected column name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
+		helpMessage(tokens, cursor, "Expected right paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &CreateIndexStatement{
+		name:   *name,
+		table:  *table,
+		column: *column,
+	}, cursor, true
+}
+
+/*
+	ANALYZE [$table-name]
+*/
+
+func parseAnalyzeStatement(tokens []*token, initialCursor uint, _ token) (*AnalyzeStatement, uint, bool) {
+	cursor := initialCursor
+	if !expectToken(tokens, cursor, tokenFromKeyword(analyzeKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, newCursor, ok := parseToken(tokens, cursor, identifierKind)
+	if !ok {
+		return &AnalyzeStatement{}, cursor, true
+	}
+	return &AnalyzeStatement{table: table}, newCursor, true
+}
@@ -3,6 +3,7 @@ package gosql
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -34,7 +35,7 @@ func TestParse(t *testing.T) {
 								},
 								{
 									literal: &token{
-										loc:   location{col: 31, line: 0},
+										loc:   location{col: 30, line: 0},
 										kind:  numericKind,
 										value: "233",
 									},
@@ -133,3 +134,163 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, test.ast, ast, test.source)
 	}
 }
+
+func TestParse_select(t *testing.T) {
+	tests := []struct {
+		source  string
+		items   string
+		where   string
+		joins   int
+		groupBy int
+		orderBy int
+	}{
+		{
+			source: "SELECT 1 - 2 - 3, 1 + 2 * 3, (1 + 2) * 3;",
+			items:  "((1 - 2) - 3), (1 + (2 * 3)), ((1 + 2) * 3)",
+		},
+		{
+			source: "SELECT * FROM users WHERE a = 1 OR b > -2 AND c <> \"x\";",
+			items:  "*",
+			where:  `((a = 1) OR ((b > -2) AND (c <> "x")))`,
+		},
+		{
+			source:  "SELECT t.name, count(*) FROM users u JOIN teams AS t ON u.team = t.id CROSS JOIN x GROUP BY t.name ORDER BY count(*) DESC, t.name;",
+			items:   "t.name, count(*)",
+			joins:   2,
+			groupBy: 1,
+			orderBy: 2,
+		},
+		{
+			source: "SELECT DATE '2024-01-02', extract(year FROM created), now() - INTERVAL '1 day';",
+			items:  `DATE '2024-01-02', extract("year", created), (now() - INTERVAL '1 day')`,
+		},
+		{
+			source: "SELECT payload->'items'->0->>'name' || x FROM events WHERE payload->>'n' = 'a';",
+			items:  `((((payload -> "items") -> 0) ->> "name") || x)`,
+			where:  `((payload ->> "n") = "a")`,
+		},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse(test.source)
+		assert.Nil(t, err, test.source)
+		slct := ast.Statements[0].SelectStatement
+
+		items := []string{}
+		for _, item := range slct.item {
+			items = append(items, item.generateCode())
+		}
+		assert.Equal(t, test.items, strings.Join(items, ", "), test.source)
+		if test.where != "" {
+			assert.Equal(t, test.where, slct.where.generateCode(), test.source)
+		}
+		assert.Equal(t, test.joins, len(slct.joins), test.source)
+		assert.Equal(t, test.groupBy, len(slct.groupBy), test.source)
+		assert.Equal(t, test.orderBy, len(slct.orderBy), test.source)
+	}
+
+	ast, err := Parse("EXPLAIN ANALYZE SELECT id FROM users;")
+	assert.Nil(t, err)
+	assert.Equal(t, ExplainKind, ast.Statements[0].Kind)
+	assert.True(t, ast.Statements[0].ExplainStatement.analyze)
+
+	ast, err = Parse("CREATE TABLE t (a DOUBLE PRECISION, b REAL, c FLOAT);")
+	assert.Nil(t, err)
+	for i, typ := range []string{"double precision", "real", "float"} {
+		assert.Equal(t, typ, ast.Statements[0].CreateTableStatement.cols[i].datatype.value)
+	}
+
+	ast, err = Parse("CREATE TABLE t (a NUMERIC(10, 2), b DECIMAL(5), c NUMERIC);")
+	assert.Nil(t, err)
+	for i, count := range []int{2, 1, 0} {
+		assert.Equal(t, count, len(ast.Statements[0].CreateTableStatement.cols[i].modifiers))
+	}
+	_, err = Parse("CREATE TABLE t (a NUMERIC(10,));")
+	assert.NotNil(t, err)
+
+	ast, err = Parse("CREATE TABLE t (a INT DEFAULT 1 + 2, b UUID DEFAULT gen_random_uuid()); INSERT INTO t (b, a) VALUES (NULL, 1);")
+	assert.Nil(t, err)
+	cols := ast.Statements[0].CreateTableStatement.cols
+	assert.Equal(t, "(1 + 2)", cols[0].def.generateCode())
+	assert.Equal(t, "gen_random_uuid()", cols[1].def.generateCode())
+	inst := ast.Statements[1].InsertStatement
+	assert.Equal(t, []string{"b", "a"}, []string{inst.columns[0].value, inst.columns[1].value})
+	for _, source := range []string{
+		"CREATE TABLE t (a INT DEFAULT);",
+		"INSERT INTO t () VALUES (1);",
+		"INSERT INTO t (a b) VALUES (1);",
+	} {
+		_, err := Parse(source)
+		assert.NotNil(t, err, source)
+	}
+
+	ast, err = Parse("CREATE TABLE t (a VARCHAR(10), b CHARACTER VARYING(3), c CHAR, d CHARACTER(2));")
+	assert.Nil(t, err)
+	for i, typ := range []string{"varchar", "character varying", "char", "character"} {
+		assert.Equal(t, typ, ast.Statements[0].CreateTableStatement.cols[i].datatype.value)
+	}
+
+	ast, err = Parse("CREATE TABLE t (a INT[], b VARCHAR(3)[]); SELECT ARRAY[1, 2][a[1]], b[1] FROM t;")
+	assert.Nil(t, err)
+	cols = ast.Statements[0].CreateTableStatement.cols
+	assert.True(t, cols[0].array)
+	assert.Equal(t, "varchar", cols[1].datatype.value)
+	assert.True(t, cols[1].array)
+	assert.Equal(t, 1, len(cols[1].modifiers))
+	items := ast.Statements[1].SelectStatement.item
+	assert.Equal(t, subscriptKind, items[0].kind)
+	assert.Equal(t, arrayKind, items[0].subscript.exp.kind)
+	assert.Equal(t, "ARRAY[1, 2][a[1]]", items[0].generateCode())
+	assert.Equal(t, "b[1]", items[1].generateCode())
+	for _, source := range []string{
+		"CREATE TABLE t (a INT[);",
+		"SELECT ARRAY[1, 2;",
+		"SELECT a[] FROM t;",
+	} {
+		_, err := Parse(source)
+		assert.NotNil(t, err, source)
+	}
+
+	ast, err = Parse("SELECT CAST(a AS VARCHAR(3)), b::DOUBLE PRECISION, '{1}'::INT[], c::TEXT || 'x', DATE '2024-01-02';")
+	assert.Nil(t, err)
+	items = ast.Statements[0].SelectStatement.item
+	for i, code := range []string{
+		"CAST(a AS VARCHAR(3))",
+		"CAST(b AS DOUBLE PRECISION)",
+		`CAST("{1}" AS INT[])`,
+		`(CAST(c AS TEXT) || "x")`,
+		"DATE '2024-01-02'",
+	} {
+		assert.Equal(t, code, items[i].generateCode())
+	}
+	for _, source := range []string{
+		"SELECT CAST(a INT);",
+		"SELECT CAST(a AS);",
+		"SELECT CAST(a AS INT;",
+		"SELECT a::;",
+	} {
+		_, err := Parse(source)
+		assert.NotNil(t, err, source)
+	}
+
+	ast, err = Parse("ANALYZE; ANALYZE users; CREATE INDEX users_id ON users (id);")
+	assert.Nil(t, err)
+	assert.Equal(t, AnalyzeKind, ast.Statements[0].Kind)
+	assert.Nil(t, ast.Statements[0].AnalyzeStatement.table)
+	assert.Equal(t, "users", ast.Statements[1].AnalyzeStatement.table.value)
+	assert.Equal(t, CreateIndexKind, ast.Statements[2].Kind)
+	crtIdx := ast.Statements[2].CreateIndexStatement
+	assert.Equal(t, []string{"users_id", "users", "id"}, []string{crtIdx.name.value, crtIdx.table.value, crtIdx.column.value})
+
+	for _, source := range []string{
+		"SELECT id FROM users JOIN teams;",
+		"CREATE INDEX users_id ON users id;",
+		"CREATE INDEX ON users (id);",
+		"SELECT id FROM users WHERE;",
+		"SELECT id FROM users ORDER BY;",
+		"SELECT (1 + 2 FROM users;",
+	} {
+		_, err := Parse(source)
+		assert.NotNil(t, err, source)
+	}
+}
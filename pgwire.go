@@ -0,0 +1,426 @@
+package gosql
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+Postgres Wire Protocol
+----------------------
+PgServer speaks enough of the Postgres v3 frontend/backend protocol for psql and the usual client libraries to
+run simple queries against a backend: the startup handshake without authentication, simple query messages and
+their RowDescription, DataRow, CommandComplete and ErrorResponse answers. Every value is sent in text format.
+
+Every message after the startup one is a type byte followed by its length, which includes itself but not the
+type byte:
+
+	$type byte $length int32 $body
+*/
+
+const (
+	pgProtocolVersion = 196608
+	pgSSLRequest      = 80877103
+	pgCancelRequest   = 80877102
+
+	pgBoolOID  = 16
+	pgByteaOID = 17
+	pgInt8OID  = 20
+	pgInt2OID  = 21
+	pgInt4OID  = 23
+	pgTextOID  = 25
+	pgJSONOID  = 114
+	pgUUIDOID  = 2950
+	// float4 and float8
+	pgRealOID    = 700
+	pgDoubleOID  = 701
+	pgNumericOID = 1700
+	// Postgres keeps dates as days and times as microseconds like we do, and intervals in 16 bytes too
+	pgDateOID      = 1082
+	pgTimeOID      = 1083
+	pgTimestampOID = 1114
+	pgIntervalOID  = 1186
+
+	// Messages bigger than this are considered garbage
+	pgMaxMessageSize = 1 << 24
+)
+
+// pgArrayOIDs are the types of arrays by the type of their elements, sent in the {1,2,3} form
+var pgArrayOIDs = map[ColumnType]uint32{
+	BoolType:      1000,
+	ByteaType:     1001,
+	SmallIntType:  1005,
+	IntType:       1007,
+	TextType:      1009,
+	BigIntType:    1016,
+	RealType:      1021,
+	DoubleType:    1022,
+	TimestampType: 1115,
+	DateType:      1182,
+	TimeType:      1183,
+	IntervalType:  1187,
+	NumericType:   1231,
+	JsonType:      199,
+	UuidType:      2951,
+}
+
+type PgServer struct {
+	// Queries running longer than this are canceled, zero means no limit
+	QueryTimeout time.Duration
+
+	// Backends aren't safe for concurrent use, so only one query runs at a time
+	mu      sync.Mutex
+	backend Backend
+}
+
+func NewPgServer(backend Backend) *PgServer {
+	return &PgServer{backend: backend}
+}
+
+// Serve accepts connections on l until it's closed, handling each one in its own goroutine
+func (s *PgServer) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go func() {
+			defer c.Close()
+			s.handle(c)
+		}()
+	}
+}
+
+type pgConn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (s *PgServer) handle(c net.Conn) {
+	conn := &pgConn{r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	if ok := conn.startup(); !ok {
+		return
+	}
+
+	// Whatever the connection is running stops when it goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// After an error in the extended protocol every message is discarded until the next Sync
+	discarding := false
+	for {
+		kind, body, err := conn.readMessage()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case kind == 'X':
+			return
+		case kind == 'S':
+			discarding = false
+			conn.readyForQuery()
+		case discarding:
+		case kind == 'Q':
+			s.simpleQuery(ctx, conn, cString(body))
+		default:
+			// The extended protocol isn't supported
+			conn.errorResponse("0A000", fmt.Sprintf("unsupported message type %q", kind))
+			discarding = true
+		}
+
+		if err := conn.w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *pgConn) startup() bool {
+	for {
+		var length int32
+		if err := binary.Read(c.r, binary.BigEndian, &length); err != nil {
+			return false
+		}
+		if length < 8 || length > pgMaxMessageSize {
+			return false
+		}
+
+		body := make([]byte, length-4)
+		if _, err := io.ReadFull(c.r, body); err != nil {
+			return false
+		}
+
+		switch binary.BigEndian.Uint32(body[:4]) {
+		case pgSSLRequest:
+			// No SSL, the client will carry on with a regular startup message
+			c.w.WriteByte('N')
+			if err := c.w.Flush(); err != nil {
+				return false
+			}
+			continue
+		case pgCancelRequest:
+			// There's nothing running in the background to cancel
+			return false
+		case pgProtocolVersion:
+		default:
+			return false
+		}
+
+		// Authentication ok
+		c.writeMessage('R', binary.BigEndian.AppendUint32(nil, 0))
+		for _, param := range [][2]string{
+			{"server_version", "14.0"},
+			{"server_encoding", "UTF8"},
+			{"client_encoding", "UTF8"},
+			{"DateStyle", "ISO, MDY"},
+			{"integer_datetimes", "on"},
+			{"standard_conforming_strings", "on"},
+		} {
+			body := append([]byte(param[0]), 0)
+			body = append(body, param[1]...)
+			c.writeMessage('S', append(body, 0))
+		}
+		c.readyForQuery()
+		return c.w.Flush() == nil
+	}
+}
+
+func (c *pgConn) readMessage() (byte, []byte, error) {
+	kind, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var length int32
+	if err := binary.Read(c.r, binary.BigEndian, &length); err != nil {
+		return 0, nil, err
+	}
+	if length < 4 || length > pgMaxMessageSize {
+		return 0, nil, fmt.Errorf("invalid message length %d", length)
+	}
+
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return kind, body, nil
+}
+
+func (c *pgConn) writeMessage(kind byte, body []byte) {
+	c.w.WriteByte(kind)
+	binary.Write(c.w, binary.BigEndian, int32(len(body)+4))
+	c.w.Write(body)
+}
+
+func (c *pgConn) readyForQuery() {
+	c.writeMessage('Z', []byte{'I'})
+}
+
+func (c *pgConn) commandComplete(tag string) {
+	c.writeMessage('C', append([]byte(tag), 0))
+}
+
+func (c *pgConn) errorResponse(code, msg string) {
+	body := []byte{}
+	for _, field := range []struct {
+		kind  byte
+		value string
+	}{
+		{'S', "ERROR"},
+		{'V', "ERROR"},
+		{'C', code},
+		{'M', msg},
+	} {
+		body = append(body, field.kind)
+		body = append(body, field.value...)
+		body = append(body, 0)
+	}
+	c.writeMessage('E', append(body, 0))
+}
+
+func (c *pgConn) rowDescription(rows *Rows) {
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(rows.cols)))
+	for _, col := range rows.cols {
+		oid, size := uint32(pgTextOID), int16(-1)
+		switch col.typ {
+		case SmallIntType:
+			oid, size = pgInt2OID, 2
+		case IntType:
+			oid, size = pgInt4OID, 4
+		case BigIntType:
+			oid, size = pgInt8OID, 8
+		case BoolType:
+			oid, size = pgBoolOID, 1
+		case RealType:
+			oid, size = pgRealOID, 4
+		case DoubleType:
+			oid, size = pgDoubleOID, 8
+		case NumericType:
+			oid = pgNumericOID
+		case DateType:
+			oid, size = pgDateOID, 4
+		case TimeType:
+			oid, size = pgTimeOID, 8
+		case TimestampType:
+			oid, size = pgTimestampOID, 8
+		case IntervalType:
+			oid, size = pgIntervalOID, 16
+		case ByteaType:
+			oid = pgByteaOID
+		case JsonType:
+			oid = pgJSONOID
+		case UuidType:
+			oid, size = pgUUIDOID, 16
+		}
+		if isArray(col.typ) {
+			oid = pgArrayOIDs[elementType(col.typ)]
+		}
+
+		body = append(body, col.name...)
+		body = append(body, 0)
+		body = binary.BigEndian.AppendUint32(body, 0)
+		body = binary.BigEndian.AppendUint16(body, 0)
+		body = binary.BigEndian.AppendUint32(body, oid)
+		body = binary.BigEndian.AppendUint16(body, uint16(size))
+		body = binary.BigEndian.AppendUint32(body, ^uint32(0))
+		body = binary.BigEndian.AppendUint16(body, 0)
+	}
+	c.writeMessage('T', body)
+}
+
+func (c *pgConn) dataRow(rows *Rows) {
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(rows.row)))
+	for i, cell := range rows.row {
+		if cell == nil {
+			body = binary.BigEndian.AppendUint32(body, ^uint32(0))
+			continue
+		}
+
+		value := cellText(cell, rows.cols[i].typ)
+		if rows.cols[i].typ == BoolType {
+			// Postgres spells booleans as t and f in text format
+			value = value[:1]
+		}
+		body = binary.BigEndian.AppendUint32(body, uint32(len(value)))
+		body = append(body, value...)
+	}
+	c.writeMessage('D', body)
+}
+
+// simpleQuery checks and runs every statement in the query, see runStatement, stopping at the first one that fails
+func (s *PgServer) simpleQuery(ctx context.Context, c *pgConn, query string) {
+	defer c.readyForQuery()
+
+	if s.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.QueryTimeout)
+		defer cancel()
+	}
+
+	ast, err := ParseContext(ctx, query)
+	if err != nil {
+		code := "42601"
+		if errors.Is(err, ErrQueryCanceled) {
+			code = sqlState(err)
+		}
+		c.errorResponse(code, err.Error())
+		return
+	}
+	if len(ast.Statements) == 0 {
+		c.writeMessage('I', nil)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stmt := range ast.Statements {
+		tag, rows, err := runStatement(ctx, s.backend, stmt)
+		switch {
+		case err == nil && rows != nil:
+			err = s.sendRows(c, rows, tag)
+		case err == nil:
+			c.commandComplete(tag)
+		}
+
+		if err != nil {
+			c.errorResponse(sqlState(err), err.Error())
+			return
+		}
+	}
+}
+
+// sendRows streams rows to the client as they are produced, completing with the command tag, which is followed by
+// the row count for selects
+func (s *PgServer) sendRows(c *pgConn, rows *Rows, command string) error {
+	defer rows.Close()
+
+	c.rowDescription(rows)
+	count := 0
+	for rows.Next() {
+		c.dataRow(rows)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if command == "SELECT" {
+		command = fmt.Sprintf("SELECT %d", count)
+	}
+	c.commandComplete(command)
+	return nil
+}
+
+// sqlState maps our errors to Postgres error codes
+func sqlState(err error) string {
+	switch {
+	case errors.Is(err, ErrTableDoesNotExist):
+		return "42P01"
+	case errors.Is(err, ErrTableAlreadyExists):
+		return "42P07"
+	case errors.Is(err, ErrColumnDoesNotExist):
+		return "42703"
+	case errors.Is(err, ErrInvalidDatatype), errors.Is(err, ErrorInvalidDataType), errors.Is(err, ErrInvalidOperands):
+		return "42804"
+	case errors.Is(err, ErrFunctionDoesNotExist):
+		return "42883"
+	case errors.Is(err, ErrMissingValues):
+		return "42601"
+	case errors.Is(err, ErrQueryCanceled):
+		return "57014"
+	case errors.Is(err, ErrNotSupported):
+		return "0A000"
+	case errors.Is(err, ErrIntegerOutOfRange), errors.Is(err, ErrFloatOutOfRange),
+		errors.Is(err, ErrNumericOutOfRange):
+		return "22003"
+	case errors.Is(err, ErrDivisionByZero):
+		return "22012"
+	case errors.Is(err, ErrValueTooLong):
+		return "22001"
+	case errors.Is(err, ErrDuplicateColumn):
+		return "42701"
+	}
+	return "XX000"
+}
+
+// cString reads a zero terminated string from the start of buf
+func cString(buf []byte) string {
+	for i, b := range buf {
+		if b == 0 {
+			return string(buf[:i])
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,168 @@
+package gosql
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pgMessage struct {
+	kind byte
+	body []byte
+}
+
+// pgClient is the bare minimum of a frontend to talk to PgServer
+type pgClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialPgServer(t *testing.T, addr string) *pgClient {
+	conn, err := net.Dial("tcp", addr)
+	assert.Nil(t, err)
+	c := &pgClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	// Ask for SSL first like psql does
+	ssl := binary.BigEndian.AppendUint32(nil, 8)
+	ssl = binary.BigEndian.AppendUint32(ssl, pgSSLRequest)
+	_, err = conn.Write(ssl)
+	assert.Nil(t, err)
+	answer, err := c.r.ReadByte()
+	assert.Nil(t, err)
+	assert.Equal(t, byte('N'), answer)
+
+	body := binary.BigEndian.AppendUint32(nil, pgProtocolVersion)
+	body = append(body, "user\x00gosql\x00\x00"...)
+	_, err = conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(body)+4)), body...))
+	assert.Nil(t, err)
+
+	messages := c.readUntilReady()
+	assert.Equal(t, byte('R'), messages[0].kind)
+	return c
+}
+
+func (c *pgClient) send(kind byte, body []byte) {
+	msg := append([]byte{kind}, binary.BigEndian.AppendUint32(nil, uint32(len(body)+4))...)
+	_, err := c.conn.Write(append(msg, body...))
+	assert.Nil(c.t, err)
+}
+
+func (c *pgClient) readUntilReady() []pgMessage {
+	messages := []pgMessage{}
+	for {
+		kind, err := c.r.ReadByte()
+		assert.Nil(c.t, err)
+		var length uint32
+		assert.Nil(c.t, binary.Read(c.r, binary.BigEndian, &length))
+		body := make([]byte, length-4)
+		_, err = io.ReadFull(c.r, body)
+		assert.Nil(c.t, err)
+
+		messages = append(messages, pgMessage{kind: kind, body: body})
+		if kind == 'Z' {
+			return messages
+		}
+	}
+}
+
+func (c *pgClient) query(q string) []pgMessage {
+	c.send('Q', append([]byte(q), 0))
+	return c.readUntilReady()
+}
+
+func TestPgServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	go NewPgServer(NewMemoryBackend()).Serve(l)
+
+	c := dialPgServer(t, l.Addr().String())
+	defer c.conn.Close()
+
+	messages := c.query(`CREATE TABLE users (id INT, name TEXT); INSERT INTO users VALUES (1, "Carlos");`)
+	assert.Equal(t, []pgMessage{
+		{kind: 'C', body: []byte("CREATE TABLE\x00")},
+		{kind: 'C', body: []byte("INSERT 0 1\x00")},
+		{kind: 'Z', body: []byte("I")},
+	}, messages)
+
+	messages = c.query(`SELECT id, name FROM users;`)
+	assert.Equal(t, 4, len(messages))
+
+	description := messages[0]
+	assert.Equal(t, byte('T'), description.kind)
+	assert.Equal(t, uint16(2), binary.BigEndian.Uint16(description.body))
+	assert.Equal(t, "id", cString(description.body[2:]))
+	assert.Equal(t, uint32(pgInt4OID), binary.BigEndian.Uint32(description.body[2+3+6:]))
+
+	row := messages[1]
+	assert.Equal(t, byte('D'), row.kind)
+	assert.Equal(t, []byte("\x00\x02\x00\x00\x00\x011\x00\x00\x00\x06Carlos"), row.body)
+	assert.Equal(t, pgMessage{kind: 'C', body: []byte("SELECT 1\x00")}, messages[2])
+
+	messages = c.query(`EXPLAIN SELECT id = 1 FROM users;`)
+	assert.Equal(t, 5, len(messages))
+	assert.Equal(t, []byte("\x00\x01\x00\x00\x00\x15Projection ((id = 1))"), messages[1].body)
+	assert.Equal(t, pgMessage{kind: 'C', body: []byte("EXPLAIN\x00")}, messages[3])
+
+	messages = c.query(`SELECT id = 1 FROM users;`)
+	assert.Equal(t, uint32(pgBoolOID), binary.BigEndian.Uint32(messages[0].body[2+9+6:]))
+	assert.Equal(t, []byte("\x00\x01\x00\x00\x00\x01t"), messages[1].body)
+
+	// Errors carry a SQLSTATE and leave the connection usable
+	messages = c.query(`SELECT id FROM missing;`)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, byte('E'), messages[0].kind)
+	assert.Contains(t, string(messages[0].body), "C42P01\x00")
+	messages = c.query(`SELECT name + 1 FROM users;`)
+	assert.Contains(t, string(messages[0].body), "C42804\x00")
+	messages = c.query(`SELECT nothing(id) FROM users;`)
+	assert.Contains(t, string(messages[0].body), "C42883\x00")
+
+	// Statements are checked before they run, with the error saying where the mistake is
+	messages = c.query(`INSERT INTO users VALUES (2, "Ana"); INSERT INTO users VALUES ('two', "Luis");`)
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, pgMessage{kind: 'C', body: []byte("INSERT 0 1\x00")}, messages[0])
+	assert.Equal(t, byte('E'), messages[1].kind)
+	assert.Contains(t, string(messages[1].body), "C42804\x00")
+	assert.Contains(t, string(messages[1].body), "at 0:63")
+	messages = c.query(`SELECT id FROM users;`)
+	assert.Equal(t, pgMessage{kind: 'C', body: []byte("SELECT 2\x00")}, messages[3])
+
+	messages = c.query(``)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, byte('I'), messages[0].kind)
+
+	// Extended protocol messages are refused until the next Sync
+	c.send('P', []byte("\x00SELECT 1\x00\x00\x00"))
+	c.send('B', []byte("\x00\x00\x00\x00\x00\x00\x00\x00"))
+	c.send('S', nil)
+	messages = c.readUntilReady()
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, byte('E'), messages[0].kind)
+
+	c.send('X', nil)
+}
+
+func TestPgServer_queryTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	server := NewPgServer(NewMemoryBackend())
+	server.QueryTimeout = time.Nanosecond
+	go server.Serve(l)
+
+	c := dialPgServer(t, l.Addr().String())
+	defer c.conn.Close()
+
+	messages := c.query(`CREATE TABLE users (id INT);`)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, byte('E'), messages[0].kind)
+	assert.Contains(t, string(messages[0].body), "C57014\x00")
+}
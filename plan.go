@@ -0,0 +1,550 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+Planning
+--------
+A select is first turned into a logical plan, a tree of nodes describing what has to happen to the rows of its
+tables: scan them, join them, filter them, group them, sort them and compute the select items. The logical plan is
+then built into the physical operators that do it, see exec.go:
+
+	Projection (name, count(*))
+	  -> Sort (count(*) DESC)
+	    -> Hash Aggregate (group by name)
+	      -> Filter (id > 1)
+	        -> Seq Scan on users
+
+On the way the select is simplified, see rewrite.go, and its joins and conditions are arranged by the optimizer,
+see optimizer.go. That tree is what EXPLAIN shows. EXPLAIN ANALYZE also runs it, reporting how many rows every
+operator produced and how long it took.
+*/
+
+// catalog is what the planner needs from a backend
+type catalog interface {
+	tableColumns(name string) ([]resultColumn, error)
+	// Scans only produce the columns at positions, all of them when it's nil
+	scanTable(ctx context.Context, name string, positions []int) (operator, error)
+
+	// The statistics ANALYZE collected for a table, nil if it never ran on it
+	tableStats(name string) *tableStats
+	tableIndexes(name string) []indexInfo
+	scanIndex(ctx context.Context, table, index string, r indexRange, positions []int) (operator, error)
+}
+
+type planNode interface {
+	columns() []resultColumn
+}
+
+// A scan only produces the columns of the table at positions, every one of them when it's nil
+type scanNode struct {
+	table     string
+	cols      []resultColumn
+	positions []int
+}
+
+// indexScanNode reads the rows of a table whose indexed column is within a range
+type indexScanNode struct {
+	table     string
+	index     string
+	cols      []resultColumn
+	positions []int
+	r         indexRange
+}
+
+// valuesNode is the single empty row a select without FROM computes its items on
+type valuesNode struct{}
+
+type filterNode struct {
+	child     planNode
+	condition *expression
+}
+
+// A join without condition is a cross join
+type joinNode struct {
+	left      planNode
+	right     planNode
+	condition *expression
+}
+
+type aggregateNode struct {
+	child      planNode
+	groupBy    []*expression
+	aggregates []*callExpression
+	cols       []resultColumn
+}
+
+type sortNode struct {
+	child   planNode
+	orderBy []*orderByItem
+}
+
+// The names of the result columns are those of the items as they were written, before folding
+type projectNode struct {
+	child planNode
+	items []*expression
+	names []string
+}
+
+func (n *scanNode) columns() []resultColumn      { return n.cols }
+func (n *indexScanNode) columns() []resultColumn { return n.cols }
+func (n *valuesNode) columns() []resultColumn    { return nil }
+func (n *filterNode) columns() []resultColumn    { return n.child.columns() }
+func (n *joinNode) columns() []resultColumn {
+	return append(append([]resultColumn{}, n.left.columns()...), n.right.columns()...)
+}
+func (n *aggregateNode) columns() []resultColumn { return n.cols }
+func (n *sortNode) columns() []resultColumn      { return n.child.columns() }
+func (n *projectNode) columns() []resultColumn   { return nil }
+
+// planSelect builds the operators running a select
+func planSelect(ctx context.Context, slct *SelectStatement, c catalog) (operator, error) {
+	node, err := logicalPlan(slct, c)
+	if err != nil {
+		return nil, err
+	}
+	return buildPlan(ctx, node, c, false)
+}
+
+func newScanNode(c catalog, table token, alias *token) (*scanNode, error) {
+	cols, err := c.tableColumns(table.value)
+	if err != nil {
+		return nil, err
+	}
+
+	name := table.value
+	if alias != nil {
+		name = alias.value
+	}
+
+	scanned := []resultColumn{}
+	for _, col := range cols {
+		scanned = append(scanned, resultColumn{name: col.name, typ: col.typ, table: name, char: col.char})
+	}
+	return &scanNode{table: table.value, cols: scanned}, nil
+}
+
+func logicalPlan(slct *SelectStatement, c catalog) (planNode, error) {
+	tables := []*scanNode{}
+	if slct.from.value != "" {
+		scan, err := newScanNode(c, slct.from, slct.alias)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, scan)
+
+		for _, join := range slct.joins {
+			right, err := newScanNode(c, join.table, join.alias)
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, right)
+		}
+	}
+
+	for _, join := range slct.joins {
+		if aggs := collectAggregates(join.on, nil); len(aggs) > 0 {
+			err := fmt.Errorf("%w: aggregates aren't allowed in JOIN conditions", ErrInvalidSelectItem)
+			return nil, atLocation(err, aggs[0].name.loc)
+		}
+	}
+	if slct.where != nil {
+		if aggs := collectAggregates(slct.where, nil); len(aggs) > 0 {
+			err := fmt.Errorf("%w: aggregates aren't allowed in WHERE", ErrInvalidSelectItem)
+			return nil, atLocation(err, aggs[0].name.loc)
+		}
+	}
+
+	// The columns in the order the tables were written, whatever order the optimizer joins them in
+	cols := []resultColumn{}
+	for _, table := range tables {
+		cols = append(cols, table.cols...)
+	}
+	items, err := expandStar(slct.item, cols)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, item := range items {
+		names = append(names, columnName(item))
+	}
+
+	slct = foldSelect(slct)
+	items = foldExpressions(items)
+
+	identifiers := selectIdentifiers(slct, items)
+	for _, table := range tables {
+		table.positions = usedColumns(table.cols, identifiers)
+		table.cols = pickColumns(table.cols, table.positions)
+	}
+
+	var node planNode = &valuesNode{}
+	if len(tables) > 0 {
+		if node, err = optimizeJoins(c, slct, tables); err != nil {
+			return nil, err
+		}
+	} else if slct.where != nil {
+		if _, err := compileCondition(slct.where, nil); err != nil {
+			return nil, err
+		}
+		if where := simplifyCondition(slct.where); !isBoolLiteral(where, true) {
+			node = &filterNode{child: node, condition: where}
+		}
+	}
+
+	aggs := []*callExpression{}
+	for _, item := range items {
+		aggs = collectAggregates(item, aggs)
+	}
+	for _, item := range slct.orderBy {
+		aggs = collectAggregates(item.exp, aggs)
+	}
+	if len(slct.groupBy) > 0 || len(aggs) > 0 {
+		childCols := node.columns()
+		node, err = newAggregateNode(node, slct.groupBy, aggs)
+		if err != nil {
+			return nil, err
+		}
+
+		exps := append([]*expression{}, items...)
+		for _, item := range slct.orderBy {
+			exps = append(exps, item.exp)
+		}
+		for _, exp := range exps {
+			if column := ungroupedColumn(exp, node.columns(), childCols); column != nil {
+				err := fmt.Errorf("%w: column %s must appear in GROUP BY or be used in an aggregate",
+					ErrInvalidSelectItem, column.value)
+				return nil, atLocation(err, column.loc)
+			}
+		}
+	}
+
+	if len(slct.orderBy) > 0 {
+		node = &sortNode{child: node, orderBy: slct.orderBy}
+	}
+	return &projectNode{child: node, items: items, names: names}, nil
+}
+
+// expandStar replaces * among the select items with every column
+func expandStar(items []*expression, cols []resultColumn) ([]*expression, error) {
+	expanded := []*expression{}
+	for _, item := range items {
+		if item.kind != literalKind || item.literal.kind != symbolKind || item.literal.value != string(asteriskSymbol) {
+			expanded = append(expanded, item)
+			continue
+		}
+
+		if len(cols) == 0 {
+			return nil, fmt.Errorf("%w: * needs a FROM", ErrInvalidSelectItem)
+		}
+		for _, col := range cols {
+			name := col.name
+			if col.table != "" {
+				name = col.table + "." + col.name
+			}
+			literal := &token{value: name, kind: identifierKind, loc: item.literal.loc}
+			expanded = append(expanded, &expression{literal: literal, kind: literalKind})
+		}
+	}
+	return expanded, nil
+}
+
+func newAggregateNode(child planNode, groupBy []*expression, aggs []*callExpression) (*aggregateNode, error) {
+	node := &aggregateNode{child: child, groupBy: groupBy, aggregates: aggs}
+	childCols := child.columns()
+
+	for _, exp := range groupBy {
+		if aggs := collectAggregates(exp, nil); len(aggs) > 0 {
+			err := fmt.Errorf("%w: aggregates aren't allowed in GROUP BY", ErrInvalidSelectItem)
+			return nil, atLocation(err, aggs[0].name.loc)
+		}
+
+		_, typ, err := compileExpression(exp, childCols)
+		if err != nil {
+			return nil, err
+		}
+
+		// Grouped columns can still be referred to by name
+		col := resultColumn{name: exp.generateCode(), typ: typ, expr: exp.generateCode()}
+		if exp.kind == literalKind && exp.literal.kind == identifierKind {
+			i, _ := resolveColumn(childCols, exp.literal.value)
+			col.name, col.table = childCols[i].name, childCols[i].table
+		}
+		node.cols = append(node.cols, col)
+	}
+
+	for _, call := range aggs {
+		agg, err := compileAggregate(call, childCols)
+		if err != nil {
+			return nil, err
+		}
+		node.cols = append(node.cols, resultColumn{name: agg.name, typ: agg.typ, expr: call.generateCode()})
+	}
+	return node, nil
+}
+
+// ungroupedColumn finds a column of the rows being grouped that exp refers to outside of any aggregate or
+// grouped expression, which has no single value in a group
+func ungroupedColumn(exp *expression, grouped, cols []resultColumn) *token {
+	code := exp.generateCode()
+	for _, col := range grouped {
+		if col.expr != "" && col.expr == code {
+			return nil
+		}
+	}
+
+	switch exp.kind {
+	case literalKind:
+		if exp.literal.kind != identifierKind {
+			return nil
+		}
+		if _, err := resolveColumn(grouped, exp.literal.value); !errors.Is(err, ErrColumnDoesNotExist) {
+			return nil
+		}
+		if _, err := resolveColumn(cols, exp.literal.value); err != nil {
+			return nil
+		}
+		return exp.literal
+	case binaryKind:
+		if column := ungroupedColumn(exp.binary.a, grouped, cols); column != nil {
+			return column
+		}
+		return ungroupedColumn(exp.binary.b, grouped, cols)
+	case callKind:
+		if isAggregate(exp.call) {
+			return nil
+		}
+		for _, arg := range exp.call.args {
+			if column := ungroupedColumn(arg, grouped, cols); column != nil {
+				return column
+			}
+		}
+	case castKind:
+		return ungroupedColumn(exp.cast.exp, grouped, cols)
+	case arrayKind:
+		for _, element := range exp.array.elements {
+			if column := ungroupedColumn(element, grouped, cols); column != nil {
+				return column
+			}
+		}
+	case subscriptKind:
+		if column := ungroupedColumn(exp.subscript.exp, grouped, cols); column != nil {
+			return column
+		}
+		return ungroupedColumn(exp.subscript.index, grouped, cols)
+	}
+	return nil
+}
+
+// columnName is the name of the result column of a select item
+func columnName(exp *expression) string {
+	switch exp.kind {
+	case literalKind:
+		if exp.literal.kind == identifierKind {
+			name := exp.literal.value
+			return name[strings.LastIndex(name, ".")+1:]
+		}
+	case callKind:
+		return exp.call.name.value
+	}
+	return "?column?"
+}
+
+// buildPlan builds the physical operators of a logical plan, wrapping each of them to count their rows and time
+// when analyzing
+func buildPlan(ctx context.Context, node planNode, c catalog, analyze bool) (operator, error) {
+	op, err := buildOperator(ctx, node, c, analyze)
+	if err != nil {
+		return nil, err
+	}
+	if analyze {
+		return &analyzed{operator: op}, nil
+	}
+	return op, nil
+}
+
+func buildOperator(ctx context.Context, node planNode, c catalog, analyze bool) (operator, error) {
+	switch n := node.(type) {
+	case *scanNode:
+		return c.scanTable(ctx, n.table, n.positions)
+	case *indexScanNode:
+		return c.scanIndex(ctx, n.table, n.index, n.r, n.positions)
+	case *valuesNode:
+		return &values{name: "Result", rows: [][]MemoryCell{{}}}, nil
+	}
+
+	// Every other node has children to build first, which have to be closed if building the node fails
+	var children []operator
+	fail := func(err error) (operator, error) {
+		for _, child := range children {
+			child.close()
+		}
+		return nil, err
+	}
+
+	var childNodes []planNode
+	switch n := node.(type) {
+	case *filterNode:
+		childNodes = []planNode{n.child}
+	case *joinNode:
+		childNodes = []planNode{n.left, n.right}
+	case *aggregateNode:
+		childNodes = []planNode{n.child}
+	case *sortNode:
+		childNodes = []planNode{n.child}
+	case *projectNode:
+		childNodes = []planNode{n.child}
+	}
+	for _, childNode := range childNodes {
+		child, err := buildPlan(ctx, childNode, c, analyze)
+		if err != nil {
+			return fail(err)
+		}
+		children = append(children, child)
+	}
+
+	switch n := node.(type) {
+	case *filterNode:
+		condition, err := compileCondition(n.condition, n.child.columns())
+		if err != nil {
+			return fail(err)
+		}
+		return &filter{child: children[0], condition: condition, code: n.condition.generateCode()}, nil
+	case *joinNode:
+		if n.condition == nil {
+			return newNestedLoopJoin(children[0], children[1], nil, ""), nil
+		}
+		condition, err := compileCondition(n.condition, n.columns())
+		if err != nil {
+			return fail(err)
+		}
+		return newNestedLoopJoin(children[0], children[1], condition, n.condition.generateCode()), nil
+	case *aggregateNode:
+		agg := &hashAggregate{child: children[0], cols: n.cols}
+		for _, exp := range n.groupBy {
+			key, _, err := compileExpression(exp, n.child.columns())
+			if err != nil {
+				return fail(err)
+			}
+			agg.keys = append(agg.keys, key)
+		}
+		for _, call := range n.aggregates {
+			compiled, err := compileAggregate(call, n.child.columns())
+			if err != nil {
+				return fail(err)
+			}
+			agg.aggregates = append(agg.aggregates, compiled)
+		}
+		return agg, nil
+	case *sortNode:
+		s := &sorter{child: children[0]}
+		for _, item := range n.orderBy {
+			key, typ, err := compileExpression(item.exp, n.child.columns())
+			if err != nil {
+				return fail(err)
+			}
+			code := item.exp.generateCode()
+			if item.desc {
+				code += " DESC"
+			}
+			s.keys = append(s.keys, key)
+			s.types = append(s.types, typ)
+			s.desc = append(s.desc, item.desc)
+			s.codes = append(s.codes, code)
+		}
+		return s, nil
+	case *projectNode:
+		p := &projection{child: children[0]}
+		for i, item := range n.items {
+			eval, typ, err := compileItem(item, n.child.columns())
+			if err != nil {
+				return fail(err)
+			}
+			p.items = append(p.items, eval)
+			p.sets = append(p.sets, isUnnest(item))
+			p.cols = append(p.cols, resultColumn{name: n.names[i], typ: typ})
+			p.codes = append(p.codes, item.generateCode())
+		}
+		return p, nil
+	}
+	return fail(fmt.Errorf("unknown plan node %T", node))
+}
+
+// compileItem compiles a select item, which may be a set-returning unnest(...) giving arrays, see projection
+func compileItem(item *expression, cols []resultColumn) (evaluator, ColumnType, error) {
+	if isUnnest(item) {
+		ev, typ, err := compileUnnest(item.call, cols)
+		return ev, typ, atLocation(err, item.loc())
+	}
+	// CHAR columns selected as they are keep their padding
+	if item.kind == literalKind && item.literal.kind == identifierKind {
+		if i, err := resolveColumn(cols, item.literal.value); err == nil && cols[i].char {
+			return columnEvaluator(i), cols[i].typ, nil
+		}
+	}
+	return compileExpression(item, cols)
+}
+
+// compileCondition compiles the condition of a WHERE or a JOIN, which must be a boolean
+func compileCondition(exp *expression, cols []resultColumn) (evaluator, error) {
+	condition, typ, err := compileExpression(exp, cols)
+	if err != nil {
+		return nil, err
+	}
+	if typ != BoolType && !isNullLiteral(exp) {
+		err := fmt.Errorf("%w: condition %s must be a BOOL, not %s", ErrInvalidDatatype, exp.generateCode(), typ)
+		return nil, atLocation(err, exp.loc())
+	}
+	return condition, nil
+}
+
+// explain plans a select and, when analyzing, runs it, returning the lines of its plan as rows
+func explain(ctx context.Context, expl *ExplainStatement, c catalog) (*Rows, error) {
+	node, err := logicalPlan(expl.statement, c)
+	if err != nil {
+		return nil, err
+	}
+	op, err := buildPlan(ctx, node, c, expl.analyze)
+	if err != nil {
+		return nil, err
+	}
+
+	if expl.analyze {
+		for {
+			_, ok, err := op.next()
+			if err != nil {
+				op.close()
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+		}
+	}
+	if err := op.close(); err != nil {
+		return nil, err
+	}
+
+	plan := &values{
+		name: "Explain",
+		cols: []resultColumn{{name: "QUERY PLAN", typ: TextType}},
+	}
+	var describe func(op operator, depth int)
+	describe = func(op operator, depth int) {
+		line := op.describe()
+		if depth > 0 {
+			line = strings.Repeat("  ", depth) + "-> " + line
+		}
+		plan.rows = append(plan.rows, []MemoryCell{MemoryCell(line)})
+		for _, child := range op.children() {
+			describe(child, depth+1)
+		}
+	}
+	describe(op, 0)
+	return newRows(plan), nil
+}
@@ -0,0 +1,428 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// queryAll runs a query returning every row as Go values
+func queryAll(t *testing.T, db *DB, query string) ([][]any, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := [][]any{}
+	for rows.Next() {
+		values := make([]any, len(rows.Columns()))
+		dest := []any{}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		assert.Nil(t, rows.Scan(dest...))
+		all = append(all, values)
+	}
+	return all, rows.Err()
+}
+
+func openFixture(t *testing.T) *DB {
+	db := Open()
+	_, err := db.Exec(`
+		CREATE TABLE users (id INT, name TEXT, team INT);
+		INSERT INTO users VALUES (1, "Carlos", 1);
+		INSERT INTO users VALUES (2, "Ana", 2);
+		INSERT INTO users VALUES (3, "Luis", 1);
+		INSERT INTO users VALUES (4, "Eva", NULL);
+		CREATE TABLE teams (id INT, name TEXT);
+		INSERT INTO teams VALUES (1, "red");
+		INSERT INTO teams VALUES (2, "blue");`)
+	assert.Nil(t, err)
+	return db
+}
+
+func TestPlanSelect(t *testing.T) {
+	db := openFixture(t)
+	defer db.Close()
+
+	tests := []struct {
+		query string
+		rows  [][]any
+		err   error
+	}{
+		{
+			query: `SELECT 1 + 2 * 3, "a" || 1;`,
+			rows:  [][]any{{int64(7), "a1"}},
+		},
+		{
+			query: `SELECT name FROM users WHERE id > 1 AND id < 4;`,
+			rows:  [][]any{{"Ana"}, {"Luis"}},
+		},
+		{
+			query: `SELECT id FROM users WHERE team = 1 OR name = "Eva";`,
+			rows:  [][]any{{int64(1)}, {int64(3)}, {int64(4)}},
+		},
+		{
+			query: `SELECT id, team = 1 FROM users WHERE id <= 2;`,
+			rows:  [][]any{{int64(1), true}, {int64(2), false}},
+		},
+		{
+			query: `SELECT id FROM users ORDER BY team DESC, id;`,
+			rows:  [][]any{{int64(4)}, {int64(2)}, {int64(1)}, {int64(3)}},
+		},
+		{
+			query: `SELECT u.name, t.name FROM users u JOIN teams AS t ON u.team = t.id ORDER BY u.id DESC;`,
+			rows:  [][]any{{"Luis", "red"}, {"Ana", "blue"}, {"Carlos", "red"}},
+		},
+		{
+			query: `SELECT count(*) FROM users CROSS JOIN teams;`,
+			rows:  [][]any{{int64(8)}},
+		},
+		{
+			query: `SELECT teams.name, count(*), sum(users.id), min(users.name) FROM users INNER JOIN teams ON team = teams.id GROUP BY teams.name ORDER BY count(*) DESC;`,
+			rows:  [][]any{{"red", int64(2), int64(4), "Carlos"}, {"blue", int64(1), int64(2), "Ana"}},
+		},
+		{
+			query: `SELECT count(team), avg(id), max(id) FROM users;`,
+			rows:  [][]any{{int64(3), int64(2), int64(4)}},
+		},
+		{
+			query: `SELECT count(*), max(id) FROM users WHERE id > 10;`,
+			rows:  [][]any{{int64(0), nil}},
+		},
+		{
+			query: `SELECT * FROM teams WHERE id = 2;`,
+			rows:  [][]any{{int64(2), "blue"}},
+		},
+		{
+			query: `SELECT id FROM users WHERE team = NULL;`,
+			rows:  [][]any{},
+		},
+		{
+			query: `SELECT name FROM users JOIN teams ON team = teams.id;`,
+			err:   ErrAmbiguousColumn,
+		},
+		{
+			query: `SELECT name FROM users GROUP BY team;`,
+			err:   ErrInvalidSelectItem,
+		},
+		{
+			query: `SELECT nme FROM users GROUP BY team;`,
+			err:   ErrColumnDoesNotExist,
+		},
+		{
+			query: `SELECT id FROM users WHERE count(*) > 1;`,
+			err:   ErrInvalidSelectItem,
+		},
+		{
+			query: `SELECT id FROM users WHERE name;`,
+			err:   ErrInvalidDatatype,
+		},
+		{
+			query: `SELECT id FROM users WHERE name = 1;`,
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: `SELECT soundex(name) FROM users;`,
+			err:   ErrFunctionDoesNotExist,
+		},
+		{
+			query: `SELECT id / 0 FROM users;`,
+			err:   ErrDivisionByZero,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+			continue
+		}
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	db := openFixture(t)
+	defer db.Close()
+
+	rows, err := queryAll(t, db, `EXPLAIN SELECT teams.name, count(*) FROM users JOIN teams ON team = teams.id WHERE users.id > 1 GROUP BY teams.name ORDER BY count(*) DESC;`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]any{
+		{"Projection (teams.name, count(*))"},
+		{"  -> Sort (count(*) DESC)"},
+		{"    -> Hash Aggregate (group by teams.name)"},
+		{"      -> Nested Loop Join (team = teams.id)"},
+		{"        -> Seq Scan on teams"},
+		{"        -> Filter (users.id > 1)"},
+		{"          -> Seq Scan on users"},
+	}, rows)
+
+	rows, err = queryAll(t, db, `EXPLAIN ANALYZE SELECT name FROM users WHERE id > 1;`)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(rows))
+	analyzed := regexp.MustCompile(`^(\s*-> )?(.+) \(actual rows=(\d+) time=\d+\.\d{3}ms\)$`)
+	for i, expected := range [][2]string{
+		{"Projection (name)", "3"},
+		{"Filter (id > 1)", "3"},
+		{"Seq Scan on users", "4"},
+	} {
+		match := analyzed.FindStringSubmatch(rows[i][0].(string))
+		assert.NotNil(t, match, rows[i][0])
+		if match != nil {
+			assert.Equal(t, expected[0], match[2])
+			assert.Equal(t, expected[1], match[3])
+		}
+	}
+
+	_, err = queryAll(t, db, `EXPLAIN SELECT id FROM missing;`)
+	assert.Equal(t, ErrTableDoesNotExist, err)
+}
+
+// openNumbers has a hundred numbers with their parity and three colors, all analyzed
+func openNumbers(t *testing.T) (*DB, *MemoryBackend) {
+	mb := NewMemoryBackend()
+	db := OpenBackend(mb)
+
+	var script strings.Builder
+	script.WriteString(`CREATE TABLE numbers (id INT, parity INT); CREATE INDEX numbers_id ON numbers (id);`)
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&script, `INSERT INTO numbers VALUES (%d, %d);`, i, i%2)
+	}
+	script.WriteString(`CREATE TABLE colors (id INT, name TEXT);
+		INSERT INTO colors VALUES (0, "red");
+		INSERT INTO colors VALUES (1, "blue");
+		INSERT INTO colors VALUES (2, "green");
+		ANALYZE;`)
+	_, err := db.Exec(script.String())
+	assert.Nil(t, err)
+	return db, mb
+}
+
+func TestAnalyze(t *testing.T) {
+	_, mb := openNumbers(t)
+
+	stats := mb.tables["numbers"].stats
+	assert.Equal(t, 100, stats.rows)
+	assert.Equal(t, 100, stats.columns[0].distinct)
+	assert.Equal(t, 2, stats.columns[1].distinct)
+	assert.Equal(t, histogramBuckets+1, len(stats.columns[0].bounds))
+	assert.Equal(t, int32(1), stats.columns[0].bounds[0].AsInt())
+	assert.Equal(t, int32(100), stats.columns[0].bounds[histogramBuckets].AsInt())
+	assert.InDelta(t, 0.5, stats.columns[0].less(intCell(51), stats.rows), 0.02)
+	assert.Equal(t, 0.0, stats.columns[0].less(intCell(-5), stats.rows))
+	assert.Equal(t, 1.0, stats.columns[0].less(intCell(500), stats.rows))
+
+	colors := mb.tables["colors"].stats
+	assert.Equal(t, 3, colors.rows)
+	assert.Equal(t, 3, len(colors.columns[1].bounds))
+
+	assert.Equal(t, ErrTableDoesNotExist, mb.Analyze(context.Background(), &AnalyzeStatement{table: &token{value: "missing"}}))
+}
+
+func TestOptimizer(t *testing.T) {
+	db, _ := openNumbers(t)
+	defer db.Close()
+
+	tests := []struct {
+		query string
+		plan  []string
+		rows  [][]any
+	}{
+		{
+			query: `SELECT id FROM numbers WHERE id > 95 AND parity = 1;`,
+			plan: []string{
+				"Projection (id)",
+				"  -> Filter (parity = 1)",
+				"    -> Index Scan using numbers_id on numbers (id > 95)",
+			},
+			rows: [][]any{{int64(97)}, {int64(99)}},
+		},
+		{
+			query: `SELECT id FROM numbers WHERE 5 >= id AND id > 1 + 1;`,
+			plan: []string{
+				"Projection (id)",
+				"  -> Index Scan using numbers_id on numbers (id > 2 AND id <= 5)",
+			},
+			rows: [][]any{{int64(3)}, {int64(4)}, {int64(5)}},
+		},
+		{
+			query: `SELECT parity FROM numbers WHERE id = 42;`,
+			plan: []string{
+				"Projection (parity)",
+				"  -> Index Scan using numbers_id on numbers (id = 42)",
+			},
+			rows: [][]any{{int64(0)}},
+		},
+		{
+			// Most rows match, scanning is cheaper
+			query: `SELECT count(*) FROM numbers WHERE id > 10;`,
+			plan: []string{
+				"Projection (count(*))",
+				"  -> Aggregate",
+				"    -> Filter (id > 10)",
+				"      -> Seq Scan on numbers",
+			},
+			rows: [][]any{{int64(90)}},
+		},
+		{
+			// The smaller table goes on the inner side of the join
+			query: `SELECT name, count(*) FROM colors JOIN numbers ON parity = colors.id WHERE numbers.id <= 10 AND name != "green" GROUP BY name;`,
+			plan: []string{
+				"Projection (name, count(*))",
+				"  -> Hash Aggregate (group by name)",
+				"    -> Nested Loop Join (parity = colors.id)",
+				"      -> Index Scan using numbers_id on numbers (id <= 10)",
+				"      -> Filter (name != \"green\")",
+				"        -> Seq Scan on colors",
+			},
+			rows: [][]any{{"blue", int64(5)}, {"red", int64(5)}},
+		},
+		{
+			// Columns that become ambiguous when reordering get qualified
+			query: `SELECT count(*) FROM numbers JOIN colors ON parity = colors.id JOIN numbers AS n ON numbers.id = n.id WHERE n.id < 5;`,
+			plan: []string{
+				"Projection (count(*))",
+				"  -> Aggregate",
+				"    -> Nested Loop Join (numbers.parity = colors.id)",
+				"      -> Nested Loop Join (numbers.id = n.id)",
+				"        -> Seq Scan on numbers",
+				"        -> Index Scan using numbers_id on numbers (id < 5)",
+				"      -> Seq Scan on colors",
+			},
+			rows: [][]any{{int64(4)}},
+		},
+	}
+
+	for _, test := range tests {
+		plan, err := queryAll(t, db, "EXPLAIN "+test.query)
+		assert.Nil(t, err, test.query)
+		lines := []string{}
+		for _, row := range plan {
+			lines = append(lines, row[0].(string))
+		}
+		assert.Equal(t, test.plan, lines, test.query)
+
+		rows, err := queryAll(t, db, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	// Indexes keep up with inserts
+	_, err := db.Exec(`INSERT INTO numbers VALUES (1000, 0);`)
+	assert.Nil(t, err)
+	rows, err := queryAll(t, db, `SELECT parity FROM numbers WHERE id = 1000;`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]any{{int64(0)}}, rows)
+
+	_, err = db.Exec(`CREATE INDEX numbers_id ON colors (id);`)
+	assert.Equal(t, ErrIndexAlreadyExists, err)
+	_, err = db.Exec(`CREATE INDEX colors_missing ON colors (missing);`)
+	assert.True(t, errors.Is(err, ErrColumnDoesNotExist))
+}
+
+func TestRewrites(t *testing.T) {
+	tests := []struct {
+		exp    string
+		folded string
+	}{
+		{exp: `age > 10 + 5`, folded: `(age > 15)`},
+		{exp: `1 = 1 AND age > 2 * -3`, folded: `(true AND (age > -6))`},
+		{exp: `"a" || 1 || "b"`, folded: `"a1b"`},
+		{exp: `count(1 + 1)`, folded: `count(2)`},
+		{exp: `1 / 0`, folded: `(1 / 0)`},
+		{exp: `1 + NULL`, folded: `(1 + null)`},
+		{exp: `"a" = 1`, folded: `("a" = 1)`},
+		{exp: `ARRAY[1 + 1, 3][2 - 1]`, folded: `ARRAY[2, 3][1]`},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse("SELECT " + test.exp + ";")
+		assert.Nil(t, err, test.exp)
+		exp := ast.Statements[0].SelectStatement.item[0]
+		assert.Equal(t, test.folded, foldConstants(exp).generateCode(), test.exp)
+	}
+
+	db := openFixture(t)
+	defer db.Close()
+
+	for query, plan := range map[string][]string{
+		`SELECT name FROM users WHERE 1 = 1 AND id > 10 - 9;`: {
+			"Projection (name)",
+			"  -> Filter (id > 1)",
+			"    -> Seq Scan on users",
+		},
+		`SELECT name FROM users WHERE 1 = 2 OR id = 1;`: {
+			"Projection (name)",
+			"  -> Filter (id = 1)",
+			"    -> Seq Scan on users",
+		},
+		`SELECT name FROM users WHERE id = 1 AND 1 > 2;`: {
+			"Projection (name)",
+			"  -> Filter false",
+			"    -> Seq Scan on users",
+		},
+		`SELECT 1 + 1 WHERE TRUE;`: {
+			"Projection (2)",
+			"  -> Result",
+		},
+		`SELECT unnest(ARRAY[1, 2]);`: {
+			"ProjectSet (unnest(ARRAY[1, 2]))",
+			"  -> Result",
+		},
+	} {
+		rows, err := queryAll(t, db, "EXPLAIN "+query)
+		assert.Nil(t, err, query)
+		lines := []string{}
+		for _, row := range rows {
+			lines = append(lines, row[0].(string))
+		}
+		assert.Equal(t, plan, lines, query)
+	}
+
+	// Folded items keep the names they were written with
+	result, err := db.Query(`SELECT lower(name), lower('X'), date_trunc('day', TIMESTAMP '2024-01-02 10:00:00'), 1 + 1
+		FROM users;`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"lower", "lower", "date_trunc", "?column?"}, result.Columns())
+		result.Close()
+	}
+
+	// Type errors aren't hidden by simplifying
+	_, err = queryAll(t, db, `SELECT name FROM users WHERE name = 1 OR TRUE;`)
+	assert.True(t, errors.Is(err, ErrInvalidOperands))
+
+	// Scans only produce the columns that are used
+	ast, err := Parse(`SELECT u.name FROM users u JOIN teams ON team = teams.id;`)
+	assert.Nil(t, err)
+	node, err := logicalPlan(ast.Statements[0].SelectStatement, db.backend.(*MemoryBackend))
+	assert.Nil(t, err)
+	scanned := map[string][]string{}
+	var walk func(node planNode)
+	walk = func(node planNode) {
+		switch n := node.(type) {
+		case *scanNode:
+			for _, col := range n.cols {
+				scanned[n.table] = append(scanned[n.table], col.name)
+			}
+		case *joinNode:
+			walk(n.left)
+			walk(n.right)
+		case *projectNode:
+			walk(n.child)
+		}
+	}
+	walk(node)
+	assert.Equal(t, map[string][]string{"users": {"name", "team"}, "teams": {"id"}}, scanned)
+
+	rows, err := queryAll(t, db, `SELECT u.name, t.name FROM users u JOIN teams t ON team = t.id WHERE t.name = "blue";`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]any{{"Ana", "blue"}}, rows)
+}
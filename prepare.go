@@ -0,0 +1,267 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+/*
+Prepared Statements
+-------------------
+A prepared statement is parsed once and can run many times with different values for its parameters. Values are
+bound straight into the expression tree as literal tokens, they never go through the lexer, so there is no way for
+them to change the shape of the statement.
+
+Parameters are either positional, numbered like $1 or ordered like ?, or named like :name. Named parameters take
+their values from sql.Named arguments. A statement can't mix positional and named parameters.
+*/
+
+type Stmt struct {
+	ast *Ast
+	// Number of positional parameters, the highest $n or the count of ?
+	positional int
+	// Named parameters in order of appearance
+	names []string
+}
+
+func Prepare(source string) (*Stmt, error) {
+	return PrepareContext(context.Background(), source)
+}
+
+func PrepareContext(ctx context.Context, source string) (*Stmt, error) {
+	ast, err := ParseContext(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stmt{ast: ast}
+	numbered, ordered := false, 0
+	seen := map[string]bool{}
+	for _, exp := range ast.expressions() {
+		if exp.kind != literalKind || exp.literal.kind != parameterKind {
+			continue
+		}
+
+		param := exp.literal
+		switch param.value[0] {
+		case '$':
+			numbered = true
+			n, err := strconv.Atoi(param.value[1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid parameter %s at %d:%d", param.value, param.loc.line, param.loc.col)
+			}
+			s.positional = max(s.positional, n)
+		case '?':
+			// Number them right away, binding only has to deal with $n from here on
+			ordered++
+			param.value = "$" + strconv.Itoa(ordered)
+			s.positional = ordered
+		case ':':
+			if !seen[param.value] {
+				seen[param.value] = true
+				s.names = append(s.names, param.value[1:])
+			}
+		}
+	}
+
+	if (numbered && ordered > 0) || (s.positional > 0 && len(s.names) > 0) {
+		return nil, ErrMixedParameters
+	}
+	return s, nil
+}
+
+// NumInput returns the number of arguments the statement needs
+func (s *Stmt) NumInput() int {
+	if len(s.names) > 0 {
+		return len(s.names)
+	}
+	return s.positional
+}
+
+// Bind returns a copy of the statement's ast with every parameter replaced by its value
+func (s *Stmt) Bind(args ...any) (*Ast, error) {
+	if len(args) != s.NumInput() {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrWrongNumberOfArguments, s.NumInput(), len(args))
+	}
+
+	values := map[string]any{}
+	for i, arg := range args {
+		if named, ok := arg.(sql.NamedArg); ok {
+			values[":"+named.Name] = named.Value
+			continue
+		}
+		values["$"+strconv.Itoa(i+1)] = arg
+	}
+
+	bind := func(exp *expression) (*expression, error) {
+		if exp.kind != literalKind || exp.literal.kind != parameterKind {
+			return exp, nil
+		}
+
+		value, ok := values[exp.literal.value]
+		if !ok {
+			return nil, fmt.Errorf("%w %s", ErrMissingArgument, exp.literal.value)
+		}
+		literal, err := tokenFromValue(value, exp.literal.loc)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", exp.literal.value, err)
+		}
+		return &expression{kind: literalKind, literal: literal}, nil
+	}
+
+	return s.ast.mapExpressions(bind)
+}
+
+// Exec runs the statement against a backend with the given arguments
+func (s *Stmt) Exec(backend Backend, args ...any) error {
+	return s.ExecContext(context.Background(), backend, args...)
+}
+
+func (s *Stmt) ExecContext(ctx context.Context, backend Backend, args ...any) error {
+	ast, err := s.Bind(args...)
+	if err != nil {
+		return err
+	}
+	_, rows, err := runStatements(ctx, backend, ast)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Query runs the statement against a backend with the given arguments, returning the rows of its last select
+func (s *Stmt) Query(backend Backend, args ...any) (*Rows, error) {
+	return s.QueryContext(context.Background(), backend, args...)
+}
+
+// QueryContext is like Query, ctx keeps being checked while the rows are read
+func (s *Stmt) QueryContext(ctx context.Context, backend Backend, args ...any) (*Rows, error) {
+	ast, err := s.Bind(args...)
+	if err != nil {
+		return nil, err
+	}
+	_, rows, err := runStatements(ctx, backend, ast)
+	return rows, err
+}
+
+// tokenFromValue turns a Go value into the literal token it stands for
+func tokenFromValue(value any, loc location) (*token, error) {
+	t := &token{loc: loc, kind: numericKind}
+	switch v := value.(type) {
+	case nil:
+		t.kind = nullKind
+		t.value = string(nullKeyword)
+	case int:
+		t.value = strconv.FormatInt(int64(v), 10)
+	case int8:
+		t.value = strconv.FormatInt(int64(v), 10)
+	case int16:
+		t.value = strconv.FormatInt(int64(v), 10)
+	case int32:
+		t.value = strconv.FormatInt(int64(v), 10)
+	case int64:
+		t.value = strconv.FormatInt(v, 10)
+	case uint8:
+		t.value = strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		t.value = strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		t.value = strconv.FormatUint(uint64(v), 10)
+	case uint:
+		return uintToken(uint64(v), loc)
+	case uint64:
+		return uintToken(v, loc)
+	case bool:
+		t.kind = boolKind
+		t.value = string(falseKeyword)
+		if v {
+			t.value = string(trueKeyword)
+		}
+	case float32:
+		return floatToken(float64(v), loc)
+	case float64:
+		return floatToken(v, loc)
+	case string:
+		t.kind = stringKind
+		t.value = v
+	case []byte:
+		// As a hex literal, so the bytes are never read as text, see byteaFromText
+		t.kind = hexKind
+		t.value = hexDigits(v)
+	case time.Time:
+		// Timestamps have no time zone, so times are passed in UTC
+		t.kind = stringKind
+		t.value = v.UTC().Format("2006-01-02 15:04:05.999999")
+	default:
+		return nil, fmt.Errorf("%w: unsupported argument type %T", ErrInvalidDatatype, value)
+	}
+	return t, nil
+}
+
+// uintToken writes an unsigned integer, failing when it doesn't fit the widest integer type, BIGINT
+func uintToken(u uint64, loc location) (*token, error) {
+	if u > math.MaxInt64 {
+		return nil, fmt.Errorf("%w: %d doesn't fit in BIGINT", ErrIntegerOutOfRange, u)
+	}
+	return &token{kind: numericKind, value: strconv.FormatUint(u, 10), loc: loc}, nil
+}
+
+// floatToken writes a float so it's read back as one, with a decimal point or an exponent
+func floatToken(f float64, loc location) (*token, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("%w: %v", ErrFloatOutOfRange, f)
+	}
+	return &token{kind: numericKind, value: floatLiteral(f), loc: loc}, nil
+}
+
+// runStatements runs every statement of the ast, returning the number of inserted rows and the rows of the last
+// select. The rows of any earlier select are closed without being read.
+func runStatements(ctx context.Context, backend Backend, ast *Ast) (int64, *Rows, error) {
+	var affected int64
+	rows := emptyRows()
+	for _, stmt := range ast.Statements {
+		_, selected, err := runStatement(ctx, backend, stmt)
+		if err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		if stmt.Kind == InsertKind {
+			affected++
+		}
+		if selected != nil {
+			rows.Close()
+			rows = selected
+		}
+	}
+	return affected, rows, nil
+}
+
+// runStatement checks and runs a single statement, returning the command tag Postgres completes it with and, for
+// selects and EXPLAIN, its rows
+func runStatement(ctx context.Context, backend Backend, stmt *Statement) (string, *Rows, error) {
+	if err := checkStatement(backend, stmt); err != nil {
+		return "", nil, err
+	}
+
+	switch stmt.Kind {
+	case CreateTableKind:
+		return "CREATE TABLE", nil, backend.CreateTable(ctx, stmt.CreateTableStatement)
+	case InsertKind:
+		return "INSERT 0 1", nil, backend.Insert(ctx, stmt.InsertStatement)
+	case CreateIndexKind:
+		return "CREATE INDEX", nil, backend.CreateIndex(ctx, stmt.CreateIndexStatement)
+	case AnalyzeKind:
+		return "ANALYZE", nil, backend.Analyze(ctx, stmt.AnalyzeStatement)
+	case SelectKind:
+		rows, err := backend.Select(ctx, stmt.SelectStatement)
+		return "SELECT", rows, err
+	case ExplainKind:
+		rows, err := backend.Explain(ctx, stmt.ExplainStatement)
+		return "EXPLAIN", rows, err
+	}
+	return "", nil, nil
+}
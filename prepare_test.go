@@ -0,0 +1,113 @@
+package gosql
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepare(t *testing.T) {
+	tests := []struct {
+		source string
+		inputs int
+		err    error
+	}{
+		{
+			source: "INSERT INTO users VALUES ($1, $2);",
+			inputs: 2,
+		},
+		{
+			source: "INSERT INTO users VALUES ($2, $2);",
+			inputs: 2,
+		},
+		{
+			source: "INSERT INTO users VALUES (?, ?); INSERT INTO users VALUES (?, 1);",
+			inputs: 3,
+		},
+		{
+			source: "INSERT INTO users VALUES (:id, :name); SELECT :id FROM users;",
+			inputs: 2,
+		},
+		{
+			source: "SELECT id FROM users;",
+			inputs: 0,
+		},
+		// mixed parameters
+		{
+			source: "INSERT INTO users VALUES ($1, ?);",
+			err:    ErrMixedParameters,
+		},
+		{
+			source: "INSERT INTO users VALUES (?, :name);",
+			err:    ErrMixedParameters,
+		},
+	}
+
+	for _, test := range tests {
+		stmt, err := Prepare(test.source)
+		assert.Equal(t, test.err, err, test.source)
+		if err == nil {
+			assert.Equal(t, test.inputs, stmt.NumInput(), test.source)
+		}
+	}
+}
+
+func TestStmt_Exec(t *testing.T) {
+	mb := NewMemoryBackend()
+	execute(t, mb, `CREATE TABLE users (id INT, name TEXT);`)
+
+	insert, err := Prepare(`INSERT INTO users VALUES (?, ?);`)
+	assert.Nil(t, err)
+	assert.Nil(t, insert.Exec(mb, 1, "Carlos"))
+	// Values never reach the lexer, so they can't break out of the statement
+	assert.Nil(t, insert.Exec(mb, int64(2), `"); CREATE TABLE pwned (id INT); --`))
+	assert.Nil(t, insert.Exec(mb, 3, nil))
+
+	named, err := Prepare(`INSERT INTO users VALUES (:id, :name);`)
+	assert.Nil(t, err)
+	assert.Nil(t, named.Exec(mb, sql.Named("name", "Ana"), sql.Named("id", 4)))
+
+	err = insert.Exec(mb, 1)
+	assert.True(t, errors.Is(err, ErrWrongNumberOfArguments))
+	err = named.Exec(mb, sql.Named("id", 4), sql.Named("nombre", "Ana"))
+	assert.True(t, errors.Is(err, ErrMissingArgument))
+	err = insert.Exec(mb, 5, struct{}{})
+	assert.True(t, errors.Is(err, ErrInvalidDatatype))
+
+	// The prepared ast is left untouched by every execution
+	assert.Equal(t, parameterKind, insert.ast.Statements[0].InsertStatement.values[0].literal.kind)
+
+	_, ok := mb.tables["pwned"]
+	assert.False(t, ok)
+
+	query, err := Prepare(`SELECT name, id FROM users;`)
+	assert.Nil(t, err)
+	rows, err := query.Query(mb)
+	assert.Nil(t, err)
+	all := collect(t, rows)
+	assert.Equal(t, 4, len(all))
+	assert.Equal(t, `"); CREATE TABLE pwned (id INT); --`, all[1][0].AsText())
+	assert.Nil(t, all[2][0])
+	assert.Equal(t, "Ana", all[3][0].AsText())
+	assert.Equal(t, int32(4), all[3][1].AsInt())
+
+	execute(t, mb, `CREATE TABLE flags (id BIGINT, active BOOL);`)
+	insert, err = Prepare(`INSERT INTO flags VALUES (?, ?);`)
+	assert.Nil(t, err)
+	assert.Nil(t, insert.Exec(mb, uint(1), true))
+	assert.Nil(t, insert.Exec(mb, uint64(math.MaxInt64), false))
+	err = insert.Exec(mb, uint64(math.MaxInt64)+1, true)
+	assert.True(t, errors.Is(err, ErrIntegerOutOfRange), err)
+	assert.ErrorContains(t, err, "9223372036854775808 doesn't fit in BIGINT")
+
+	query, err = Prepare(`SELECT id FROM flags WHERE active = ?;`)
+	assert.Nil(t, err)
+	rows, err = query.Query(mb, false)
+	assert.Nil(t, err)
+	all = collect(t, rows)
+	assert.Equal(t, 1, len(all))
+	assert.Equal(t, int64(math.MaxInt64), all[0][0].AsInt64())
+}
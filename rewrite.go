@@ -0,0 +1,245 @@
+package gosql
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+Rewrites
+--------
+Before planning, a select goes through rules that don't need statistics:
+
+  - Constant folding computes the parts of expressions without columns once, so age > 10 + 5 becomes age > 15,
+    including calls like now(), which is how it gives the same time everywhere in a statement, but not calls to
+    volatile functions like gen_random_uuid(). Parts that fail, like 1 / 0, or that give NULL are left alone and
+    behave as if they weren't folded. Result columns are still named after the items as they were written.
+  - Conditions that are always true are dropped, WHERE 1 = 1 AND age > 15 becomes WHERE age > 15, and AND and OR
+    with a constant operand are simplified.
+  - Scans only produce the columns the select refers to, so joins, sorts and groups carry narrower rows.
+
+Pushing conditions below joins happens along with join ordering, see optimizer.go.
+*/
+
+// foldSelect returns a copy of the select with the constant parts of all its expressions folded
+func foldSelect(slct *SelectStatement) *SelectStatement {
+	folded := *slct
+	folded.item = foldExpressions(slct.item)
+	folded.where = foldConstants(slct.where)
+	folded.groupBy = foldExpressions(slct.groupBy)
+
+	folded.joins = nil
+	for _, join := range slct.joins {
+		j := *join
+		j.on = foldConstants(join.on)
+		folded.joins = append(folded.joins, &j)
+	}
+
+	folded.orderBy = nil
+	for _, item := range slct.orderBy {
+		folded.orderBy = append(folded.orderBy, &orderByItem{exp: foldConstants(item.exp), desc: item.desc})
+	}
+	return &folded
+}
+
+func foldExpressions(exps []*expression) []*expression {
+	if exps == nil {
+		return nil
+	}
+
+	folded := []*expression{}
+	for _, exp := range exps {
+		folded = append(folded, foldConstants(exp))
+	}
+	return folded
+}
+
+// foldConstants replaces the parts of exp that don't depend on any row with their value
+func foldConstants(exp *expression) *expression {
+	if exp == nil {
+		return nil
+	}
+
+	switch exp.kind {
+	case binaryKind:
+		a, b := foldConstants(exp.binary.a), foldConstants(exp.binary.b)
+		folded := &expression{kind: binaryKind, binary: &binaryExpression{a: a, b: b, op: exp.binary.op}}
+		if !isConstant(a) || !isConstant(b) {
+			return folded
+		}
+		return foldExpression(folded, exp.binary.op.loc)
+	case callKind:
+		call := *exp.call
+		call.args = foldExpressions(exp.call.args)
+		folded := &expression{kind: callKind, call: &call}
+		if isAggregate(&call) || call.star || volatileFunctions[call.name.value] {
+			return folded
+		}
+		for _, arg := range call.args {
+			if !isConstant(arg) {
+				return folded
+			}
+		}
+		return foldExpression(folded, call.name.loc)
+	case castKind:
+		cast := *exp.cast
+		cast.exp = foldConstants(exp.cast.exp)
+		return &expression{kind: castKind, cast: &cast}
+	case arrayKind:
+		arr := &arrayExpression{elements: foldExpressions(exp.array.elements), loc: exp.array.loc}
+		return &expression{kind: arrayKind, array: arr}
+	case subscriptKind:
+		sub := &subscriptExpression{exp: foldConstants(exp.subscript.exp), index: foldConstants(exp.subscript.index)}
+		return &expression{kind: subscriptKind, subscript: sub}
+	}
+	return exp
+}
+
+// foldExpression replaces an expression of constants with its value, unless computing it fails
+func foldExpression(exp *expression, loc location) *expression {
+	eval, typ, err := compileExpression(exp, nil)
+	if err != nil {
+		return exp
+	}
+	cell, err := eval(nil)
+	if err != nil || cell == nil {
+		return exp
+	}
+
+	// Only types with literals of their own can be folded, and a BIGINT that fits an INT would be lexed back as
+	// an INT
+	literal := cellLiteral(cell, typ, loc)
+	if _, literalType, err := compileExpression(literal, nil); err != nil || literalType != typ {
+		return exp
+	}
+	return literal
+}
+
+// isConstant is true for literals other than columns, parameters or *, and for typed literals like
+// DATE '2024-01-02'
+func isConstant(exp *expression) bool {
+	if exp.kind == castKind {
+		return isConstant(exp.cast.exp)
+	}
+	if exp.kind != literalKind {
+		return false
+	}
+	switch exp.literal.kind {
+	case numericKind, stringKind, hexKind, boolKind, nullKind:
+		return true
+	}
+	return false
+}
+
+// cellLiteral is the literal for a value
+func cellLiteral(cell MemoryCell, typ ColumnType, loc location) *expression {
+	literal := &token{loc: loc}
+	switch typ {
+	case SmallIntType, IntType, BigIntType:
+		literal.kind, literal.value = numericKind, strconv.FormatInt(cell.AsInt64(), 10)
+	case BoolType:
+		literal.kind, literal.value = boolKind, string(falseKeyword)
+		if cell.AsBool() {
+			literal.value = string(trueKeyword)
+		}
+	case DoubleType:
+		literal.kind, literal.value = numericKind, floatLiteral(cell.AsFloat())
+	case DateType, TimeType, TimestampType, IntervalType:
+		literal.kind, literal.value = stringKind, cellText(cell, typ)
+		datatype := token{kind: keywordKind, value: strings.ToLower(typ.String()), loc: loc}
+		return &expression{kind: castKind, cast: &castExpression{exp: &expression{kind: literalKind, literal: literal},
+			datatype: datatype}}
+	case ByteaType:
+		literal.kind, literal.value = hexKind, hexDigits(cell)
+	default:
+		literal.kind, literal.value = stringKind, cell.AsText()
+	}
+	return &expression{kind: literalKind, literal: literal}
+}
+
+// floatLiteral writes a float so it's lexed back as one, with a decimal point or an exponent
+func floatLiteral(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// isBoolLiteral is true when exp is the literal TRUE or FALSE given
+func isBoolLiteral(exp *expression, value bool) bool {
+	if exp.kind != literalKind || exp.literal.kind != boolKind {
+		return false
+	}
+	return (exp.literal.value == string(trueKeyword)) == value
+}
+
+// simplifyCondition removes the operands of AND and OR that don't change their result. Only conditions that
+// already compiled can be simplified, dropping an operand would also drop its errors.
+func simplifyCondition(exp *expression) *expression {
+	if exp.kind != binaryKind {
+		return exp
+	}
+
+	and, or := isKeywordOperator(exp, andKeyword), isKeywordOperator(exp, orKeyword)
+	if !and && !or {
+		return exp
+	}
+
+	a, b := simplifyCondition(exp.binary.a), simplifyCondition(exp.binary.b)
+	for _, pair := range [][2]*expression{{a, b}, {b, a}} {
+		constant, other := pair[0], pair[1]
+		switch {
+		// x AND TRUE is x, x OR FALSE is x
+		case and && isBoolLiteral(constant, true), or && isBoolLiteral(constant, false):
+			return other
+		// x AND FALSE is FALSE even when x is NULL, x OR TRUE is TRUE
+		case and && isBoolLiteral(constant, false), or && isBoolLiteral(constant, true):
+			return constant
+		}
+	}
+	return &expression{kind: binaryKind, binary: &binaryExpression{a: a, b: b, op: exp.binary.op}}
+}
+
+// usedColumns keeps the columns of a table the select may refer to, returning their positions. A column is kept
+// when any identifier could resolve to it, so resolving against the pruned columns gives the same result.
+func usedColumns(cols []resultColumn, identifiers []string) []int {
+	used := []int{}
+	for i, col := range cols {
+		for _, identifier := range identifiers {
+			if identifier == col.name || identifier == col.table+"."+col.name {
+				used = append(used, i)
+				break
+			}
+		}
+	}
+	return used
+}
+
+// selectIdentifiers lists the identifiers in every expression of the select
+func selectIdentifiers(slct *SelectStatement, items []*expression) []string {
+	identifiers := []string{}
+	collect := func(exp *expression) {
+		mapExpression(exp, func(lit *expression) (*expression, error) {
+			if lit.literal.kind == identifierKind {
+				identifiers = append(identifiers, lit.literal.value)
+			}
+			return lit, nil
+		})
+	}
+
+	for _, item := range items {
+		collect(item)
+	}
+	for _, join := range slct.joins {
+		collect(join.on)
+	}
+	collect(slct.where)
+	for _, exp := range slct.groupBy {
+		collect(exp)
+	}
+	for _, item := range slct.orderBy {
+		collect(item.exp)
+	}
+	return identifiers
+}
@@ -0,0 +1,255 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrNullValue = errors.New("Value is NULL")
+
+// Rows walks the results of a select, starting before the first row. Rows are produced as Next asks for them,
+// so they must be closed if they're not read to the end.
+type Rows struct {
+	op   operator
+	cols []resultColumn
+	row  []MemoryCell
+	err  error
+
+	// Held while producing every row when the backend is shared
+	locker rowsLocker
+}
+
+// rowsLocker guards a shared backend while rows are produced. Taking it can fail, which ends the rows with that
+// error.
+type rowsLocker interface {
+	lock() error
+	unlock()
+}
+
+// mutexLocker is a rowsLocker that waits for as long as the mutex is held
+type mutexLocker struct {
+	mu *sync.Mutex
+}
+
+func (l mutexLocker) lock() error {
+	l.mu.Lock()
+	return nil
+}
+
+func (l mutexLocker) unlock() {
+	l.mu.Unlock()
+}
+
+func newRows(op operator) *Rows {
+	return &Rows{op: op, cols: op.columns()}
+}
+
+// emptyRows are the rows of a script without selects
+func emptyRows() *Rows {
+	return &Rows{}
+}
+
+func (r *Rows) Columns() []string {
+	columns := []string{}
+	for _, col := range r.cols {
+		columns = append(columns, col.name)
+	}
+	return columns
+}
+
+func (r *Rows) ColumnTypes() []ColumnType {
+	types := []ColumnType{}
+	for _, col := range r.cols {
+		types = append(types, col.typ)
+	}
+	return types
+}
+
+// Next moves to the following row, returning false once there are no more rows or producing one failed
+func (r *Rows) Next() bool {
+	r.row = nil
+	if r.op == nil {
+		return false
+	}
+
+	if r.locker != nil {
+		if err := r.locker.lock(); err != nil {
+			r.err = err
+			return false
+		}
+		defer r.locker.unlock()
+	}
+
+	row, ok, err := r.op.next()
+	if err != nil {
+		r.err = err
+	}
+	if !ok || err != nil {
+		r.closeOperator()
+		return false
+	}
+	r.row = row
+	return true
+}
+
+// Row returns the cells of the current row
+func (r *Rows) Row() []Cell {
+	cells := []Cell{}
+	for _, cell := range r.row {
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+// Err returns the error that stopped Next, if any
+func (r *Rows) Err() error {
+	return r.err
+}
+
+func (r *Rows) Close() error {
+	r.row = nil
+	if r.op == nil {
+		return nil
+	}
+
+	if r.locker != nil {
+		if err := r.locker.lock(); err != nil {
+			r.op = nil
+			return err
+		}
+		defer r.locker.unlock()
+	}
+	return r.closeOperator()
+}
+
+func (r *Rows) closeOperator() error {
+	err := r.op.close()
+	r.op = nil
+	if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+// Scan copies the cells of the current row into dest, one pointer per column
+func (r *Rows) Scan(dest ...any) error {
+	if r.row == nil {
+		return errors.New("Scan called without a row, call Next first")
+	}
+
+	if len(dest) != len(r.row) {
+		return fmt.Errorf("%w: expected %d destinations, got %d", ErrWrongNumberOfArguments, len(r.row), len(dest))
+	}
+
+	for i, cell := range r.row {
+		col := r.cols[i]
+		value, err := cellValue(cell, col.typ)
+		if err != nil {
+			return err
+		}
+		if err := assignValue(dest[i], value); err != nil {
+			return fmt.Errorf("column %s: %w", col.name, err)
+		}
+	}
+	return nil
+}
+
+// cellValue maps a cell to the Go value for the type of its column, nil for NULL
+func cellValue(cell MemoryCell, typ ColumnType) (any, error) {
+	if cell == nil {
+		return nil, nil
+	}
+
+	switch typ {
+	case SmallIntType, IntType, BigIntType:
+		return cell.AsInt64(), nil
+	case TextType, JsonType:
+		return cell.AsText(), nil
+	case BoolType:
+		return cell.AsBool(), nil
+	case RealType, DoubleType:
+		return cell.AsFloat(), nil
+	case NumericType:
+		// As text so no digit is lost, like most drivers do
+		return decodeNumeric(cell).String(), nil
+	case DateType, TimestampType:
+		return cell.AsTime(), nil
+	case TimeType, IntervalType, UuidType:
+		return cellText(cell, typ), nil
+	case ByteaType:
+		// A copy, so the caller can keep it after the row is gone
+		return append([]byte{}, cell...), nil
+	}
+	if isArray(typ) {
+		return arrayText(cell), nil
+	}
+	return nil, ErrInvalidDatatype
+}
+
+func assignValue(dest any, value any) error {
+	if d, ok := dest.(*any); ok {
+		*d = value
+		return nil
+	}
+	if value == nil {
+		return ErrNullValue
+	}
+
+	switch v := value.(type) {
+	case int64:
+		switch d := dest.(type) {
+		case *int:
+			*d = int(v)
+			return nil
+		case *int32:
+			*d = int32(v)
+			return nil
+		case *int64:
+			*d = v
+			return nil
+		case *float64:
+			*d = float64(v)
+			return nil
+		}
+	case float64:
+		switch d := dest.(type) {
+		case *float64:
+			*d = v
+			return nil
+		case *float32:
+			*d = float32(v)
+			return nil
+		}
+	case string:
+		switch d := dest.(type) {
+		case *string:
+			*d = v
+			return nil
+		case *[]byte:
+			*d = []byte(v)
+			return nil
+		}
+	case []byte:
+		switch d := dest.(type) {
+		case *[]byte:
+			*d = v
+			return nil
+		case *string:
+			*d = string(v)
+			return nil
+		}
+	case bool:
+		if d, ok := dest.(*bool); ok {
+			*d = v
+			return nil
+		}
+	case time.Time:
+		if d, ok := dest.(*time.Time); ok {
+			*d = v
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: can't scan %T into %T", ErrInvalidDatatype, value, dest)
+}
@@ -0,0 +1,100 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingScan produces n rows, keeping track of how many were asked for. It's also the catalog of a single
+// table to plan selects on.
+type countingScan struct {
+	n      int
+	pulled int
+	closed bool
+	err    error
+}
+
+func (s *countingScan) columns() []resultColumn {
+	return []resultColumn{{name: "id", typ: IntType}}
+}
+
+func (s *countingScan) next() ([]MemoryCell, bool, error) {
+	if s.pulled >= s.n {
+		return nil, false, s.err
+	}
+	s.pulled++
+	return []MemoryCell{intCell(int32(s.pulled))}, true, nil
+}
+
+func (s *countingScan) close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *countingScan) describe() string {
+	return "Counting Scan"
+}
+
+func (s *countingScan) children() []operator {
+	return nil
+}
+
+func (s *countingScan) tableColumns(string) ([]resultColumn, error) {
+	return s.columns(), nil
+}
+
+func (s *countingScan) scanTable(context.Context, string, []int) (operator, error) {
+	return s, nil
+}
+
+func (s *countingScan) tableStats(string) *tableStats {
+	return nil
+}
+
+func (s *countingScan) tableIndexes(string) []indexInfo {
+	return nil
+}
+
+func (s *countingScan) scanIndex(context.Context, string, string, indexRange, []int) (operator, error) {
+	return nil, ErrNotSupported
+}
+
+func TestRows(t *testing.T) {
+	scan := &countingScan{n: 1000000}
+	slct, err := Parse(`SELECT id FROM numbers;`)
+	assert.Nil(t, err)
+	op, err := planSelect(context.Background(), slct.Statements[0].SelectStatement, scan)
+	assert.Nil(t, err)
+
+	// Rows are only produced as they're read
+	rows := newRows(op)
+	assert.Equal(t, []string{"id"}, rows.Columns())
+	assert.Equal(t, 0, scan.pulled)
+	for i := 1; i <= 3; i++ {
+		assert.True(t, rows.Next())
+		var id int
+		assert.Nil(t, rows.Scan(&id))
+		assert.Equal(t, i, id)
+	}
+	assert.Equal(t, 3, scan.pulled)
+
+	assert.Nil(t, rows.Close())
+	assert.True(t, scan.closed)
+	assert.False(t, rows.Next())
+	assert.Equal(t, 3, scan.pulled)
+
+	// Errors stop the iteration and are kept for Err
+	failed := errors.New("failed")
+	scan = &countingScan{n: 2, err: failed}
+	op, err = planSelect(context.Background(), slct.Statements[0].SelectStatement, scan)
+	assert.Nil(t, err)
+	rows = newRows(op)
+	assert.True(t, rows.Next())
+	assert.True(t, rows.Next())
+	assert.False(t, rows.Next())
+	assert.Equal(t, failed, rows.Err())
+	assert.True(t, scan.closed)
+}
@@ -0,0 +1,240 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+Struct Scanning
+---------------
+Instead of scanning column by column, the cells of a row can be copied into the fields of a struct. A column goes
+to the field tagged with its name, or to the field with the same name ignoring case when no field is tagged. Fields
+tagged with `gosql:"-"` are never filled, embedded structs are searched too.
+
+	type User struct {
+		ID       int     `gosql:"id"`
+		Name     string
+		Nickname *string
+	}
+
+	var users []User
+	err := rows.ScanAll(&users)
+
+Pointer fields are left nil for NULL cells, any other field fails to take a NULL.
+*/
+
+var ErrNoStructField = errors.New("No struct field for column")
+
+// ScanStruct copies the cells of the current row into the fields of the struct dest points to
+func (r *Rows) ScanStruct(dest any) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: ScanStruct expects a pointer to a struct, got %T", ErrInvalidDatatype, dest)
+	}
+	if r.row == nil {
+		return errors.New("Scan called without a row, call Next first")
+	}
+
+	fields, err := r.structFields(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	return r.scanFields(v.Elem(), fields)
+}
+
+// ScanAll reads every remaining row into the slice dest points to and closes the rows. The slice may hold structs
+// or pointers to structs.
+func (r *Rows) ScanAll(dest any) error {
+	defer r.Close()
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%w: ScanAll expects a pointer to a slice, got %T", ErrInvalidDatatype, dest)
+	}
+
+	slice := v.Elem()
+	elem := slice.Type().Elem()
+	isPointer := elem.Kind() == reflect.Pointer
+	if isPointer {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: ScanAll expects a slice of structs, got %T", ErrInvalidDatatype, dest)
+	}
+
+	fields, err := r.structFields(elem)
+	if err != nil {
+		return err
+	}
+
+	for r.Next() {
+		item := reflect.New(elem)
+		if err := r.scanFields(item.Elem(), fields); err != nil {
+			return err
+		}
+
+		if isPointer {
+			slice = reflect.Append(slice, item)
+		} else {
+			slice = reflect.Append(slice, item.Elem())
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	v.Elem().Set(slice)
+	return nil
+}
+
+// structFields finds the index path of the field every column goes to
+func (r *Rows) structFields(t reflect.Type) ([][]int, error) {
+	byTag := map[string][]int{}
+	byName := map[string][]int{}
+	collectFields(t, nil, byTag, byName)
+
+	fields := [][]int{}
+	for _, col := range r.cols {
+		index, ok := byTag[col.name]
+		if !ok {
+			index, ok = byName[strings.ToLower(col.name)]
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w %s in %s", ErrNoStructField, col.name, t)
+		}
+		fields = append(fields, index)
+	}
+	return fields, nil
+}
+
+func collectFields(t reflect.Type, parent []int, byTag, byName map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+
+		tag := field.Tag.Get("gosql")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			collectFields(field.Type, index, byTag, byName)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		// Fields closer to the top win over the ones of embedded structs
+		if tag != "" {
+			if _, ok := byTag[tag]; !ok || len(byTag[tag]) > len(index) {
+				byTag[tag] = index
+			}
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		if _, ok := byName[name]; !ok || len(byName[name]) > len(index) {
+			byName[name] = index
+		}
+	}
+}
+
+func (r *Rows) scanFields(v reflect.Value, fields [][]int) error {
+	for i, cell := range r.row {
+		col := r.cols[i]
+		value, err := cellValue(cell, col.typ)
+		if err != nil {
+			return err
+		}
+		if err := setField(v.FieldByIndex(fields[i]), value); err != nil {
+			return fmt.Errorf("column %s: %w", col.name, err)
+		}
+	}
+	return nil
+}
+
+// setField stores value into a struct field, converting it to the type of the field
+func setField(field reflect.Value, value any) error {
+	switch field.Kind() {
+	case reflect.Pointer:
+		if value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		ptr := reflect.New(field.Type().Elem())
+		if err := setField(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	case reflect.Interface:
+		if value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		if !reflect.TypeOf(value).AssignableTo(field.Type()) {
+			break
+		}
+		field.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	if value == nil {
+		return ErrNullValue
+	}
+
+	switch v := value.(type) {
+	case int64:
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if field.OverflowInt(v) {
+				return fmt.Errorf("%w: %d overflows %s", ErrInvalidDatatype, v, field.Type())
+			}
+			field.SetInt(v)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v < 0 || field.OverflowUint(uint64(v)) {
+				return fmt.Errorf("%w: %d overflows %s", ErrInvalidDatatype, v, field.Type())
+			}
+			field.SetUint(uint64(v))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			field.SetFloat(float64(v))
+			return nil
+		}
+	case float64:
+		if field.Kind() == reflect.Float32 || field.Kind() == reflect.Float64 {
+			field.SetFloat(v)
+			return nil
+		}
+	case bool:
+		if field.Kind() == reflect.Bool {
+			field.SetBool(v)
+			return nil
+		}
+	case string:
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString(v)
+			return nil
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+			field.SetBytes([]byte(v))
+			return nil
+		}
+	case []byte:
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8 {
+			// Copied, so the field doesn't share memory with the row
+			field.SetBytes(append([]byte{}, v...))
+			return nil
+		}
+	case time.Time:
+		if field.Type() == reflect.TypeOf(v) {
+			field.Set(reflect.ValueOf(v))
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: can't scan %T into %s", ErrInvalidDatatype, value, field.Type())
+}
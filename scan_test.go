@@ -0,0 +1,126 @@
+package gosql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type audit struct {
+	Name string
+}
+
+type user struct {
+	audit
+	ID       uint8   `gosql:"id"`
+	Nickname *string `gosql:"nick"`
+	Ignored  string  `gosql:"-"`
+}
+
+func TestRows_ScanAll(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE users (id INT, name TEXT, nick TEXT);
+		INSERT INTO users VALUES (1, "Carlos", "Charlie");
+		INSERT INTO users VALUES (2, "Ana", $1);`, nil)
+	assert.Nil(t, err)
+
+	rows, err := db.Query(`SELECT id, name, nick FROM users;`)
+	assert.Nil(t, err)
+	users := []user{}
+	assert.Nil(t, rows.ScanAll(&users))
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, uint8(1), users[0].ID)
+	assert.Equal(t, "Carlos", users[0].Name)
+	assert.Equal(t, "Charlie", *users[0].Nickname)
+	assert.Equal(t, "Ana", users[1].Name)
+	assert.Nil(t, users[1].Nickname)
+
+	rows, err = db.Query(`SELECT name FROM users;`)
+	assert.Nil(t, err)
+	pointers := []*user{}
+	assert.Nil(t, rows.ScanAll(&pointers))
+	assert.Equal(t, "Ana", pointers[1].Name)
+
+	rows, err = db.Query(`SELECT id, name FROM users;`)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	var one user
+	assert.Nil(t, rows.ScanStruct(&one))
+	assert.Equal(t, uint8(1), one.ID)
+	assert.Nil(t, rows.Close())
+
+	tests := []struct {
+		query string
+		dest  any
+		err   error
+		msg   string
+	}{
+		{
+			query: `SELECT nick FROM users;`,
+			dest: &[]struct {
+				Nick string
+			}{},
+			err: ErrNullValue,
+			msg: "column nick",
+		},
+		{
+			query: `SELECT name FROM users;`,
+			dest: &[]struct {
+				Name int
+			}{},
+			err: ErrInvalidDatatype,
+			msg: "column name",
+		},
+		{
+			query: `SELECT id FROM users;`,
+			dest:  &[]struct{ Name string }{},
+			err:   ErrNoStructField,
+			msg:   "id",
+		},
+		{
+			query: `SELECT id FROM users;`,
+			dest:  &[]int{},
+			err:   ErrInvalidDatatype,
+		},
+		{
+			query: `SELECT id FROM users;`,
+			dest:  []user{},
+			err:   ErrInvalidDatatype,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := db.Query(test.query)
+		assert.Nil(t, err, test.query)
+		err = rows.ScanAll(test.dest)
+		assert.True(t, errors.Is(err, test.err), test.query)
+		assert.True(t, strings.Contains(err.Error(), test.msg), err.Error())
+	}
+	_, err = db.Exec(`
+		CREATE TABLE files (id INT, data BYTEA);
+		INSERT INTO files VALUES (1, X'DEADBEEF');
+		INSERT INTO files VALUES (2, X'');`)
+	assert.Nil(t, err)
+	rows, err = db.Query(`SELECT id, data FROM files;`)
+	assert.Nil(t, err)
+	files := []struct {
+		ID   int
+		Data []byte
+	}{}
+	assert.Nil(t, rows.ScanAll(&files))
+	assert.Equal(t, []byte{0xde, 0xad, 0xbe, 0xef}, files[0].Data)
+	assert.Equal(t, []byte{}, files[1].Data)
+
+	rows, err = db.Query(`SELECT data FROM files WHERE id = 1;`)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	var file struct{ Data *[]byte }
+	assert.Nil(t, rows.ScanStruct(&file))
+	assert.Equal(t, []byte{0xde, 0xad, 0xbe, 0xef}, *file.Data)
+	assert.Nil(t, rows.Close())
+}
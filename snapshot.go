@@ -0,0 +1,226 @@
+package gosql
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*
+Snapshots
+---------
+A snapshot is every table of a memory backend written one after the other, so a prepared dataset can be loaded
+back without replaying the statements that built it.
+
+	$magic $version uint16 $tables uint32
+	[$name $columns uint16 [$column-name $column-type byte [$modifier] [$default]]... $rows uint64
+	 [$length uint32 $row]... $indexes uint16 [$index-name $column-name]...]...
+
+Rows and columns use the same encoding as the disk backend: like in its catalog NUMERIC columns are followed by
+their precision and scale, VARCHAR and CHAR columns by their length, and columns with a default by its expression.
+Only the definition of indexes is kept, they're built again from the rows when loading. Version 1 snapshots had no
+indexes.
+*/
+
+const (
+	snapshotMagic   = "gosqlmb\x00"
+	snapshotVersion = 2
+)
+
+var ErrInvalidSnapshot = errors.New("Invalid snapshot")
+
+// SaveTo writes a snapshot of every table to w
+func (mb *MemoryBackend) SaveTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := io.WriteString(bw, snapshotMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, uint16(snapshotVersion)); err != nil {
+		return err
+	}
+
+	// Sorted so the same tables always produce the same snapshot
+	names := []string{}
+	for name := range mb.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := binary.Write(bw, binary.BigEndian, uint32(len(names))); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := saveTable(bw, name, mb.tables[name]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func saveTable(w io.Writer, name string, t *table) error {
+	if err := writeString(w, name); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint16(len(t.columns))); err != nil {
+		return err
+	}
+	for i, col := range t.columns {
+		if err := writeColumn(w, col, t.columnTypes[i], t.columnModifiers[i], t.columnDefaults[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint64(len(t.rows))); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		data := encodeRow(row)
+		if err := binary.Write(w, binary.BigEndian, uint32(len(data))); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint16(len(t.indexes))); err != nil {
+		return err
+	}
+	for _, idx := range t.indexes {
+		if err := writeString(w, idx.name); err != nil {
+			return err
+		}
+		if err := writeString(w, t.columns[idx.column]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadMemoryBackend reads a snapshot written by SaveTo into a new memory backend
+func LoadMemoryBackend(r io.Reader) (*MemoryBackend, error) {
+	mb, err := loadSnapshot(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSnapshot, err)
+	}
+	return mb, nil
+}
+
+func loadSnapshot(r io.Reader) (*MemoryBackend, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != snapshotMagic {
+		return nil, errors.New("bad magic")
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if version < 1 || version > snapshotVersion {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+
+	mb := NewMemoryBackend()
+	for i := 0; i < int(count); i++ {
+		name, t, err := loadTable(r, version)
+		if err != nil {
+			return nil, err
+		}
+		mb.tables[name] = t
+	}
+	return mb, nil
+}
+
+func loadTable(r io.Reader, version uint16) (string, *table, error) {
+	name, err := readString(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var columns uint16
+	if err := binary.Read(r, binary.BigEndian, &columns); err != nil {
+		return "", nil, err
+	}
+
+	t := &table{}
+	for i := 0; i < int(columns); i++ {
+		col, dt, mod, def, err := readColumn(r)
+		if err != nil {
+			return "", nil, err
+		}
+		t.columns = append(t.columns, col)
+		t.columnTypes = append(t.columnTypes, dt)
+		t.columnModifiers = append(t.columnModifiers, mod)
+		t.columnDefaults = append(t.columnDefaults, def)
+	}
+
+	var rows uint64
+	if err := binary.Read(r, binary.BigEndian, &rows); err != nil {
+		return "", nil, err
+	}
+	for i := uint64(0); i < rows; i++ {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return "", nil, err
+		}
+
+		// Don't trust the length to allocate, a corrupt one would ask for gigabytes
+		data, err := io.ReadAll(io.LimitReader(r, int64(length)))
+		if err != nil {
+			return "", nil, err
+		}
+		if len(data) != int(length) {
+			return "", nil, io.ErrUnexpectedEOF
+		}
+		row, err := decodeRow(data)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(row) != len(t.columns) {
+			return "", nil, fmt.Errorf("row %d of %s has %d cells, expected %d", i, name, len(row), len(t.columns))
+		}
+		t.rows = append(t.rows, row)
+	}
+
+	if version < 2 {
+		return name, t, nil
+	}
+	var indexes uint16
+	if err := binary.Read(r, binary.BigEndian, &indexes); err != nil {
+		return "", nil, err
+	}
+	for i := 0; i < int(indexes); i++ {
+		indexName, err := readString(r)
+		if err != nil {
+			return "", nil, err
+		}
+		col, err := readString(r)
+		if err != nil {
+			return "", nil, err
+		}
+
+		column := -1
+		for j, name := range t.columns {
+			if name == col {
+				column = j
+			}
+		}
+		if column < 0 {
+			return "", nil, fmt.Errorf("index %s of %s is on unknown column %s", indexName, name, col)
+		}
+		t.indexes = append(t.indexes, newIndex(t, indexName, column))
+	}
+	return name, t, nil
+}
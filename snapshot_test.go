@@ -0,0 +1,79 @@
+package gosql
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBackend_SaveTo(t *testing.T) {
+	mb := NewMemoryBackend()
+	execute(t, mb, `CREATE TABLE users (id INT, name TEXT);`)
+	execute(t, mb, `INSERT INTO users VALUES (1, "Carlos");`)
+	execute(t, mb, `INSERT INTO users VALUES (2, "");`)
+	execute(t, mb, `CREATE TABLE empty (id INT);`)
+	execute(t, mb, `CREATE TABLE accounts (balance NUMERIC(8, 2), currency VARCHAR(3) DEFAULT 'usd', limits INT[],
+		note TEXT DEFAULT 'say "hi"' || " it's " || CAST(ARRAY[1][1] AS VARCHAR(2)));`)
+	execute(t, mb, `INSERT INTO accounts VALUES (-12.5, 'eur', ARRAY[100, NULL], NULL);`)
+	mb.tables["users"].rows = append(mb.tables["users"].rows, []MemoryCell{intCell(3), nil})
+	execute(t, mb, `CREATE INDEX users_id ON users (id);`)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, mb.SaveTo(buf))
+	snapshot := buf.Bytes()
+
+	loaded, err := LoadMemoryBackend(bytes.NewReader(snapshot))
+	assert.Nil(t, err)
+	assert.Equal(t, mb.tables, loaded.tables)
+
+	// Saving the same tables gives the same bytes
+	again := new(bytes.Buffer)
+	assert.Nil(t, loaded.SaveTo(again))
+	assert.Equal(t, snapshot, again.Bytes())
+
+	all := collect(t, execute(t, loaded, `SELECT name, id FROM users;`))
+	assert.Equal(t, 3, len(all))
+	assert.Equal(t, "Carlos", all[0][0].AsText())
+	assert.Equal(t, int32(2), all[1][1].AsInt())
+	assert.Equal(t, 3, len(loaded.tables["users"].indexes[0].entries))
+
+	// Defaults are kept as they were written, quotes and all
+	execute(t, loaded, `INSERT INTO accounts (balance) VALUES (1);`)
+	all = collect(t, execute(t, loaded, `SELECT currency, note FROM accounts WHERE balance = 1;`))
+	assert.Equal(t, "usd", all[0][0].AsText())
+	assert.Equal(t, `say "hi" it's 1`, all[0][1].AsText())
+
+	// Version 1 snapshots are the same without the index count closing every table
+	v1 := new(bytes.Buffer)
+	assert.Nil(t, (&MemoryBackend{tables: map[string]*table{"empty": mb.tables["empty"]}}).SaveTo(v1))
+	old := v1.Bytes()[:v1.Len()-2]
+	old[len(snapshotMagic)+1] = 1
+	loaded, err = LoadMemoryBackend(bytes.NewReader(old))
+	assert.Nil(t, err)
+	assert.Equal(t, mb.tables["empty"], loaded.tables["empty"])
+
+	tests := []struct {
+		name     string
+		snapshot []byte
+	}{
+		{
+			name:     "empty",
+			snapshot: nil,
+		},
+		{
+			name:     "bad magic",
+			snapshot: append([]byte("gosqldb\x00"), snapshot[len(snapshotMagic):]...),
+		},
+		{
+			name:     "truncated",
+			snapshot: snapshot[:len(snapshot)-3],
+		},
+	}
+
+	for _, test := range tests {
+		_, err := LoadMemoryBackend(bytes.NewReader(test.snapshot))
+		assert.True(t, errors.Is(err, ErrInvalidSnapshot), test.name)
+	}
+}
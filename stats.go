@@ -0,0 +1,152 @@
+package gosql
+
+import (
+	"context"
+	"sort"
+)
+
+/*
+Statistics
+----------
+ANALYZE reads every row of a table to count them and, for every column, count its distinct values and NULLs and
+build a histogram of its values. The histogram splits the sorted values into buckets holding the same number of
+rows each, keeping the value every bucket starts at plus the largest value:
+
+	bounds: 1 12 30 31 55 ... 990
+
+With them the optimizer can estimate how many rows a condition lets through, say 1 in distinct for id = 3 or the
+share of buckets below 30 for id < 30. Tables that were never analyzed are assumed to have defaultTableRows rows
+and conditions on them get fixed selectivities.
+*/
+
+const (
+	defaultTableRows = 1000
+	histogramBuckets = 10
+
+	defaultEqualSelectivity = 0.1
+	defaultRangeSelectivity = 1.0 / 3
+	defaultSelectivity      = 0.5
+)
+
+type tableStats struct {
+	rows    int
+	columns []columnStats
+}
+
+type columnStats struct {
+	typ      ColumnType
+	distinct int
+	nulls    int
+	// Up to histogramBuckets+1 sorted values, empty when every value is NULL
+	bounds []MemoryCell
+}
+
+// analyzeTable collects the statistics of a table by scanning it
+func analyzeTable(ctx context.Context, c catalog, name string) (*tableStats, error) {
+	scan, err := c.scanTable(ctx, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer scan.close()
+
+	cols := scan.columns()
+	values := make([][]MemoryCell, len(cols))
+	stats := &tableStats{columns: make([]columnStats, len(cols))}
+	for {
+		row, ok, err := scan.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		stats.rows++
+		for i, cell := range row {
+			if cell == nil {
+				stats.columns[i].nulls++
+				continue
+			}
+			values[i] = append(values[i], cell)
+		}
+	}
+
+	for i, col := range cols {
+		stats.columns[i].typ = col.typ
+		stats.columns[i].distinct, stats.columns[i].bounds = histogram(values[i], col.typ)
+	}
+	return stats, nil
+}
+
+// histogram sorts the non NULL values of a column, returning how many distinct ones there are and the bounds of
+// their buckets
+func histogram(values []MemoryCell, typ ColumnType) (int, []MemoryCell) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return compareCells(values[i], values[j], typ) < 0
+	})
+
+	distinct := 1
+	for i := 1; i < len(values); i++ {
+		if compareCells(values[i-1], values[i], typ) != 0 {
+			distinct++
+		}
+	}
+
+	buckets := histogramBuckets
+	if len(values)-1 < buckets {
+		buckets = len(values) - 1
+	}
+	bounds := []MemoryCell{values[0]}
+	for b := 1; b <= buckets; b++ {
+		bounds = append(bounds, values[b*(len(values)-1)/buckets])
+	}
+	return distinct, bounds
+}
+
+// nonNull is the share of rows whose value isn't NULL
+func (cs *columnStats) nonNull(rows int) float64 {
+	if rows == 0 {
+		return 0
+	}
+	return float64(rows-cs.nulls) / float64(rows)
+}
+
+// equal estimates the share of rows equal to a value, assuming every distinct value is as common
+func (cs *columnStats) equal(rows int) float64 {
+	if cs.distinct == 0 {
+		return 0
+	}
+	return cs.nonNull(rows) / float64(cs.distinct)
+}
+
+// less estimates the share of rows below a value, interpolating within its bucket for numbers
+func (cs *columnStats) less(value MemoryCell, rows int) float64 {
+	if len(cs.bounds) == 0 {
+		return 0
+	}
+
+	last := len(cs.bounds) - 1
+	if compareCells(value, cs.bounds[0], cs.typ) <= 0 {
+		return 0
+	}
+	if compareCells(value, cs.bounds[last], cs.typ) > 0 {
+		return cs.nonNull(rows)
+	}
+
+	// The bucket the value falls in, bounds[b] < value <= bounds[b+1]
+	b := sort.Search(last, func(i int) bool {
+		return compareCells(cs.bounds[i+1], value, cs.typ) >= 0
+	})
+	within := 0.5
+	if isNumeric(cs.typ) {
+		lower, upper := numericValue(cs.bounds[b], cs.typ), numericValue(cs.bounds[b+1], cs.typ)
+		if upper > lower {
+			within = (numericValue(value, cs.typ) - lower) / (upper - lower)
+		}
+	}
+	return (float64(b) + within) / float64(last) * cs.nonNull(rows)
+}
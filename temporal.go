@@ -0,0 +1,635 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Dates and times
+---------------
+DATE, TIME and TIMESTAMP values count from the Unix epoch and have no time zone:
+
+	DATE       int32, days since 1970-01-01
+	TIME       int64, microseconds since midnight
+	TIMESTAMP  int64, microseconds since 1970-01-01 00:00:00
+
+An INTERVAL keeps its months, days and microseconds apart, since months don't all have the same number of days:
+
+	$months int32 $days int32 $microseconds int64
+
+Adding one moves the calendar by months first, keeping the day unless the month is shorter, then by days and then
+by the rest, so 2024-01-31 plus 1 month is 2024-02-29. Intervals are compared as if every month had 30 days.
+
+They're written as typed literals, DATE '2024-01-02', TIME '12:30:00', TIMESTAMP '2024-01-02 12:30:00' or
+INTERVAL '1 year 2 days 03:00:00', and a string compared with one of them or inserted into one of their columns is
+read as that type too. The operations on them are:
+
+	date + integer, date - integer    DATE
+	date - date                       INT, the days between them
+	date + interval                   TIMESTAMP
+	timestamp + interval, - interval  TIMESTAMP
+	timestamp - timestamp             INTERVAL of days and microseconds
+	time + interval, - interval       TIME, wrapping around midnight
+	interval + interval, - interval   INTERVAL
+	interval * integer, / integer     INTERVAL
+
+Like integer arithmetic, literals and results that don't fit their type fail with ErrIntegerOutOfRange instead of
+wrapping around.
+
+now() is the time the statement is planned at, in UTC, and is the same everywhere in it. date_trunc(field, t)
+cuts a TIMESTAMP or DATE down to the start of its second, minute, hour, day, week, month, quarter or year, and
+extract(field FROM t) gives a field of any of them as a DOUBLE PRECISION, like date_part.
+*/
+
+const (
+	microsPerSecond = int64(1000000)
+	microsPerMinute = 60 * microsPerSecond
+	microsPerHour   = 60 * microsPerMinute
+	microsPerDay    = 24 * microsPerHour
+	// Intervals are compared, and their epoch extracted, as if every month had this many days
+	daysPerMonth = 30
+)
+
+type interval struct {
+	months int32
+	days   int32
+	micros int64
+}
+
+func isTemporal(typ ColumnType) bool {
+	return typ == DateType || typ == TimeType || typ == TimestampType || typ == IntervalType
+}
+
+func dateCell(days int64) (MemoryCell, error) {
+	if days < math.MinInt32 || days > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: date is out of range", ErrIntegerOutOfRange)
+	}
+	return binary.BigEndian.AppendUint32(nil, uint32(days)), nil
+}
+
+func microsCell(micros int64) MemoryCell {
+	return binary.BigEndian.AppendUint64(nil, uint64(micros))
+}
+
+func intervalCell(iv interval) MemoryCell {
+	cell := binary.BigEndian.AppendUint32(nil, uint32(iv.months))
+	cell = binary.BigEndian.AppendUint32(cell, uint32(iv.days))
+	return binary.BigEndian.AppendUint64(cell, uint64(iv.micros))
+}
+
+func decodeInterval(cell MemoryCell) interval {
+	return interval{
+		months: int32(binary.BigEndian.Uint32(cell)),
+		days:   int32(binary.BigEndian.Uint32(cell[4:])),
+		micros: int64(binary.BigEndian.Uint64(cell[8:])),
+	}
+}
+
+// AsTime reads the cell of a DATE or TIMESTAMP column in UTC, TIME cells are read as a time of 1970-01-01
+func (mc MemoryCell) AsTime() time.Time {
+	if len(mc) == 4 {
+		return time.Unix(int64(int32(binary.BigEndian.Uint32(mc)))*86400, 0).UTC()
+	}
+	return time.UnixMicro(int64(binary.BigEndian.Uint64(mc))).UTC()
+}
+
+// cellMicros reads a TIME or TIMESTAMP cell
+func cellMicros(cell MemoryCell) int64 {
+	return int64(binary.BigEndian.Uint64(cell))
+}
+
+// cellDays reads a DATE cell
+func cellDays(cell MemoryCell) int64 {
+	return int64(int32(binary.BigEndian.Uint32(cell)))
+}
+
+// floorDiv divides rounding toward negative infinity, so times before the epoch fall on the right day
+func floorDiv(a, b int64) (int64, int64) {
+	q, r := a/b, a%b
+	if r < 0 {
+		q, r = q-1, r+b
+	}
+	return q, r
+}
+
+func civilDate(days int64) (int, time.Month, int) {
+	return time.Unix(days*86400, 0).UTC().Date()
+}
+
+func daysFromCivil(year int, month time.Month, day int) int64 {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+// addMonths moves a date by calendar months, going back to the last day of shorter months
+func addMonths(days int64, months int64) int64 {
+	if months == 0 {
+		return days
+	}
+	year, month, day := civilDate(days)
+	years, m := floorDiv(int64(month-1)+months, 12)
+	target := time.Month(m + 1)
+	last := time.Date(year+int(years), target+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return daysFromCivil(year+int(years), target, min(day, last))
+}
+
+// addInterval moves a timestamp by an interval, failing when the result doesn't fit
+func addInterval(timestamp int64, iv interval) (int64, error) {
+	outOfRange := fmt.Errorf("%w: timestamp is out of range", ErrIntegerOutOfRange)
+	days, clock := floorDiv(timestamp, microsPerDay)
+	days = addMonths(days, int64(iv.months)) + int64(iv.days)
+	micros, ok := checkedMul(days, microsPerDay)
+	if !ok {
+		return 0, outOfRange
+	}
+	if micros, ok = checkedAdd(micros, clock); !ok {
+		return 0, outOfRange
+	}
+	if micros, ok = checkedAdd(micros, iv.micros); !ok {
+		return 0, outOfRange
+	}
+	return micros, nil
+}
+
+func negateInterval(iv interval) (interval, error) {
+	if iv.months == math.MinInt32 || iv.days == math.MinInt32 || iv.micros == math.MinInt64 {
+		return interval{}, fmt.Errorf("%w: interval is out of range", ErrIntegerOutOfRange)
+	}
+	return interval{months: -iv.months, days: -iv.days, micros: -iv.micros}, nil
+}
+
+// checkedAdd and checkedMul compute on int64s, ok is false when the result doesn't fit
+func checkedAdd(a, b int64) (int64, bool) {
+	sum := a + b
+	return sum, (sum > a) == (b > 0)
+}
+
+func checkedMul(a, b int64) (int64, bool) {
+	product := a * b
+	return product, a == 0 || (product/a == b && !(a == -1 && b == math.MinInt64))
+}
+
+// floatToInteger converts a whole float, ok is false when it doesn't fit an int64
+func floatToInteger(f float64) (int64, bool) {
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+var timestampLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseTemporal reads a string written in one of the date and time types
+func parseTemporal(s string, typ ColumnType) (MemoryCell, error) {
+	invalid := fmt.Errorf("%w: %q is not a %s", ErrInvalidDatatype, s, typ)
+	s = strings.TrimSpace(s)
+
+	switch typ {
+	case DateType:
+		// The time of a timestamp is dropped, so parameters passed as times can be dates
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				days, _ := floorDiv(t.Unix(), 86400)
+				return dateCell(days)
+			}
+		}
+	case TimeType:
+		for _, layout := range []string{"15:04:05", "15:04"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return microsCell(int64(t.Hour())*microsPerHour + int64(t.Minute())*microsPerMinute +
+					int64(t.Second())*microsPerSecond + int64(t.Nanosecond()/1000)), nil
+			}
+		}
+	case TimestampType:
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return microsCell(t.UnixMicro()), nil
+			}
+		}
+	case IntervalType:
+		iv, err := parseInterval(s)
+		if errors.Is(err, ErrIntegerOutOfRange) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, invalid
+		}
+		return intervalCell(iv), nil
+	}
+	return nil, invalid
+}
+
+var intervalUnits = map[string]interval{
+	"microsecond": {micros: 1},
+	"millisecond": {micros: 1000},
+	"second":      {micros: microsPerSecond},
+	"sec":         {micros: microsPerSecond},
+	"minute":      {micros: microsPerMinute},
+	"min":         {micros: microsPerMinute},
+	"hour":        {micros: microsPerHour},
+	"day":         {days: 1},
+	"week":        {days: 7},
+	"month":       {months: 1},
+	"mon":         {months: 1},
+	"year":        {months: 12},
+}
+
+// parseInterval reads quantities followed by their unit, like 1 year 2 mons or -1.5 hours, and a time of day
+// like 03:00:00 for hours, minutes and seconds. Quantities that don't fit fail with ErrIntegerOutOfRange.
+func parseInterval(s string) (interval, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return interval{}, fmt.Errorf("empty interval")
+	}
+
+	// Parts are added up as int64s, which are checked on every step and against the size of an interval at the end
+	var months, days, micros int64
+	ok := true
+	add := func(sum *int64, part float64) {
+		n, fits := floatToInteger(part)
+		if !fits {
+			ok = false
+			return
+		}
+		*sum, fits = checkedAdd(*sum, n)
+		ok = ok && fits
+	}
+
+	for i := 0; i < len(fields) && ok; i++ {
+		if strings.Contains(fields[i], ":") {
+			clock, err := parseClock(fields[i])
+			if err != nil {
+				return interval{}, err
+			}
+			micros, ok = checkedAdd(micros, clock)
+			continue
+		}
+
+		n, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil || i+1 == len(fields) {
+			return interval{}, fmt.Errorf("expected a quantity and a unit")
+		}
+		i++
+		unit, found := intervalUnits[strings.TrimSuffix(strings.ToLower(fields[i]), "s")]
+		if !found {
+			return interval{}, fmt.Errorf("unknown unit %s", fields[i])
+		}
+
+		switch {
+		case unit.months != 0:
+			m := n * float64(unit.months)
+			if m != math.Trunc(m) {
+				return interval{}, fmt.Errorf("months must be whole")
+			}
+			add(&months, m)
+		case unit.days != 0:
+			// What doesn't make a whole day goes to the microseconds, like 1.5 days is 1 day 12:00:00
+			d := n * float64(unit.days)
+			add(&days, math.Trunc(d))
+			add(&micros, math.Round((d-math.Trunc(d))*float64(microsPerDay)))
+		default:
+			add(&micros, math.Round(n*float64(unit.micros)))
+		}
+	}
+
+	if !ok || months < math.MinInt32 || months > math.MaxInt32 || days < math.MinInt32 || days > math.MaxInt32 {
+		return interval{}, fmt.Errorf("%w: interval %q is out of range", ErrIntegerOutOfRange, s)
+	}
+	return interval{months: int32(months), days: int32(days), micros: micros}, nil
+}
+
+// parseClock reads [-]hh:mm[:ss[.ffffff]] into microseconds
+func parseClock(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimPrefix(s, "-"), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+
+	micros := int64(0)
+	for i, part := range parts {
+		unit := []int64{microsPerHour, microsPerMinute, microsPerSecond}[i]
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 || (i < 2 && n != math.Trunc(n)) {
+			return 0, fmt.Errorf("invalid time %s", s)
+		}
+		micros += int64(math.Round(n * float64(unit)))
+	}
+	if negative {
+		return -micros, nil
+	}
+	return micros, nil
+}
+
+func formatClock(micros int64) string {
+	sign := ""
+	if micros < 0 {
+		sign, micros = "-", -micros
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, micros/microsPerHour, micros/microsPerMinute%60,
+		micros/microsPerSecond%60)
+	if fraction := micros % microsPerSecond; fraction != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", fraction), "0")
+	}
+	return s
+}
+
+// formatInterval writes an interval the way Postgres does, like 1 year 2 mons 3 days 04:05:06
+func formatInterval(iv interval) string {
+	parts := []string{}
+	plural := func(n int32, unit string) {
+		if n == 1 || n == -1 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, unit))
+		} else if n != 0 {
+			parts = append(parts, fmt.Sprintf("%d %ss", n, unit))
+		}
+	}
+	plural(iv.months/12, "year")
+	plural(iv.months%12, "mon")
+	plural(iv.days, "day")
+	if iv.micros != 0 || len(parts) == 0 {
+		parts = append(parts, formatClock(iv.micros))
+	}
+	return strings.Join(parts, " ")
+}
+
+func temporalText(cell MemoryCell, typ ColumnType) string {
+	switch typ {
+	case DateType:
+		return cell.AsTime().Format("2006-01-02")
+	case TimeType:
+		return formatClock(cellMicros(cell))
+	case TimestampType:
+		return cell.AsTime().Format("2006-01-02 15:04:05.999999")
+	}
+	return formatInterval(decodeInterval(cell))
+}
+
+// intervalLength is the length of an interval in microseconds, taking months as daysPerMonth days
+func intervalLength(iv interval) *big.Int {
+	days := big.NewInt(int64(iv.months)*daysPerMonth + int64(iv.days))
+	length := days.Mul(days, big.NewInt(microsPerDay))
+	return length.Add(length, big.NewInt(iv.micros))
+}
+
+func compareTemporal(a, b MemoryCell, typ ColumnType) int {
+	switch typ {
+	case DateType:
+		return compareInt64(cellDays(a), cellDays(b))
+	case TimeType, TimestampType:
+		return compareInt64(cellMicros(a), cellMicros(b))
+	}
+	return intervalLength(decodeInterval(a)).Cmp(intervalLength(decodeInterval(b)))
+}
+
+func compareInt64(x, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// compileTemporalArithmetic compiles the operations on dates and times listed above
+func compileTemporalArithmetic(a evaluator, at ColumnType, b evaluator, bt ColumnType, op string) (evaluator,
+	ColumnType, error) {
+	invalid := fmt.Errorf("%w: %s %s %s", ErrInvalidOperands, at, op, bt)
+	plus, minus := op == string(plusSymbol), op == string(minusSymbol)
+	sign := int64(1)
+	if minus {
+		sign = -1
+	}
+
+	// Only multiplication takes the interval on either side
+	if op == string(asteriskSymbol) && isInteger(at) && bt == IntervalType {
+		a, at, b, bt = b, bt, a, at
+	}
+
+	switch {
+	case at == DateType && isInteger(bt) && (plus || minus):
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			return dateCell(cellDays(x) + sign*y.AsInt64())
+		}), DateType, nil
+	case at == DateType && bt == DateType && minus:
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			return integerCell(cellDays(x)-cellDays(y), IntType)
+		}), IntType, nil
+	case (at == DateType || at == TimestampType) && bt == IntervalType && (plus || minus):
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			start := cellDays(x) * microsPerDay
+			if at == TimestampType {
+				start = cellMicros(x)
+			}
+			iv := decodeInterval(y)
+			if minus {
+				var err error
+				if iv, err = negateInterval(iv); err != nil {
+					return nil, err
+				}
+			}
+			micros, err := addInterval(start, iv)
+			if err != nil {
+				return nil, err
+			}
+			return microsCell(micros), nil
+		}), TimestampType, nil
+	case at == TimestampType && bt == TimestampType && minus:
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			days, rest := floorDiv(cellMicros(x)-cellMicros(y), microsPerDay)
+			// Negative differences keep both parts negative, like -1 days -02:00:00
+			if days < 0 && rest > 0 {
+				days, rest = days+1, rest-microsPerDay
+			}
+			if days < math.MinInt32 || days > math.MaxInt32 {
+				return nil, fmt.Errorf("%w: interval is out of range", ErrIntegerOutOfRange)
+			}
+			return intervalCell(interval{days: int32(days), micros: rest}), nil
+		}), IntervalType, nil
+	case at == TimeType && bt == IntervalType && (plus || minus):
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			// Only the part of the interval within a day matters, taking it first keeps the sum from overflowing
+			_, within := floorDiv(decodeInterval(y).micros, microsPerDay)
+			_, clock := floorDiv(cellMicros(x)+sign*within, microsPerDay)
+			return microsCell(clock), nil
+		}), TimeType, nil
+	case at == IntervalType && bt == IntervalType && (plus || minus):
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			i, j := decodeInterval(x), decodeInterval(y)
+			if minus {
+				var err error
+				if j, err = negateInterval(j); err != nil {
+					return nil, err
+				}
+			}
+			micros, ok := checkedAdd(i.micros, j.micros)
+			if !ok {
+				return nil, fmt.Errorf("%w: interval is out of range", ErrIntegerOutOfRange)
+			}
+			return intervalFromParts(int64(i.months)+int64(j.months), int64(i.days)+int64(j.days), micros)
+		}), IntervalType, nil
+	case at == IntervalType && isInteger(bt) && op == string(asteriskSymbol):
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			iv, n := decodeInterval(x), y.AsInt64()
+			months, monthsOk := checkedMul(int64(iv.months), n)
+			days, daysOk := checkedMul(int64(iv.days), n)
+			rest, restOk := checkedMul(iv.micros, n)
+			if !monthsOk || !daysOk || !restOk {
+				return nil, fmt.Errorf("%w: interval is out of range", ErrIntegerOutOfRange)
+			}
+			return intervalFromParts(months, days, rest)
+		}), IntervalType, nil
+	case at == IntervalType && isInteger(bt) && op == string(slashSymbol):
+		return binaryEvaluator(a, b, func(x, y MemoryCell) (MemoryCell, error) {
+			iv, n := decodeInterval(x), y.AsInt64()
+			if n == 0 {
+				return nil, ErrDivisionByZero
+			}
+			// What doesn't divide evenly goes down to the next part, like 1 mon / 2 is 15 days
+			days := int64(iv.days) + int64(iv.months)%n*daysPerMonth
+			rest := iv.micros + days%n*microsPerDay
+			return intervalFromParts(int64(iv.months)/n, days/n, rest/n)
+		}), IntervalType, nil
+	}
+	return nil, 0, invalid
+}
+
+// intervalFromParts builds an interval from the results of an operation, failing when months or days don't fit
+func intervalFromParts(months, days, micros int64) (MemoryCell, error) {
+	for _, part := range []int64{months, days} {
+		if part < math.MinInt32 || part > math.MaxInt32 {
+			return nil, fmt.Errorf("%w: interval is out of range", ErrIntegerOutOfRange)
+		}
+	}
+	return intervalCell(interval{months: int32(months), days: int32(days), micros: micros}), nil
+}
+
+func compileNow(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return constantEvaluator(microsCell(time.Now().UnixMicro())), TimestampType, nil
+}
+
+// truncations are the units date_trunc cuts down to, in microseconds, the others depend on the calendar
+var truncations = map[string]int64{
+	"microseconds": 1,
+	"milliseconds": 1000,
+	"second":       microsPerSecond,
+	"minute":       microsPerMinute,
+	"hour":         microsPerHour,
+	"day":          microsPerDay,
+}
+
+// compileDateTrunc cuts a timestamp down to the start of a unit, dates being converted to timestamps first
+func compileDateTrunc(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return binaryEvaluator(args[0], args[1], func(field, cell MemoryCell) (MemoryCell, error) {
+		timestamp := cellMicros(cell)
+		name := strings.ToLower(field.AsText())
+		if unit, ok := truncations[name]; ok {
+			start, _ := floorDiv(timestamp, unit)
+			return microsCell(start * unit), nil
+		}
+
+		day, _ := floorDiv(timestamp, microsPerDay)
+		year, month, _ := civilDate(day)
+		switch name {
+		case "week":
+			// Weeks start on Monday
+			weekday := (int64(time.Unix(day*86400, 0).UTC().Weekday()) + 6) % 7
+			day -= weekday
+		case "month":
+			day = daysFromCivil(year, month, 1)
+		case "quarter":
+			day = daysFromCivil(year, month-(month-1)%3, 1)
+		case "year":
+			day = daysFromCivil(year, time.January, 1)
+		default:
+			return nil, fmt.Errorf("%w: date_trunc can't truncate to %s", ErrInvalidOperands, name)
+		}
+		return microsCell(day * microsPerDay), nil
+	}), TimestampType, nil
+}
+
+func compileExtract(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return binaryEvaluator(args[0], args[1], func(field, cell MemoryCell) (MemoryCell, error) {
+		name := strings.ToLower(field.AsText())
+		value, ok := extractField(name, cell, types[1])
+		if !ok {
+			return nil, fmt.Errorf("%w: can't extract %s from a %s", ErrInvalidOperands, name, types[1])
+		}
+		return floatCell(value, DoubleType)
+	}), DoubleType, nil
+}
+
+func extractField(name string, cell MemoryCell, typ ColumnType) (float64, bool) {
+	var months, days, clock int64
+	switch typ {
+	case IntervalType:
+		iv := decodeInterval(cell)
+		months, days, clock = int64(iv.months), int64(iv.days), iv.micros
+	case DateType:
+		days = cellDays(cell)
+	default:
+		days, clock = floorDiv(cellMicros(cell), microsPerDay)
+	}
+
+	switch name {
+	case "hour":
+		return float64(clock / microsPerHour), true
+	case "minute":
+		return float64(clock / microsPerMinute % 60), true
+	case "second":
+		return float64(clock%microsPerMinute) / float64(microsPerSecond), true
+	case "milliseconds":
+		return float64(clock%microsPerMinute) / 1000, true
+	case "microseconds":
+		return float64(clock % microsPerMinute), true
+	case "epoch":
+		if typ == IntervalType {
+			// Years are taken as 365.25 days and the remaining months as daysPerMonth days
+			total := float64(months/12)*365.25 + float64(months%12*daysPerMonth+days)
+			return total*86400 + float64(clock)/float64(microsPerSecond), true
+		}
+		return float64(days*86400) + float64(clock)/float64(microsPerSecond), true
+	}
+
+	if typ == IntervalType {
+		switch name {
+		case "year":
+			return float64(months / 12), true
+		case "month":
+			return float64(months % 12), true
+		case "day":
+			return float64(days), true
+		}
+		return 0, false
+	}
+	if typ == TimeType {
+		return 0, false
+	}
+
+	t := time.Unix(days*86400, 0).UTC()
+	switch name {
+	case "year":
+		return float64(t.Year()), true
+	case "quarter":
+		return float64((t.Month()-1)/3 + 1), true
+	case "month":
+		return float64(t.Month()), true
+	case "week":
+		_, week := t.ISOWeek()
+		return float64(week), true
+	case "day":
+		return float64(t.Day()), true
+	case "dow":
+		return float64(t.Weekday()), true
+	case "doy":
+		return float64(t.YearDay()), true
+	}
+	return 0, false
+}
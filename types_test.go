@@ -0,0 +1,974 @@
+package gosql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloatTypes(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE prices (id INT, price DOUBLE PRECISION, weight REAL, ratio FLOAT);
+		INSERT INTO prices VALUES (1, 1.5, 2, 1e-3);
+		INSERT INTO prices VALUES (2, -0.25, 0.5, 1.5E2);
+		INSERT INTO prices VALUES (3, 10, NULL, $1);`, 0.125)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+		err   error
+	}{
+		{
+			query: `SELECT price, weight, ratio FROM prices WHERE id = 1;`,
+			rows:  [][]any{{1.5, 2.0, 0.001}},
+		},
+		{
+			query: `SELECT price * 2, weight + 1, id / 2.0, id + price FROM prices WHERE id = 2;`,
+			rows:  [][]any{{-0.5, 1.5, 1.0, 1.75}},
+		},
+		{
+			query: `SELECT id FROM prices WHERE price > 1 AND ratio < 100;`,
+			rows:  [][]any{{int64(1)}, {int64(3)}},
+		},
+		{
+			query: `SELECT id FROM prices ORDER BY price;`,
+			rows:  [][]any{{int64(2)}, {int64(1)}, {int64(3)}},
+		},
+		{
+			query: `SELECT sum(price), avg(weight), min(ratio), max(price) FROM prices;`,
+			rows:  [][]any{{11.25, 1.25, 0.001, 10.0}},
+		},
+		{
+			query: `SELECT 1.5 || "x", 2 * 1.5e1;`,
+			rows:  [][]any{{"1.5x", 30.0}},
+		},
+		{
+			query: `SELECT price / 0.0 FROM prices;`,
+			err:   ErrDivisionByZero,
+		},
+		{
+			query: `SELECT 1e308 * 10;`,
+			err:   ErrFloatOutOfRange,
+		},
+		{
+			query: `SELECT "a" + 1.5;`,
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+			continue
+		}
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	_, err = db.Exec(`INSERT INTO prices VALUES (1.5, 1, 1, 1);`)
+	assert.True(t, errors.Is(err, ErrInvalidDatatype))
+	_, err = db.Exec(`INSERT INTO prices VALUES (4, 1, 1e39, 1);`)
+	assert.True(t, errors.Is(err, ErrFloatOutOfRange))
+
+	rows, err := db.Query(`SELECT price, weight FROM prices WHERE id = 1;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{DoubleType, RealType}, rows.ColumnTypes())
+	var items []struct {
+		Price  float64
+		Weight float32
+	}
+	assert.Nil(t, rows.ScanAll(&items))
+	assert.Equal(t, 1.5, items[0].Price)
+	assert.Equal(t, float32(2), items[0].Weight)
+}
+
+func TestIntegerTypes(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE counters (small SMALLINT, normal INTEGER, big BIGINT);
+		CREATE INDEX counters_small ON counters (small);
+		INSERT INTO counters VALUES (32767, 2147483647, 9223372036854775807);
+		INSERT INTO counters VALUES (-32768, -2147483648, -9223372036854775808);
+		INSERT INTO counters VALUES (1, 2, $1);`, int64(3000000000))
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+		err   error
+	}{
+		{
+			query: `SELECT small, normal, big FROM counters WHERE small = 1;`,
+			rows:  [][]any{{int64(1), int64(2), int64(3000000000)}},
+		},
+		{
+			query: `SELECT small + 1, normal + small, big / 2 FROM counters WHERE small = 1;`,
+			rows:  [][]any{{int64(2), int64(3), int64(1500000000)}},
+		},
+		{
+			query: `SELECT 2147483648 + 1, 3000000000 - 2999999999, count(*), sum(normal) FROM counters;`,
+			rows:  [][]any{{int64(2147483649), int64(1), int64(3), int64(1)}},
+		},
+		{
+			query: `SELECT small FROM counters WHERE big > 2147483647 ORDER BY small;`,
+			rows:  [][]any{{int64(1)}, {int64(32767)}},
+		},
+		{
+			query: `SELECT 2147483647 + 1;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT small * small FROM counters;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT normal * 2 FROM counters;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT big + 1 FROM counters;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT big * big FROM counters;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT big / -1 FROM counters;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT sum(big) FROM counters WHERE big > 0;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT 9223372036854775808;`,
+			err:   ErrIntegerOutOfRange,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+			continue
+		}
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	for _, insert := range []string{
+		`INSERT INTO counters VALUES (32768, 1, 1);`,
+		`INSERT INTO counters VALUES (1, 2147483648, 1);`,
+		`INSERT INTO counters VALUES (1, 1, 9223372036854775808);`,
+	} {
+		_, err = db.Exec(insert)
+		assert.True(t, errors.Is(err, ErrIntegerOutOfRange), "%s: %v", insert, err)
+	}
+
+	// Errors say which value didn't fit
+	for query, message := range map[string]string{
+		`SELECT normal * 2 FROM counters;`:             "4294967294 doesn't fit in INT",
+		`SELECT big + 1 FROM counters;`:                "9223372036854775807 + 1 doesn't fit in BIGINT",
+		`SELECT sum(big) FROM counters WHERE big > 0;`: "sum doesn't fit in BIGINT",
+	} {
+		_, err = queryAll(t, db, query)
+		assert.ErrorContains(t, err, message, query)
+	}
+
+	rows, err := db.Query(`SELECT small, normal, big, count(*) FROM counters GROUP BY small, normal, big;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{SmallIntType, IntType, BigIntType, BigIntType}, rows.ColumnTypes())
+	rows.Close()
+
+	plan, err := queryAll(t, db, `EXPLAIN SELECT normal FROM counters WHERE small = 1;`)
+	assert.Nil(t, err)
+	assert.Contains(t, plan[len(plan)-1][0], "Index Scan using counters_small")
+}
+
+func TestNumericType(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE payments (id INT, amount NUMERIC(10, 2), rate DECIMAL, units NUMERIC(3));
+		CREATE INDEX payments_amount ON payments (amount);
+		INSERT INTO payments VALUES (1, 0.1, 0.000001, 12.5);
+		INSERT INTO payments VALUES (2, 0.2, 1e-20, 3);
+		INSERT INTO payments VALUES (3, 19.999, 123456789012345678901234567890, 0);
+		INSERT INTO payments VALUES (4, $1, -2.5, NULL);`, "-12345678.125")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+		err   error
+	}{
+		{
+			query: `SELECT amount, rate, units FROM payments ORDER BY id;`,
+			rows: [][]any{
+				{"0.10", "0.000001", "13"},
+				{"0.20", "0.00000000000000000001", "3"},
+				{"20.00", "123456789012345678901234567890", "0"},
+				{"-12345678.13", "-2.5", nil},
+			},
+		},
+		{
+			query: `SELECT amount + amount + amount, amount * rate, amount - 1, units / 3 FROM payments WHERE id = 1;`,
+			rows:  [][]any{{"0.30", "0.00000010", "-0.90", "4.3333333333333333"}},
+		},
+		{
+			query: `SELECT rate + 1 FROM payments WHERE id = 3;`,
+			rows:  [][]any{{"123456789012345678901234567891"}},
+		},
+		{
+			query: `SELECT sum(amount), avg(units), max(rate) FROM payments WHERE id < 4;`,
+			rows:  [][]any{{"20.30", "5.3333333333333333", "123456789012345678901234567890"}},
+		},
+		{
+			query: `SELECT id FROM payments WHERE amount > 0.15 AND amount <= 20 ORDER BY amount DESC;`,
+			rows:  [][]any{{int64(3)}, {int64(2)}},
+		},
+		{
+			query: `SELECT amount * 2.0, amount * CAST(2 AS DOUBLE PRECISION), units < 1 FROM payments WHERE id = 2;`,
+			rows:  [][]any{{"0.400", 0.4, false}},
+		},
+		{
+			query: `SELECT amount + 0.2, amount * 1.1, 0.2 - amount, amount / 0.5 FROM payments WHERE id = 1;`,
+			rows:  [][]any{{"0.30", "0.110", "0.10", "0.2000000000000000"}},
+		},
+		{
+			query: `SELECT id FROM payments WHERE amount + 0.2 = 0.3 OR 0.4 = amount * 2;`,
+			rows:  [][]any{{int64(1)}, {int64(2)}},
+		},
+		{
+			query: `SELECT amount / 0 FROM payments;`,
+			err:   ErrDivisionByZero,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+			continue
+		}
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	_, err = db.Exec(`INSERT INTO payments VALUES (5, 100000000, 1, 1);`)
+	assert.True(t, errors.Is(err, ErrNumericOutOfRange), err)
+	_, err = db.Exec(`INSERT INTO payments VALUES (5, 1, 1, 999.5);`)
+	assert.True(t, errors.Is(err, ErrNumericOutOfRange), err)
+	assert.ErrorContains(t, err, "doesn't fit in NUMERIC(")
+	_, err = db.Exec(`INSERT INTO payments VALUES (5, "abc", 1, 1);`)
+	assert.True(t, errors.Is(err, ErrInvalidDatatype), err)
+	_, err = db.Exec(`CREATE TABLE wrong (amount NUMERIC(2, 3));`)
+	assert.True(t, errors.Is(err, ErrorInvalidDataType), err)
+	_, err = db.Exec(`CREATE TABLE wrong (amount INT(2));`)
+	assert.True(t, errors.Is(err, ErrorInvalidDataType), err)
+
+	rows, err := db.Query(`SELECT amount, sum(units) FROM payments WHERE id = 3 GROUP BY amount;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{NumericType, NumericType}, rows.ColumnTypes())
+	var items []struct {
+		Amount string
+		Sum    string
+	}
+	assert.Nil(t, rows.ScanAll(&items))
+	assert.Equal(t, "20.00", items[0].Amount)
+	assert.Equal(t, "0", items[0].Sum)
+}
+
+func TestTemporalTypes(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE events (id INT, day DATE, at TIME, created TIMESTAMP, length INTERVAL);
+		CREATE INDEX events_created ON events (created);
+		INSERT INTO events VALUES (1, '2024-01-31', '09:30', '2024-01-31 09:30:00', '1 day 02:00:00');
+		INSERT INTO events VALUES (2, DATE '2023-12-25', TIME '23:15:30.5', TIMESTAMP '2023-12-25 23:15:30.5',
+			INTERVAL '1 year 2 mons');
+		INSERT INTO events VALUES (3, $1, NULL, $1, '-90 minutes');`, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+		err   error
+	}{
+		{
+			query: `SELECT day || "", at || "", created || "", length || "" FROM events ORDER BY created;`,
+			rows: [][]any{
+				{"2023-12-25", "23:15:30.5", "2023-12-25 23:15:30.5", "1 year 2 mons"},
+				{"2024-01-31", "09:30:00", "2024-01-31 09:30:00", "1 day 02:00:00"},
+				{"2024-02-29", nil, "2024-02-29 00:00:00", "-01:30:00"},
+			},
+		},
+		{
+			query: `SELECT id FROM events WHERE day > '2024-01-01' AND created < TIMESTAMP '2024-02-29' ORDER BY id;`,
+			rows:  [][]any{{int64(1)}},
+		},
+		{
+			query: `SELECT id FROM events WHERE day = created ORDER BY id;`,
+			rows:  [][]any{{int64(3)}},
+		},
+		{
+			query: `SELECT id FROM events ORDER BY length;`,
+			rows:  [][]any{{int64(3)}, {int64(1)}, {int64(2)}},
+		},
+		{
+			query: `SELECT (day + 1) || "", (day - DATE '2024-01-01'), (day + INTERVAL '1 month') || ""
+				FROM events WHERE id = 1;`,
+			rows: [][]any{{"2024-02-01", int64(30), "2024-02-29 00:00:00"}},
+		},
+		{
+			query: `SELECT (created - INTERVAL '1 year 1 day') || "", (created - TIMESTAMP '2024-01-01') || "",
+				(TIMESTAMP '2024-01-01' - created) || "", (at - INTERVAL '10 hours') || "",
+				(length * 3) || "", (INTERVAL '1 mon' / 2) || "", (length + INTERVAL '30 minutes') || ""
+				FROM events WHERE id = 1;`,
+			rows: [][]any{{"2023-01-30 09:30:00", "30 days 09:30:00", "-30 days -09:30:00", "23:30:00",
+				"3 days 06:00:00", "15 days", "1 day 02:30:00"}},
+		},
+		{
+			query: `SELECT date_trunc('month', created) || "", date_trunc('week', day) || "",
+				date_trunc("hour", created) || "" FROM events WHERE id = 2;`,
+			rows: [][]any{{"2023-12-01 00:00:00", "2023-12-25 00:00:00", "2023-12-25 23:00:00"}},
+		},
+		{
+			query: `SELECT extract(year FROM created), extract(second FROM at), extract('dow', day),
+				extract(epoch FROM length), extract(month FROM length) FROM events WHERE id = 2;`,
+			rows: [][]any{{2023.0, 30.5, 1.0, 36741600.0, 2.0}},
+		},
+		{
+			query: `SELECT count(*) FROM events WHERE created < now() AND now() - created > INTERVAL '1 day';`,
+			rows:  [][]any{{int64(3)}},
+		},
+		{
+			query: `SELECT min(day) || "", max(length) || "" FROM events;`,
+			rows:  [][]any{{"2023-12-25", "1 year 2 mons"}},
+		},
+		{
+			query: `SELECT day FROM events WHERE day > 'yesterday';`,
+			err:   ErrInvalidDatatype,
+		},
+		{
+			query: `SELECT DATE '2024-02-30';`,
+			err:   ErrInvalidDatatype,
+		},
+		{
+			query: `SELECT day + day FROM events;`,
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: `SELECT date_trunc('fortnight', created) FROM events;`,
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: `SELECT length / 0 FROM events;`,
+			err:   ErrDivisionByZero,
+		},
+		// Intervals and timestamps that don't fit fail instead of wrapping around
+		{
+			query: `SELECT INTERVAL '1000000000 years';`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT INTERVAL '2000000000 days 2000000000 days';`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT INTERVAL '1e30 hours';`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT TIMESTAMP '2024-01-01 00:00:00' + INTERVAL '9223372036854775000 microseconds';`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT TIMESTAMP '2024-01-01 00:00:00' + INTERVAL '2000000000 days';`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT INTERVAL '4 mons' * 4611686018427387904;`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT INTERVAL '9223372036854775000 microseconds' + INTERVAL '1 hour';`,
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: `SELECT (TIME '10:00:00' + INTERVAL '9223372036854775000 microseconds') || '';`,
+			rows:  [][]any{{"14:00:54.774784"}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+			continue
+		}
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	_, err = db.Exec(`INSERT INTO events VALUES (4, 20240101, NULL, NULL, NULL);`)
+	assert.True(t, errors.Is(err, ErrInvalidDatatype), err)
+	_, err = db.Exec(`INSERT INTO events VALUES (4, NULL, NULL, DATE '2024-03-01' + INTERVAL '1 hour', NULL);`)
+	assert.Nil(t, err)
+
+	rows, err := db.Query(`SELECT day, created, at FROM events WHERE id = 1;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{DateType, TimestampType, TimeType}, rows.ColumnTypes())
+	var items []struct {
+		Day     time.Time
+		Created time.Time
+		At      string
+	}
+	assert.Nil(t, rows.ScanAll(&items))
+	assert.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), items[0].Day)
+	assert.Equal(t, time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC), items[0].Created)
+	assert.Equal(t, "09:30:00", items[0].At)
+
+	plan, err := queryAll(t, db, `EXPLAIN SELECT id FROM events WHERE created >= '2024-02-01';`)
+	assert.Nil(t, err)
+	assert.Contains(t, plan[len(plan)-1][0], "(created >= TIMESTAMP '2024-02-01 00:00:00')")
+}
+
+func TestTextTypes(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE people (id INT, name VARCHAR(5), code CHAR(3), flag CHAR, note CHARACTER VARYING, city CHARACTER(4));
+		CREATE INDEX people_name ON people (name);
+		CREATE INDEX people_code ON people (code);
+		INSERT INTO people VALUES (1, 'ana', 'ab', 'y', 'anything at all', 'rome');
+		INSERT INTO people VALUES (2, 'björn', 'xyz', NULL, NULL, 'oslo');
+		INSERT INTO people VALUES (3, 'eve    ', 'q  ', 'n  ', '', $1);`, "nice")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+	}{
+		{
+			query: `SELECT name, code, flag, note, city FROM people ORDER BY id;`,
+			rows: [][]any{
+				{"ana", "ab ", "y", "anything at all", "rome"},
+				{"björn", "xyz", nil, nil, "oslo"},
+				{"eve  ", "q  ", "n", "", "nice"},
+			},
+		},
+		{
+			query: `SELECT id FROM people WHERE name = "björn";`,
+			rows:  [][]any{{int64(2)}},
+		},
+		{
+			query: `SELECT id, code = 'ab', code = 'ab ', length(code), code || '|', length(flag) FROM people ORDER BY id;`,
+			rows: [][]any{
+				{int64(1), true, true, int64(2), "ab|", int64(1)},
+				{int64(2), false, false, int64(3), "xyz|", nil},
+				{int64(3), false, false, int64(1), "q|", int64(1)},
+			},
+		},
+		{
+			query: `SELECT id FROM people WHERE code = 'q' OR city = 'rome' ORDER BY id;`,
+			rows:  [][]any{{int64(1)}, {int64(3)}},
+		},
+		{
+			query: `SELECT id FROM people WHERE code = 'ab';`,
+			rows:  [][]any{{int64(1)}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	for _, insert := range []string{
+		`INSERT INTO people VALUES (4, 'abcdef', 'a', 'a', 'a', 'a');`,
+		`INSERT INTO people VALUES (4, 'a', 'abcd', 'a', 'a', 'a');`,
+		`INSERT INTO people VALUES (4, 'a', 'a', 'ab', 'a', 'a');`,
+		`INSERT INTO people VALUES (4, 'a', 'a', 'a', 'a', 'paris');`,
+	} {
+		_, err = db.Exec(insert)
+		assert.True(t, errors.Is(err, ErrValueTooLong), "%s: %v", insert, err)
+	}
+	_, err = db.Exec(`INSERT INTO people VALUES (4, 12, 'a', 'a', 'a', 'a');`)
+	assert.True(t, errors.Is(err, ErrInvalidDatatype), err)
+	for _, create := range []string{
+		`CREATE TABLE wrong (name VARCHAR(0));`,
+		`CREATE TABLE wrong (name VARCHAR(1, 2));`,
+	} {
+		_, err = db.Exec(create)
+		assert.True(t, errors.Is(err, ErrorInvalidDataType), "%s: %v", create, err)
+	}
+
+	rows, err := db.Query(`SELECT name, code FROM people;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{TextType, TextType}, rows.ColumnTypes())
+	rows.Close()
+}
+
+func TestByteaType(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE files (id INT, data BYTEA, thumb BLOB);
+		CREATE INDEX files_data ON files (data);
+		INSERT INTO files VALUES (1, X'DEADBEEF', NULL);
+		INSERT INTO files VALUES (2, x'00ff', 'ab');
+		INSERT INTO files VALUES (3, $1, '\x0102');`, []byte{0, 1, 2})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+	}{
+		{
+			query: `SELECT data, thumb FROM files ORDER BY id;`,
+			rows: [][]any{
+				{[]byte{0xde, 0xad, 0xbe, 0xef}, nil},
+				{[]byte{0, 0xff}, []byte("ab")},
+				{[]byte{0, 1, 2}, []byte{1, 2}},
+			},
+		},
+		{
+			query: `SELECT id FROM files ORDER BY data;`,
+			rows:  [][]any{{int64(3)}, {int64(2)}, {int64(1)}},
+		},
+		{
+			query: `SELECT id FROM files WHERE data = X'00FF' OR thumb = 'ab';`,
+			rows:  [][]any{{int64(2)}},
+		},
+		{
+			query: `SELECT length(data), substring(data, 2, 2), substring(data, 3), data || X'00' FROM files WHERE id = 1;`,
+			rows:  [][]any{{int64(4), []byte{0xad, 0xbe}, []byte{0xbe, 0xef}, []byte{0xde, 0xad, 0xbe, 0xef, 0}}},
+		},
+		{
+			query: `SELECT length('héllo'), substring('héllo', 2, 3), substring('héllo', 0, 2), substring('héllo', 9);`,
+			rows:  [][]any{{int64(5), "éll", "h", ""}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	_, err = db.Exec(`INSERT INTO files VALUES (4, X'ABC', NULL);`)
+	assert.True(t, errors.Is(err, ErrInvalidDatatype), err)
+	_, err = db.Exec(`INSERT INTO files VALUES (X'01', NULL, NULL);`)
+	assert.True(t, errors.Is(err, ErrInvalidDatatype), err)
+	_, err = db.Query(`SELECT length(id) FROM files;`)
+	assert.True(t, errors.Is(err, ErrInvalidOperands), err)
+
+	// Bound bytes are stored as they are, even when they look like the hex form of a string
+	escaped := []byte{'\\', 'x', 'A', 'B'}
+	_, err = db.Exec(`INSERT INTO files VALUES (5, $1, NULL);`, escaped)
+	assert.Nil(t, err)
+	stored, err := db.Query(`SELECT data FROM files WHERE id = 5 AND data = $1;`, escaped)
+	assert.Nil(t, err)
+	assert.True(t, stored.Next())
+	var back []byte
+	assert.Nil(t, stored.Scan(&back))
+	assert.Equal(t, escaped, back)
+	assert.Nil(t, stored.Close())
+
+	rows, err := db.Query(`SELECT data FROM files WHERE id = 2;`)
+	assert.Nil(t, err)
+	defer rows.Close()
+	assert.Equal(t, []ColumnType{ByteaType}, rows.ColumnTypes())
+	assert.True(t, rows.Next())
+	var data []byte
+	assert.Nil(t, rows.Scan(&data))
+	assert.Equal(t, []byte{0, 0xff}, data)
+}
+
+func TestJSONType(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE events (id INT, payload JSON);
+		INSERT INTO events VALUES (1, '{"type": "click", "items": [{"name": "a"}, {"name": "b"}], "count": 2}');
+		INSERT INTO events VALUES (2, '{"type": "view", "items": [], "note": null}');
+		INSERT INTO events VALUES (3, $1);
+		INSERT INTO events VALUES (4, NULL);`, `[1, "two", {"three": 3}]`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+		err   error
+	}{
+		{
+			query: `SELECT payload ->> 'type', payload -> 'count', payload -> 'items' -> 1 -> 'name' FROM events ORDER BY id;`,
+			rows: [][]any{
+				{"click", "2", `"b"`},
+				{"view", nil, nil},
+				{nil, nil, nil},
+				{nil, nil, nil},
+			},
+		},
+		{
+			query: `SELECT payload -> 0, payload ->> 1, payload ->> -1, payload -> 5 FROM events WHERE id = 3;`,
+			rows:  [][]any{{"1", "two", `{"three": 3}`, nil}},
+		},
+		{
+			query: `SELECT id FROM events WHERE payload->>'type' = 'click' OR payload ->> 'note' = 'x';`,
+			rows:  [][]any{{int64(1)}},
+		},
+		{
+			query: `SELECT json_extract(payload, '$.items[0].name'), json_extract(payload, '$.missing'), json_extract(payload, '$') FROM events WHERE id = 1;`,
+			rows:  [][]any{{`"a"`, nil, `{"type": "click", "items": [{"name": "a"}, {"name": "b"}], "count": 2}`}},
+		},
+		{
+			query: `SELECT json_extract(payload, '$[2].three'), json_array_length(payload) FROM events WHERE id = 3;`,
+			rows:  [][]any{{"3", int64(3)}},
+		},
+		{
+			query: `SELECT json_array_length(payload -> 'items') FROM events WHERE id < 3 ORDER BY id;`,
+			rows:  [][]any{{int64(2)}, {int64(0)}},
+		},
+		{
+			query: `SELECT json_array_length(payload) FROM events WHERE id = 1;`,
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: `SELECT json_extract(payload, 'items') FROM events WHERE id = 1;`,
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: `SELECT id -> 'a' FROM events;`,
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+			continue
+		}
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	for _, insert := range []string{
+		`INSERT INTO events VALUES (5, '{"type": }');`,
+		`INSERT INTO events VALUES (5, 12);`,
+	} {
+		_, err = db.Exec(insert)
+		assert.True(t, errors.Is(err, ErrInvalidDatatype), "%s: %v", insert, err)
+	}
+
+	rows, err := db.Query(`SELECT payload, payload -> 'type', payload ->> 'type' FROM events;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{JsonType, JsonType, TextType}, rows.ColumnTypes())
+	rows.Close()
+}
+
+func TestUUIDType(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE users (id UUID DEFAULT gen_random_uuid(), name TEXT);
+		CREATE INDEX users_id ON users (id);
+		INSERT INTO users VALUES ('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', 'ana');
+		INSERT INTO users VALUES ('{B0EEBC999C0B4EF8BB6D6BB9BD380A11}', 'luis');
+		INSERT INTO users (name) VALUES ('eva');
+		INSERT INTO users (name) VALUES ('juan');`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+	}{
+		{
+			query: `SELECT id, name FROM users WHERE name = 'ana' OR name = 'luis' ORDER BY id DESC;`,
+			rows: [][]any{
+				{"b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "luis"},
+				{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "ana"},
+			},
+		},
+		{
+			query: `SELECT name FROM users WHERE id = 'A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11';`,
+			rows:  [][]any{{"ana"}},
+		},
+		{
+			query: `SELECT name FROM users WHERE id > 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11' AND name > 'k';`,
+			rows:  [][]any{{"luis"}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	// Generated ids are random version 4 UUIDs, different for each row
+	generated, err := queryAll(t, db, `SELECT id FROM users WHERE name = 'eva' OR name = 'juan';`)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(generated))
+	assert.NotEqual(t, generated[0][0], generated[1][0])
+	for _, row := range generated {
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, row[0])
+	}
+	both, err := queryAll(t, db, `SELECT gen_random_uuid() = gen_random_uuid();`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]any{{false}}, both)
+
+	for _, insert := range []string{
+		`INSERT INTO users VALUES ('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a1', 'x');`,
+		`INSERT INTO users VALUES ('a0eebc99x9c0b-4ef8-bb6d-6bb9bd380a11', 'x');`,
+		`INSERT INTO users VALUES (12, 'x');`,
+	} {
+		_, err = db.Exec(insert)
+		assert.True(t, errors.Is(err, ErrInvalidDatatype), "%s: %v", insert, err)
+	}
+
+	rows, err := db.Query(`SELECT id FROM users;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{UuidType}, rows.ColumnTypes())
+	rows.Close()
+}
+
+func TestArrayTypes(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE posts (id INT, scores INT[], tags TEXT[]);
+		INSERT INTO posts VALUES (1, ARRAY[3, 1, 2], ARRAY['go', 'sql']);
+		INSERT INTO posts VALUES (2, '{5, NULL}', '{"hello world",db}');
+		INSERT INTO posts VALUES (3, ARRAY[], '{}');
+		INSERT INTO posts VALUES (4, NULL, ARRAY['go']);`)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+	}{
+		{
+			query: `SELECT id, scores, tags FROM posts;`,
+			rows: [][]any{
+				{int64(1), "{3,1,2}", "{go,sql}"},
+				{int64(2), "{5,NULL}", `{"hello world",db}`},
+				{int64(3), "{}", "{}"},
+				{int64(4), nil, "{go}"},
+			},
+		},
+		{
+			query: `SELECT id, scores[1], tags[2], scores[5] FROM posts WHERE id < 3;`,
+			rows:  [][]any{{int64(1), int64(3), "sql", nil}, {int64(2), int64(5), "db", nil}},
+		},
+		{
+			query: `SELECT id FROM posts WHERE 'go' = ANY(tags);`,
+			rows:  [][]any{{int64(1)}, {int64(4)}},
+		},
+		{
+			query: `SELECT id, 4 > ANY(scores), 9 = ANY(scores) FROM posts;`,
+			rows: [][]any{
+				{int64(1), true, false},
+				{int64(2), nil, nil},
+				{int64(3), false, false},
+				{int64(4), nil, nil},
+			},
+		},
+		{
+			query: `SELECT id, unnest(tags) FROM posts;`,
+			rows: [][]any{
+				{int64(1), "go"},
+				{int64(1), "sql"},
+				{int64(2), "hello world"},
+				{int64(2), "db"},
+				{int64(4), "go"},
+			},
+		},
+		{
+			query: `SELECT unnest(scores), unnest(tags) FROM posts WHERE id = 1;`,
+			rows:  [][]any{{int64(3), "go"}, {int64(1), "sql"}, {int64(2), nil}},
+		},
+		{
+			query: `SELECT id FROM posts WHERE id < 4 ORDER BY scores;`,
+			rows:  [][]any{{int64(3)}, {int64(1)}, {int64(2)}},
+		},
+		{
+			query: `SELECT id FROM posts WHERE tags = '{go,sql}';`,
+			rows:  [][]any{{int64(1)}},
+		},
+		{
+			query: `SELECT ARRAY[1, 2.5], ARRAY[1, NULL][2], ARRAY['a', 'b'] = ARRAY['a', 'b'];`,
+			rows:  [][]any{{"{1,2.5}", nil, true}},
+		},
+		{
+			query: `SELECT id, scores || scores, 0 || scores || 4, tags || 'rust', scores || NULL FROM posts WHERE id <> 2;`,
+			rows: [][]any{
+				{int64(1), "{3,1,2,3,1,2}", "{0,3,1,2,4}", "{go,sql,rust}", nil},
+				{int64(3), "{}", "{0,4}", "{rust}", nil},
+				{int64(4), nil, nil, "{go,rust}", nil},
+			},
+		},
+		{
+			query: `SELECT ARRAY[1] || ARRAY[2.5], (ARRAY[1] || 2)[2];`,
+			rows:  [][]any{{"{1,2.5}", int64(2)}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	errorTests := []struct {
+		query string
+		err   error
+	}{
+		{`INSERT INTO posts VALUES (5, ARRAY[DATE '2024-01-02'], NULL);`, ErrInvalidDatatype},
+		{`INSERT INTO posts VALUES (5, '{1,2', NULL);`, ErrInvalidDatatype},
+		{`INSERT INTO posts VALUES (5, 1, NULL);`, ErrInvalidDatatype},
+		{`SELECT ARRAY[1, 'x'];`, ErrInvalidOperands},
+		{`SELECT ARRAY[NULL];`, ErrInvalidDatatype},
+		{`SELECT ARRAY[ARRAY[1]];`, ErrNotSupported},
+		{`SELECT id[1] FROM posts;`, ErrInvalidOperands},
+		{`SELECT 1 = ANY(id) FROM posts;`, ErrInvalidOperands},
+		{`SELECT unnest(id) FROM posts;`, ErrInvalidOperands},
+		{`SELECT id FROM posts WHERE unnest(tags) = 'go';`, ErrInvalidSelectItem},
+		{`SELECT scores || tags FROM posts;`, ErrInvalidOperands},
+		{`SELECT scores || 'x' FROM posts;`, ErrInvalidOperands},
+	}
+	for _, test := range errorTests {
+		_, err := db.Query(test.query)
+		if err == nil {
+			_, err = db.Exec(test.query)
+		}
+		assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE codes (c VARCHAR(2)[]);
+		INSERT INTO codes VALUES (ARRAY['ab']);`)
+	assert.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO codes VALUES ('{abc}');`)
+	assert.True(t, errors.Is(err, ErrValueTooLong), err)
+
+	rows, err := db.Query(`SELECT scores, tags, unnest(scores), scores || 1.5 FROM posts;`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ColumnType{arrayOf(IntType), arrayOf(TextType), IntType, arrayOf(DoubleType)}, rows.ColumnTypes())
+	rows.Close()
+}
+
+func TestCasts(t *testing.T) {
+	db := Open()
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE items (id INT, price NUMERIC(6, 2), qty SMALLINT, sold TIMESTAMP, active BOOLEAN, code VARCHAR(3));
+		INSERT INTO items VALUES ('1', '9.99', 3, '2024-01-02 10:30:00', TRUE, 'abc');
+		INSERT INTO items VALUES (2, 5, CAST(12.6 AS INT), NULL, 'no', $1);
+		INSERT INTO items VALUES (3, 2.5 * 2, 7::BIGINT, '2024-03-04', 'on', NULL);`, "xy")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		query string
+		rows  [][]any
+	}{
+		{
+			query: `SELECT id, price, qty, active, code FROM items;`,
+			rows: [][]any{
+				{int64(1), "9.99", int64(3), true, "abc"},
+				{int64(2), "5.00", int64(13), false, "xy"},
+				{int64(3), "5.00", int64(7), true, nil},
+			},
+		},
+		{
+			query: `SELECT id FROM items WHERE id = '2' OR qty > '10';`,
+			rows:  [][]any{{int64(2)}},
+		},
+		{
+			query: `SELECT CAST(id AS TEXT) || '!', price::INT, CAST(sold AS DATE), sold::TIME FROM items WHERE id = 1;`,
+			rows:  [][]any{{"1!", int64(10), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "10:30:00"}},
+		},
+		{
+			query: `SELECT CAST('12' AS INT) + 1, '2.5'::DOUBLE PRECISION, CAST(2.5 AS INT), CAST(3.5 AS INT), 1.239::NUMERIC(4, 2);`,
+			rows:  [][]any{{int64(13), 2.5, int64(2), int64(4), "1.24"}},
+		},
+		{
+			query: `SELECT CAST('abcdef' AS VARCHAR(3)), 'a'::CHAR(3), CAST(1 AS BOOL), TRUE::INT, CAST(NULL AS INT);`,
+			rows:  [][]any{{"abc", "a  ", true, int64(1), nil}},
+		},
+		{
+			query: `SELECT CAST('{1,2}' AS INT[])[2], ARRAY[1.5, 2.5]::INT[], CAST(ARRAY[1, 2] AS TEXT[]);`,
+			rows:  [][]any{{int64(2), "{2,2}", "{1,2}"}},
+		},
+		{
+			query: `SELECT ARRAY[]::INT[], CAST(ARRAY[NULL] AS DATE[]), ARRAY[]::TEXT[] || 'x', ARRAY[]::INT[] = '{}';`,
+			rows:  [][]any{{"{}", "{NULL}", "{x}", true}},
+		},
+		{
+			query: `SELECT DATE '2024-01-02', CAST('2024-01-02' AS DATE) = DATE '2024-01-02';`,
+			rows:  [][]any{{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), true}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := queryAll(t, db, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	errorTests := []struct {
+		query string
+		err   error
+	}{
+		{`INSERT INTO items VALUES ('one', 1, 1, NULL, TRUE, NULL);`, ErrInvalidDatatype},
+		{`INSERT INTO items VALUES (TRUE, 1, 1, NULL, TRUE, NULL);`, ErrInvalidDatatype},
+		{`INSERT INTO items VALUES (1, 1, 1, NULL, 'maybe', NULL);`, ErrInvalidDatatype},
+		{`INSERT INTO items VALUES (1, 1, 1, NULL, 1, NULL);`, ErrInvalidDatatype},
+		{`INSERT INTO items VALUES (1, 1, 1, 5, TRUE, NULL);`, ErrInvalidDatatype},
+		{`INSERT INTO items VALUES (1, 1, 40000::INT, NULL, TRUE, NULL);`, ErrIntegerOutOfRange},
+		{`INSERT INTO items VALUES (1, 1, 1, NULL, TRUE, 12 + 1);`, ErrInvalidDatatype},
+		{`SELECT id FROM items WHERE id = 'two';`, ErrInvalidDatatype},
+		{`SELECT CAST('x' AS INT);`, ErrInvalidDatatype},
+		{`SELECT CAST(DATE '2024-01-02' AS INT);`, ErrInvalidDatatype},
+		{`SELECT CAST(1 AS UUID);`, ErrInvalidDatatype},
+		{`SELECT CAST(99999 AS SMALLINT);`, ErrIntegerOutOfRange},
+		{`SELECT CAST(123.4 AS NUMERIC(3, 1));`, ErrNumericOutOfRange},
+	}
+	for _, test := range errorTests {
+		_, err := db.Query(test.query)
+		if err == nil {
+			_, err = db.Exec(test.query)
+		}
+		assert.True(t, errors.Is(err, test.err), "%s: %v", test.query, err)
+	}
+	_, err = queryAll(t, db, `SELECT CAST(1e10 AS INT);`)
+	assert.ErrorContains(t, err, "10000000000 doesn't fit in INT")
+
+	rows, err := db.Query(`SELECT active, CAST(id AS BIGINT), code::VARCHAR(1), ARRAY[id]::TEXT[] FROM items;`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []ColumnType{BoolType, BigIntType, TextType, arrayOf(TextType)}, rows.ColumnTypes())
+		rows.Close()
+	}
+}
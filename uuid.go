@@ -0,0 +1,66 @@
+package gosql
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+/*
+UUIDs
+-----
+UUID columns keep their 16 bytes, not the 36 characters they're written with. They're read from strings like
+'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', in upper or lower case, without the hyphens or between braces, and written
+back in that first form in lower case. They compare by their bytes, which is the order of their text too.
+
+gen_random_uuid() gives a new random (version 4) UUID every time it's called, so unlike now() it isn't computed
+once per statement, and it's meant for DEFAULT clauses like id UUID DEFAULT gen_random_uuid().
+*/
+
+const uuidSize = 16
+
+// parseUUID reads a UUID in any of the forms above
+func parseUUID(s string) (MemoryCell, error) {
+	invalid := fmt.Errorf("%w: %s is not a UUID", ErrInvalidDatatype, s)
+
+	digits := s
+	if strings.HasPrefix(digits, "{") && strings.HasSuffix(digits, "}") {
+		digits = digits[1 : len(digits)-1]
+	}
+	if len(digits) == 36 {
+		for _, i := range []int{8, 13, 18, 23} {
+			if digits[i] != '-' {
+				return nil, invalid
+			}
+		}
+		digits = strings.ReplaceAll(digits, "-", "")
+	}
+	if len(digits) != 2*uuidSize {
+		return nil, invalid
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, invalid
+	}
+	return b, nil
+}
+
+func uuidText(cell MemoryCell) string {
+	s := hex.EncodeToString(cell)
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+func compileGenRandomUUID(args []evaluator, types []ColumnType) (evaluator, ColumnType, error) {
+	return func([]MemoryCell) (MemoryCell, error) {
+		cell := make(MemoryCell, uuidSize)
+		if _, err := rand.Read(cell); err != nil {
+			return nil, err
+		}
+		// The version, 4, and the variant, RFC 4122
+		cell[6] = cell[6]&0x0f | 0x40
+		cell[8] = cell[8]&0x3f | 0x80
+		return cell, nil
+	}, UuidType, nil
+}
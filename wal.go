@@ -0,0 +1,178 @@
+package gosql
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+)
+
+/*
+Write-Ahead Log
+---------------
+Every statement the disk backend runs is a small transaction: once it's done, the image of every page it changed
+is appended to the log followed by a commit record, and the log is synced before returning. Pages only reach the
+database file after they are safe in the log, so after a crash we just write back the pages of every committed
+transaction we find in the log, in order. A checkpoint flushes every page to the database file and empties the log.
+
+Record layout:
+	[0:4]   payload length
+	[4]     record type
+	[5:9]   page id
+	payload
+	crc32 of everything above
+
+A record that is cut short or doesn't match its checksum marks the end of the log, anything after it was never
+committed.
+*/
+
+const (
+	walPageRecord   byte = 1
+	walCommitRecord byte = 2
+
+	walHeaderSize = 9
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errTornRecord = errors.New("torn wal record")
+
+type wal struct {
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+}
+
+func openWAL(path string) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &wal{
+		file:   f,
+		writer: bufio.NewWriter(f),
+		size:   info.Size(),
+	}, nil
+}
+
+func (w *wal) append(kind byte, id pageID, payload []byte) error {
+	record := make([]byte, walHeaderSize, walHeaderSize+len(payload)+4)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	record[4] = kind
+	binary.BigEndian.PutUint32(record[5:9], uint32(id))
+	record = append(record, payload...)
+	record = binary.BigEndian.AppendUint32(record, crc32.Checksum(record, crcTable))
+
+	if _, err := w.writer.Write(record); err != nil {
+		return err
+	}
+	w.size += int64(len(record))
+	return nil
+}
+
+func (w *wal) appendPage(id pageID, data *[pageSize]byte) error {
+	return w.append(walPageRecord, id, data[:])
+}
+
+// commit marks every page appended since the last commit as committed and syncs the log
+func (w *wal) commit() error {
+	if err := w.append(walCommitRecord, 0, nil); err != nil {
+		return err
+	}
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *wal) readRecord(r *bufio.Reader) (byte, pageID, []byte, error) {
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, errTornRecord
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > pageSize {
+		return 0, 0, nil, errTornRecord
+	}
+
+	rest := make([]byte, length+4)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, nil, errTornRecord
+	}
+
+	payload := rest[:length]
+	sum := binary.BigEndian.Uint32(rest[length:])
+	if crc32.Update(crc32.Checksum(header, crcTable), crcTable, payload) != sum {
+		return 0, 0, nil, errTornRecord
+	}
+	return header[4], pageID(binary.BigEndian.Uint32(header[5:9])), payload, nil
+}
+
+// replay calls apply with the last image of every page changed by each committed transaction, in commit order
+func (w *wal) replay(apply func(pageID, *[pageSize]byte) error) error {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(w.file)
+	pages := map[pageID]*[pageSize]byte{}
+	for {
+		kind, id, payload, err := w.readRecord(r)
+		if err == errTornRecord {
+			return nil
+		}
+
+		switch kind {
+		case walPageRecord:
+			if len(payload) != pageSize {
+				return nil
+			}
+			data := [pageSize]byte{}
+			copy(data[:], payload)
+			pages[id] = &data
+		case walCommitRecord:
+			ids := []pageID{}
+			for id := range pages {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+			for _, id := range ids {
+				if err := apply(id, pages[id]); err != nil {
+					return err
+				}
+			}
+			pages = map[pageID]*[pageSize]byte{}
+		default:
+			return nil
+		}
+	}
+}
+
+// reset empties the log, it must only be called once every page in it is safe in the database file
+func (w *wal) reset() error {
+	w.writer.Reset(w.file)
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.size = 0
+	return w.file.Sync()
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
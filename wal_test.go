@@ -0,0 +1,108 @@
+package gosql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// crash drops the backend as if the process died, nothing that wasn't written to the files yet makes it there
+func crash(db *DiskBackend) {
+	db.wal.close()
+	db.pager.close()
+}
+
+func TestDiskBackend_recovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crash.db")
+
+	db, err := OpenDiskBackend(path)
+	assert.Nil(t, err)
+	db.checkpointSize = math.MaxInt64
+
+	// Size of the log after every commit
+	commits := []int64{}
+	execute(t, db, `CREATE TABLE users (id INT, name TEXT);`)
+	commits = append(commits, db.wal.size)
+	for i := 0; i < 20; i++ {
+		execute(t, db, fmt.Sprintf(`INSERT INTO users VALUES (%d, "user %d");`, i, i))
+		commits = append(commits, db.wal.size)
+	}
+	crash(db)
+
+	dbFile, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	walFile, err := os.ReadFile(path + "-wal")
+	assert.Nil(t, err)
+	assert.Equal(t, commits[len(commits)-1], int64(len(walFile)))
+
+	offsets := []int64{}
+	for offset := int64(0); offset < int64(len(walFile)); offset += 499 {
+		offsets = append(offsets, offset)
+	}
+	for _, commit := range commits {
+		offsets = append(offsets, commit-1, commit, commit+1)
+	}
+
+	for _, offset := range offsets {
+		if offset > int64(len(walFile)) {
+			continue
+		}
+
+		recovered := filepath.Join(dir, fmt.Sprintf("recovered-%d.db", offset))
+		assert.Nil(t, os.WriteFile(recovered, dbFile, 0644))
+		assert.Nil(t, os.WriteFile(recovered+"-wal", walFile[:offset], 0644))
+
+		committed := 0
+		for _, commit := range commits {
+			if commit <= offset {
+				committed++
+			}
+		}
+
+		db, err := OpenDiskBackend(recovered)
+		assert.Nil(t, err, offset)
+		assert.Equal(t, int64(0), db.wal.size, offset)
+
+		ast, err := Parse(`SELECT id, name FROM users;`)
+		assert.Nil(t, err)
+		rows, err := db.Select(context.Background(), ast.Statements[0].SelectStatement)
+		if committed == 0 {
+			assert.Equal(t, ErrTableDoesNotExist, err, offset)
+		} else {
+			assert.Nil(t, err, offset)
+			all := collect(t, rows)
+			assert.Equal(t, committed-1, len(all), offset)
+			for i, row := range all {
+				assert.Equal(t, int32(i), row[0].AsInt(), offset)
+			}
+		}
+		assert.Nil(t, db.Close())
+	}
+}
+
+func TestDiskBackend_checkpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.db")
+
+	db, err := OpenDiskBackend(path)
+	assert.Nil(t, err)
+	db.checkpointSize = 3 * pageSize
+
+	execute(t, db, `CREATE TABLE users (id INT, name TEXT);`)
+	for i := 0; i < 100; i++ {
+		execute(t, db, fmt.Sprintf(`INSERT INTO users VALUES (%d, "user %d");`, i, i))
+		assert.Less(t, db.wal.size, int64(6*pageSize))
+	}
+	crash(db)
+
+	db, err = OpenDiskBackend(path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	assert.Equal(t, 100, len(collect(t, execute(t, db, `SELECT id FROM users;`))))
+}